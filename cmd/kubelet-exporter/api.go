@@ -0,0 +1,81 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/api"
+	"github.com/cofyc/kubelet-exporter/pkg/auth"
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+const (
+	apiVolumesPath = "/api/v1/volumes"
+	apiPodsPath    = "/api/v1/pods"
+)
+
+// apiVolumesHandler serves the PVCs used by pods on the node with their
+// current usage. The namespace query parameters select namespaces, the sort
+// query parameter the order.
+func apiVolumesHandler(client *kubelet.Client, authn *auth.Authenticator, resolver *auth.NamespaceResolver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		summary, namespaces, ok := apiSummary(w, r, client, authn, resolver)
+		if !ok {
+			return
+		}
+		volumes := api.Volumes(summary, namespaces, time.Now())
+		if err := api.SortVolumes(volumes, r.URL.Query().Get("sort")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, &api.VolumeList{Items: volumes})
+	}
+}
+
+// apiPodsHandler serves the pods on the node with the current usage of their
+// volumes. The namespace query parameters select namespaces, the sort query
+// parameter the order.
+func apiPodsHandler(client *kubelet.Client, authn *auth.Authenticator, resolver *auth.NamespaceResolver) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		summary, namespaces, ok := apiSummary(w, r, client, authn, resolver)
+		if !ok {
+			return
+		}
+		pods := api.Pods(summary, namespaces, time.Now())
+		if err := api.SortPods(pods, r.URL.Query().Get("sort")); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		writeJSON(w, &api.PodList{Items: pods})
+	}
+}
+
+// apiSummary authorizes r and fetches a summary. It returns the namespaces
+// selected by the namespace query parameters which the tenant may see, or nil
+// for all namespaces. It writes an error to w on failure.
+func apiSummary(w http.ResponseWriter, r *http.Request, client *kubelet.Client, authn *auth.Authenticator, resolver *auth.NamespaceResolver) (*v1alpha1.Summary, map[string]bool, bool) {
+	allowed, ok := authorize(w, r, authn, resolver)
+	if !ok {
+		return nil, nil, false
+	}
+	namespaces := allowed
+	if selected := r.URL.Query()["namespace"]; len(selected) > 0 {
+		namespaces = map[string]bool{}
+		for _, ns := range selected {
+			if allowed == nil || allowed[ns] {
+				namespaces[ns] = true
+			}
+		}
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	summary, err := client.GetSummary(ctx)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to get summary: %v", err), http.StatusBadGateway)
+		return nil, nil, false
+	}
+	return summary, namespaces, true
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+)
+
+const testSummary = `{
+  "node": {"nodeName": "node-1"},
+  "pods": [
+    {
+      "podRef": {"name": "web-0", "namespace": "team-a", "uid": "1"},
+      "volume": [{"name": "data", "pvcRef": {"name": "data", "namespace": "team-a"}, "capacityBytes": 1000, "usedBytes": 100}]
+    },
+    {
+      "podRef": {"name": "cache-0", "namespace": "team-b", "uid": "2"},
+      "volume": [{"name": "cache", "pvcRef": {"name": "cache", "namespace": "team-b"}, "capacityBytes": 1000, "usedBytes": 900}]
+    }
+  ]
+}`
+
+func TestAPIHandlersSort(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(testSummary))
+	}))
+	defer server.Close()
+	client, err := kubelet.NewClient(server.URL, kubelet.ClientConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		handler http.Handler
+		url     string
+		status  int
+		want    []string
+	}{
+		{handler: apiVolumesHandler(client, nil, nil), url: apiVolumesPath, status: http.StatusOK, want: []string{"data", "cache"}},
+		{handler: apiVolumesHandler(client, nil, nil), url: apiVolumesPath + "?sort=-usedBytes", status: http.StatusOK, want: []string{"cache", "data"}},
+		{handler: apiVolumesHandler(client, nil, nil), url: apiVolumesPath + "?sort=size", status: http.StatusBadRequest},
+		{handler: apiPodsHandler(client, nil, nil), url: apiPodsPath + "?sort=-volumeUsedBytes", status: http.StatusOK, want: []string{"cache-0", "web-0"}},
+		{handler: apiPodsHandler(client, nil, nil), url: apiPodsPath + "?sort=-size", status: http.StatusBadRequest},
+	}
+	for _, test := range tests {
+		w := httptest.NewRecorder()
+		test.handler.ServeHTTP(w, httptest.NewRequest("GET", test.url, nil))
+		if w.Code != test.status {
+			t.Errorf("got status %d for %s, want %d", w.Code, test.url, test.status)
+			continue
+		}
+		if test.status != http.StatusOK {
+			continue
+		}
+		var list struct {
+			Items []struct {
+				Name                  string `json:"name"`
+				PersistentVolumeClaim string `json:"persistentVolumeClaim"`
+			} `json:"items"`
+		}
+		if err := json.Unmarshal(w.Body.Bytes(), &list); err != nil {
+			t.Errorf("failed to parse response of %s: %v", test.url, err)
+			continue
+		}
+		var got []string
+		for _, item := range list.Items {
+			got = append(got, item.Name+item.PersistentVolumeClaim)
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("got %v for %s, want %v", got, test.url, test.want)
+		}
+	}
+}
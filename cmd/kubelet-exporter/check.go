@@ -0,0 +1,265 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+	"github.com/cofyc/kubelet-exporter/pkg/openmetrics"
+)
+
+// checkTimeout is the time each network step of the check command may take.
+const checkTimeout = 10 * time.Second
+
+// checker runs the steps of the check command and prints their results to
+// out.
+type checker struct {
+	out    io.Writer
+	failed int
+}
+
+// pass prints a passed step.
+func (c *checker) pass(step, detail string) {
+	fmt.Fprintf(c.out, "[PASS] %s: %s\n", step, detail)
+}
+
+// warn prints a step which passed, but may not do what is expected.
+func (c *checker) warn(step, detail, hint string) {
+	fmt.Fprintf(c.out, "[WARN] %s: %s\n       hint: %s\n", step, detail, hint)
+}
+
+// fail prints a failed step.
+func (c *checker) fail(step string, err error, hint string) {
+	c.failed++
+	fmt.Fprintf(c.out, "[FAIL] %s: %v\n       hint: %s\n", step, err, hint)
+}
+
+// runCheck checks the connection to the kubelet and the collectors with the
+// flags of the exporter, e.g. before deploying it with them. Each step
+// passes or fails with a hint on how to fix it.
+func runCheck(args []string) error {
+	flag.CommandLine.Parse(args)
+	c := &checker{out: os.Stdout}
+	if c.checkKubelet() {
+		c.checkCollectors()
+	}
+	if c.failed > 0 {
+		return fmt.Errorf("failed steps: %d", c.failed)
+	}
+	return nil
+}
+
+// checkKubelet checks the connection to the kubelet step by step, and
+// returns true if a summary was fetched.
+func (c *checker) checkKubelet() bool {
+	address := optKubelet.address
+	u, err := url.Parse(address)
+	if err == nil && (u.Scheme != "http" && u.Scheme != "https" || u.Host == "") {
+		err = fmt.Errorf("invalid kubelet address %q, expected http(s)://host:port", address)
+	}
+	if err != nil {
+		c.fail("address", err, "set --kubelet-address to https://<node>:10250, or http://<node>:10255 if the read-only port of the kubelet is enabled")
+		return false
+	}
+	c.pass("address", address)
+
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	if net.ParseIP(host) != nil {
+		c.pass("resolve", host+" is an IP address")
+	} else {
+		addrs, err := net.DefaultResolver.LookupHost(ctx, host)
+		if err != nil {
+			c.fail("resolve", err, "the host of --kubelet-address must resolve in the pod, use the IP of the node instead, e.g. from status.hostIP with the downward API")
+			return false
+		}
+		c.pass("resolve", fmt.Sprintf("%s resolves to %s", host, strings.Join(addrs, ", ")))
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, port), checkTimeout)
+	if err != nil {
+		hint := "check that the kubelet listens on port " + port + ", and that the pod can reach the node, e.g. with hostNetwork: true"
+		if isConnectionRefused(err) && port == "10255" {
+			hint = "the read-only port of the kubelet is disabled on many clusters, use https://<node>:10250 with --kubelet-token-file instead"
+		}
+		c.fail("connect", err, hint)
+		return false
+	}
+	conn.Close()
+	c.pass("connect", "connected to "+net.JoinHostPort(host, port))
+
+	if !c.checkTLS(u.Scheme, host, port) {
+		return false
+	}
+
+	client, err := optKubelet.client()
+	if err != nil {
+		c.fail("auth", err, "check that --kubelet-token-file is readable")
+		return false
+	}
+	summaryCtx, summaryCancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer summaryCancel()
+	summary, err := client.GetSummary(summaryCtx)
+	if body, _ := client.LastSummaryBody(); err != nil && body == nil {
+		c.fail("auth", err, requestHint(err))
+		return false
+	}
+	if optKubelet.config.TokenFile != "" {
+		c.pass("auth", "authenticated with the token in "+optKubelet.config.TokenFile)
+	} else {
+		c.pass("auth", "anonymous requests are allowed")
+	}
+	if err == nil && summary.Node.NodeName == "" {
+		err = fmt.Errorf("the summary has no node name")
+	}
+	if err != nil {
+		c.fail("summary", err, "--kubelet-address must be the kubelet itself, not a proxy or another service on the node")
+		return false
+	}
+	pvcs := 0
+	for _, pod := range summary.Pods {
+		for _, v := range pod.VolumeStats {
+			if v.PVCRef != nil {
+				pvcs++
+			}
+		}
+	}
+	c.pass("summary", fmt.Sprintf("node %s with %d pods and %d PVC volumes", summary.Node.NodeName, len(summary.Pods), pvcs))
+	return true
+}
+
+// checkTLS checks the TLS handshake with the kubelet if scheme is https, and
+// returns true on success.
+func (c *checker) checkTLS(scheme, host, port string) bool {
+	if scheme != "https" {
+		c.pass("tls", "plain HTTP, the summary is not encrypted")
+		return true
+	}
+	tlsConfig, err := optKubelet.config.TLSConfig()
+	if err != nil {
+		c.fail("tls", err, "--kubelet-ca-file must contain PEM encoded CA certificates")
+		return false
+	}
+	tlsConfig.ServerName = host
+	conn, err := tls.DialWithDialer(&net.Dialer{Timeout: checkTimeout}, "tcp", net.JoinHostPort(host, port), tlsConfig)
+	if err != nil {
+		// The errors are matched by their messages, newer Go versions wrap
+		// the x509 errors.
+		hint := "check that the kubelet serves HTTPS on port " + port
+		switch msg := err.Error(); {
+		case strings.Contains(msg, "certificate signed by unknown authority"):
+			hint = "the serving certificate of the kubelet is not signed by a trusted CA, pass the CA with --kubelet-ca-file, or --kubelet-insecure-skip-tls-verify for self-signed certificates"
+		case strings.Contains(msg, "certificate is valid for") || strings.Contains(msg, "doesn't contain any IP SANs"):
+			hint = "the serving certificate of the kubelet is not valid for " + host + ", use a host it is valid for in --kubelet-address, or --kubelet-insecure-skip-tls-verify"
+		case strings.Contains(msg, "certificate has expired"):
+			hint = "the serving certificate of the kubelet is expired, renew it, or use --kubelet-insecure-skip-tls-verify"
+		case strings.Contains(msg, "does not look like a TLS handshake"):
+			hint = "the kubelet serves plain HTTP on port " + port + ", use http:// in --kubelet-address"
+		}
+		c.fail("tls", err, hint)
+		return false
+	}
+	conn.Close()
+	if optKubelet.config.InsecureSkipTLSVerify {
+		c.warn("tls", "the serving certificate of the kubelet is not verified", "pass the CA of the kubelet with --kubelet-ca-file instead of --kubelet-insecure-skip-tls-verify")
+	} else {
+		c.pass("tls", "the serving certificate of the kubelet is valid")
+	}
+	return true
+}
+
+// requestHint returns a hint for a failed summary request.
+func requestHint(err error) string {
+	statusErr, ok := err.(*kubelet.StatusError)
+	if !ok {
+		return "the kubelet didn't answer, check that the scheme of --kubelet-address matches the port, and the kubelet logs"
+	}
+	switch statusErr.StatusCode {
+	case http.StatusUnauthorized:
+		if optKubelet.config.TokenFile == "" {
+			return "the kubelet requires authentication, pass a token with --kubelet-token-file, e.g. " + serviceAccountTokenFile
+		}
+		return "the kubelet rejected the token in " + optKubelet.config.TokenFile + ", check that it is valid and not expired"
+	case http.StatusForbidden:
+		return "the token may not read stats, grant its service account get on the nodes/stats resource with a ClusterRole"
+	case http.StatusBadRequest:
+		return "the kubelet may serve HTTPS on this port, use https:// in --kubelet-address"
+	case http.StatusNotFound:
+		return "--kubelet-address must be the kubelet, and its summary API must be enabled"
+	default:
+		return "check the kubelet logs"
+	}
+}
+
+// isConnectionRefused returns true if err is a refused connection.
+func isConnectionRefused(err error) bool {
+	opErr, ok := err.(*net.OpError)
+	if !ok {
+		return false
+	}
+	if sysErr, ok := opErr.Err.(*os.SyscallError); ok {
+		return sysErr.Err == syscall.ECONNREFUSED
+	}
+	return false
+}
+
+// checkCollectors gathers the metrics of each enabled collector and prints
+// how many series it has.
+func (c *checker) checkCollectors() {
+	client, err := optKubelet.client()
+	if err != nil {
+		c.fail("collectors", err, "check the kubelet flags")
+		return
+	}
+	set, deps, err := newCollectorSet(client)
+	if err != nil {
+		c.fail("collectors", err, "fix the flag in the error")
+		return
+	}
+	if deps.prober != nil {
+		deps.prober.ProbeAll()
+	}
+	help := map[string]string{}
+	for _, info := range availableCollectors {
+		help[info.name] = info.help
+	}
+	for _, name := range set.Names() {
+		step := "collector " + name
+		gatherer, err := set.Gatherer([]string{name}, nil)
+		if err != nil {
+			c.fail(step, err, "this is a bug")
+			continue
+		}
+		families, err := openmetrics.WithoutCreated(gatherer).Gather()
+		if err != nil {
+			c.fail(step, err, "the collector produced invalid metrics, check --metric-allowlist and --metric-denylist, and the logs")
+			continue
+		}
+		series := 0
+		for _, family := range families {
+			series += len(family.Metric)
+		}
+		if series == 0 {
+			c.warn(step, "no series", "the node may have nothing to collect, or the collector failed, see the logs above; it collects "+help[name])
+			continue
+		}
+		c.pass(step, fmt.Sprintf("%d series in %d families", series, len(families)))
+	}
+}
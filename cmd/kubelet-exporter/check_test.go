@@ -0,0 +1,248 @@
+package main
+
+import (
+	"bytes"
+	"encoding/pem"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+)
+
+// withKubeletFlags sets optKubelet to flags until the returned function is
+// called.
+func withKubeletFlags(flags kubeletFlags) func() {
+	saved := optKubelet
+	optKubelet = flags
+	return func() { optKubelet = saved }
+}
+
+// writeFile writes data to name in dir and returns its path.
+func writeFile(t *testing.T, dir, name string, data []byte) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestCheckKubelet(t *testing.T) {
+	dir, err := ioutil.TempDir("", "check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := writeFile(t, dir, "token", []byte("secret"))
+
+	respond := func(status int, body string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(status)
+			w.Write([]byte(body))
+		})
+	}
+	summary := `{"node": {"nodeName": "node-1"}, "pods": [{"podRef": {"name": "web-0", "namespace": "team-a"}, "volume": [{"name": "data", "pvcRef": {"name": "data", "namespace": "team-a"}}, {"name": "tmp"}]}]}`
+	// The kubelet authorizes requests with the token only.
+	kubeletHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Header.Get("Authorization") {
+		case "":
+			w.WriteHeader(http.StatusUnauthorized)
+		case "Bearer secret":
+			w.Write([]byte(summary))
+		default:
+			w.WriteHeader(http.StatusForbidden)
+		}
+	})
+	tlsServer := httptest.NewTLSServer(kubeletHandler)
+	defer tlsServer.Close()
+	caFile := writeFile(t, dir, "ca.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: tlsServer.Certificate().Raw}))
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		// tls serves handler with TLS, scheme overrides the scheme of
+		// the address of the server if set.
+		tls    bool
+		scheme string
+		config kubelet.ClientConfig
+		ok     bool
+		// want are substrings of the output, in order.
+		want []string
+	}{
+		{
+			name:    "summary",
+			handler: respond(http.StatusOK, summary),
+			ok:      true,
+			want: []string{
+				"[PASS] tls: plain HTTP",
+				"[PASS] auth: anonymous requests are allowed",
+				"[PASS] summary: node node-1 with 1 pods and 1 PVC volumes",
+			},
+		},
+		{
+			name:    "anonymous 401",
+			handler: kubeletHandler,
+			want:    []string{"[FAIL] auth: ", "hint: the kubelet requires authentication, pass a token with --kubelet-token-file"},
+		},
+		{
+			name:    "rejected token",
+			handler: respond(http.StatusUnauthorized, "Unauthorized"),
+			config:  kubelet.ClientConfig{TokenFile: tokenFile},
+			want:    []string{"[FAIL] auth: ", "hint: the kubelet rejected the token in " + tokenFile},
+		},
+		{
+			name:    "forbidden",
+			handler: respond(http.StatusForbidden, "Forbidden"),
+			config:  kubelet.ClientConfig{TokenFile: tokenFile},
+			want:    []string{"[FAIL] auth: ", "hint: the token may not read stats"},
+		},
+		{
+			// Go servers answer 400 to plain HTTP requests on an HTTPS
+			// port like the kubelet.
+			name:    "plain HTTP to the HTTPS port",
+			handler: kubeletHandler,
+			tls:     true,
+			scheme:  "http",
+			want:    []string{"[PASS] tls: plain HTTP", "[FAIL] auth: ", "400", "hint: the kubelet may serve HTTPS on this port, use https://"},
+		},
+		{
+			name:    "HTTPS to a plain HTTP port",
+			handler: kubeletHandler,
+			scheme:  "https",
+			config:  kubelet.ClientConfig{TokenFile: tokenFile},
+			want:    []string{"[FAIL] tls: ", "hint: the kubelet serves plain HTTP on port"},
+		},
+		{
+			name:    "unknown CA",
+			handler: kubeletHandler,
+			tls:     true,
+			config:  kubelet.ClientConfig{TokenFile: tokenFile},
+			want:    []string{"[FAIL] tls: ", "hint: the serving certificate of the kubelet is not signed by a trusted CA"},
+		},
+		{
+			name:    "CA file",
+			handler: kubeletHandler,
+			tls:     true,
+			config:  kubelet.ClientConfig{CAFile: caFile, TokenFile: tokenFile},
+			ok:      true,
+			want: []string{
+				"[PASS] tls: the serving certificate of the kubelet is valid",
+				"[PASS] auth: authenticated with the token in " + tokenFile,
+				"[PASS] summary: node node-1",
+			},
+		},
+		{
+			name:    "insecure skip TLS verify",
+			handler: kubeletHandler,
+			tls:     true,
+			config:  kubelet.ClientConfig{InsecureSkipTLSVerify: true, TokenFile: tokenFile},
+			ok:      true,
+			want:    []string{"[WARN] tls: the serving certificate of the kubelet is not verified", "[PASS] summary: node node-1"},
+		},
+		{
+			// The kubelet answered, so authentication passed, but with
+			// something else than a summary.
+			name:    "not a kubelet",
+			handler: respond(http.StatusOK, "<html>Welcome to nginx!</html>"),
+			want:    []string{"[PASS] auth: ", "[FAIL] summary: failed to parse stats summary", "hint: --kubelet-address must be the kubelet itself"},
+		},
+		{
+			name:    "summary without node",
+			handler: respond(http.StatusOK, `{"pods": []}`),
+			want:    []string{"[PASS] auth: ", "[FAIL] summary: the summary has no node name", "hint: --kubelet-address must be the kubelet itself"},
+		},
+	}
+	for _, test := range tests {
+		server := httptest.NewUnstartedServer(test.handler)
+		// Don't log the failed handshakes of the tests.
+		server.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+		if test.tls {
+			server.StartTLS()
+		} else {
+			server.Start()
+		}
+		address := server.URL
+		if test.scheme != "" {
+			address = test.scheme + "://" + server.Listener.Addr().String()
+		}
+		restore := withKubeletFlags(kubeletFlags{address: address, config: test.config})
+		var out bytes.Buffer
+		c := &checker{out: &out}
+		ok := c.checkKubelet()
+		restore()
+		server.Close()
+
+		if ok != test.ok {
+			t.Errorf("%s: got %v, want %v, output:\n%s", test.name, ok, test.ok, out.String())
+		}
+		if failed := c.failed > 0; failed == test.ok {
+			t.Errorf("%s: got %d failed steps, output:\n%s", test.name, c.failed, out.String())
+		}
+		rest := out.String()
+		for _, want := range test.want {
+			i := strings.Index(rest, want)
+			if i < 0 {
+				t.Errorf("%s: got output\n%s\nwant %q in order", test.name, out.String(), want)
+				break
+			}
+			rest = rest[i+len(want):]
+		}
+	}
+}
+
+func TestRequestHint(t *testing.T) {
+	defer withKubeletFlags(kubeletFlags{})()
+	tests := []struct {
+		err       error
+		tokenFile string
+		want      string
+	}{
+		{err: errors.New("EOF"), want: "the kubelet didn't answer"},
+		{err: &kubelet.StatusError{StatusCode: http.StatusUnauthorized}, want: "pass a token with --kubelet-token-file"},
+		{err: &kubelet.StatusError{StatusCode: http.StatusUnauthorized}, tokenFile: "/token", want: "the kubelet rejected the token in /token"},
+		{err: &kubelet.StatusError{StatusCode: http.StatusForbidden}, tokenFile: "/token", want: "nodes/stats"},
+		{err: &kubelet.StatusError{StatusCode: http.StatusBadRequest}, want: "use https:// in --kubelet-address"},
+		{err: &kubelet.StatusError{StatusCode: http.StatusNotFound}, want: "its summary API must be enabled"},
+		{err: &kubelet.StatusError{StatusCode: http.StatusInternalServerError}, want: "check the kubelet logs"},
+	}
+	for _, test := range tests {
+		optKubelet.config.TokenFile = test.tokenFile
+		if got := requestHint(test.err); !strings.Contains(got, test.want) {
+			t.Errorf("got hint %q for %v with token file %q, want %q in it", got, test.err, test.tokenFile, test.want)
+		}
+	}
+}
+
+func TestIsConnectionRefused(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	l.Close()
+	_, err = net.Dial("tcp", address)
+	if err == nil {
+		t.Fatalf("connected to closed listener %s", address)
+	}
+	if !isConnectionRefused(err) {
+		t.Errorf("got %v not refused, want refused", err)
+	}
+
+	_, err = net.Dial("tcp", "invalid:address:port")
+	if err == nil {
+		t.Fatal("connected to an invalid address")
+	}
+	if isConnectionRefused(err) {
+		t.Errorf("got %v refused, want not refused", err)
+	}
+	if isConnectionRefused(errors.New("connection refused")) {
+		t.Error("got an error which is not a net.OpError refused")
+	}
+}
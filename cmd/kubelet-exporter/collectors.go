@@ -0,0 +1,207 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"strings"
+
+	"github.com/cofyc/kubelet-exporter/pkg/collectors"
+	"github.com/cofyc/kubelet-exporter/pkg/cri"
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+	"github.com/cofyc/kubelet-exporter/pkg/node"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// collectorInfo describes a collector which can be enabled by flags.
+type collectorInfo struct {
+	name           string
+	help           string
+	defaultEnabled bool
+	// rankKeys are the values items can be ranked by when the collector
+	// exceeds its series budget. Collectors without rank keys have no budget.
+	rankKeys []string
+	factory  func(deps *collectorDeps, budget *collectors.Budget) collectors.Factory
+}
+
+// collectorDeps are what collectors are created from.
+type collectorDeps struct {
+	client *kubelet.Client
+	source *collectors.NodeSource
+	// cri builds summaries from the container runtime.
+	cri *cri.SummaryProvider
+	// csi is only set if stats of CSI volumes are collected from their
+	// plugins.
+	csi *collectors.CSIStatsSource
+	// statfs is only set if the statfs fallback is enabled.
+	statfs *collectors.StatfsFallback
+	// timestamps is only set if samples are timestamped with the time the
+	// kubelet took them.
+	timestamps *collectors.SampleTimestamps
+	// localVolumeDirs are the discovery directories of local volumes by
+	// storage class.
+	localVolumeDirs map[string]string
+	// localVolumes is nil if the PVs of local volumes are not looked up.
+	localVolumes *node.LocalVolumeCache
+	// nfsMounts is only set if the nfs collector is enabled.
+	nfsMounts *collectors.MountTimes
+	// orphans is only set if the orphans collector is enabled.
+	orphans *collectors.OrphanTracker
+	// prober is only set if the probe collector is enabled.
+	prober *collectors.VolumeProber
+	// podLogsDir is where the kubelet keeps container logs.
+	podLogsDir string
+	// logSizeThreshold is the size in bytes above which container logs are
+	// flagged.
+	logSizeThreshold int64
+}
+
+var availableCollectors = []collectorInfo{
+	{
+		name:           "volume",
+		help:           "PVC capacity and usage from kubelet stats summary",
+		defaultEnabled: true,
+		rankKeys:       collectors.VolumeStatsRankKeys,
+		factory: func(deps *collectorDeps, budget *collectors.Budget) collectors.Factory {
+			return func(summaries *collectors.Summaries, filter *collectors.Filter) prometheus.Collector {
+				return collectors.NewVolumeStatsCollector(summaries.Of(deps.client), filter, budget, deps.csi, deps.statfs, deps.timestamps)
+			}
+		},
+	},
+	{
+		name:     "containers",
+		help:     "container CPU, memory and writable layer usage from kubelet stats summary",
+		rankKeys: collectors.ContainerStatsRankKeys,
+		factory: func(deps *collectorDeps, budget *collectors.Budget) collectors.Factory {
+			return func(summaries *collectors.Summaries, filter *collectors.Filter) prometheus.Collector {
+				return collectors.NewContainerStatsCollector(summaries.Of(deps.client), "kubelet", filter, budget, deps.timestamps)
+			}
+		},
+	},
+	{
+		name:     "cri",
+		help:     "container CPU, memory and writable layer usage from the container runtime over the CRI, needs the CRI socket of the node",
+		rankKeys: collectors.ContainerStatsRankKeys,
+		factory: func(deps *collectorDeps, budget *collectors.Budget) collectors.Factory {
+			return func(summaries *collectors.Summaries, filter *collectors.Filter) prometheus.Collector {
+				return collectors.NewContainerStatsCollector(summaries.Of(deps.cri), "cri", filter, budget, deps.timestamps)
+			}
+		},
+	},
+	{
+		name: "diskstats",
+		help: "PVC block device I/O statistics from /proc/diskstats, needs the procfs and kubelet root dir of the node",
+		factory: func(deps *collectorDeps, budget *collectors.Budget) collectors.Factory {
+			return func(summaries *collectors.Summaries, filter *collectors.Filter) prometheus.Collector {
+				return collectors.NewVolumeDiskStatsCollector(summaries.Of(deps.client), deps.source, filter)
+			}
+		},
+	},
+	{
+		name: "nfs",
+		help: "NFS client statistics of NFS backed PVCs from /proc/self/mountstats, needs the procfs and kubelet root dir of the node",
+		factory: func(deps *collectorDeps, budget *collectors.Budget) collectors.Factory {
+			return func(summaries *collectors.Summaries, filter *collectors.Filter) prometheus.Collector {
+				return collectors.NewVolumeNFSStatsCollector(summaries.Of(deps.client), deps.source, deps.nfsMounts, filter)
+			}
+		},
+	},
+	{
+		name: "mountinfo",
+		help: "filesystem type, device and mount options of pod volumes from /proc/self/mountinfo, needs the procfs and kubelet root dir of the node",
+		factory: func(deps *collectorDeps, budget *collectors.Budget) collectors.Factory {
+			return func(summaries *collectors.Summaries, filter *collectors.Filter) prometheus.Collector {
+				return collectors.NewVolumeMountInfoCollector(summaries.Of(deps.client), deps.source, filter)
+			}
+		},
+	},
+	{
+		name: "orphans",
+		help: "volume directories of pods the kubelet no longer runs, needs the procfs and kubelet root dir of the node",
+		factory: func(deps *collectorDeps, budget *collectors.Budget) collectors.Factory {
+			return func(summaries *collectors.Summaries, filter *collectors.Filter) prometheus.Collector {
+				return collectors.NewOrphanedVolumesCollector(summaries.Of(deps.client), deps.source, deps.orphans, filter)
+			}
+		},
+	},
+	{
+		name: "local",
+		help: "capacity of the local volumes in the discovery directories of the local static provisioner, needs the procfs and discovery directories of the node",
+		factory: func(deps *collectorDeps, budget *collectors.Budget) collectors.Factory {
+			return func(summaries *collectors.Summaries, filter *collectors.Filter) prometheus.Collector {
+				return collectors.NewLocalVolumesCollector(summaries.Of(deps.client), deps.source, deps.localVolumeDirs, deps.localVolumes, filter)
+			}
+		},
+	},
+	{
+		name:     "logs",
+		help:     "container log file usage from the pod logs dir, needs the pod logs dir of the node",
+		rankKeys: collectors.ContainerLogsRankKeys,
+		factory: func(deps *collectorDeps, budget *collectors.Budget) collectors.Factory {
+			return func(summaries *collectors.Summaries, filter *collectors.Filter) prometheus.Collector {
+				return collectors.NewContainerLogsCollector(deps.podLogsDir, deps.logSizeThreshold, filter, budget)
+			}
+		},
+	},
+	{
+		name: "probe",
+		help: "periodic statfs and optional canary write probes of PVC mounts, needs the kubelet root dir of the node",
+		factory: func(deps *collectorDeps, budget *collectors.Budget) collectors.Factory {
+			return func(summaries *collectors.Summaries, filter *collectors.Filter) prometheus.Collector {
+				return collectors.NewVolumeProbeCollector(deps.prober, filter)
+			}
+		},
+	},
+}
+
+var (
+	optCollectorEnabled   = map[string]*bool{}
+	optCollectorDisabled  = map[string]*bool{}
+	optCollectorMaxSeries = map[string]*int{}
+	optCollectorRankBy    = map[string]*string{}
+)
+
+func init() {
+	for _, c := range availableCollectors {
+		optCollectorEnabled[c.name] = flag.Bool("collector."+c.name, c.defaultEnabled, fmt.Sprintf("enable the %s collector: %s", c.name, c.help))
+		optCollectorDisabled[c.name] = flag.Bool("no-collector."+c.name, false, fmt.Sprintf("disable the %s collector", c.name))
+		if len(c.rankKeys) > 0 {
+			optCollectorMaxSeries[c.name] = flag.Int("collector."+c.name+".max-series", 0, fmt.Sprintf("maximum number of series of the %s collector, the top consumers are kept and the rest is rolled into an %q bucket; 0 means unlimited", c.name, "__other__"))
+			optCollectorRankBy[c.name] = flag.String("collector."+c.name+".rank-by", c.rankKeys[0], fmt.Sprintf("value to rank by when the %s collector exceeds its max series, one of: %s", c.name, strings.Join(c.rankKeys, ", ")))
+		}
+	}
+}
+
+// collectorEnabled returns true if the named collector is enabled by flags.
+func collectorEnabled(name string) bool {
+	return *optCollectorEnabled[name] && !*optCollectorDisabled[name]
+}
+
+// enabledCollectors returns a set of the collectors enabled by flags.
+func enabledCollectors(deps *collectorDeps, filter *collectors.Filter) (*collectors.Set, error) {
+	set := collectors.NewSet(filter)
+	for _, c := range availableCollectors {
+		if !collectorEnabled(c.name) {
+			continue
+		}
+		budget, err := collectorBudget(c)
+		if err != nil {
+			return nil, err
+		}
+		set.Add(c.name, c.factory(deps, budget))
+	}
+	return set, nil
+}
+
+// collectorBudget returns the series budget of a collector set by flags.
+func collectorBudget(c collectorInfo) (*collectors.Budget, error) {
+	if len(c.rankKeys) == 0 || *optCollectorMaxSeries[c.name] <= 0 {
+		return nil, nil
+	}
+	rankBy := *optCollectorRankBy[c.name]
+	for _, key := range c.rankKeys {
+		if key == rankBy {
+			return &collectors.Budget{MaxSeries: *optCollectorMaxSeries[c.name], RankBy: rankBy}, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid --collector.%s.rank-by %q, must be one of: %s", c.name, rankBy, strings.Join(c.rankKeys, ", "))
+}
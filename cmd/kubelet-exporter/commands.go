@@ -0,0 +1,85 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+)
+
+// serviceAccountTokenFile is where the token of the service account of a pod
+// is mounted.
+const serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// commands are the subcommands of the exporter by name. They are run with
+// the arguments following their name instead of serving metrics.
+var commands = map[string]func(args []string) error{
+	"check":   runCheck,
+	"top":     runTop,
+	"volumes": runVolumes,
+}
+
+// runCommand runs the subcommand named by the first argument, if any. It
+// returns false if there is none.
+func runCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+	run, ok := commands[args[0]]
+	if !ok {
+		return false
+	}
+	if err := run(args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", args[0], err)
+		os.Exit(1)
+	}
+	return true
+}
+
+// newCommandFlagSet creates the flag set of a subcommand with a usage line.
+func newCommandFlagSet(name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintf(os.Stderr, "Usage: %s %s [flags]\n\n%s\n\nFlags:\n", os.Args[0], name, usage)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// kubeletFlags are the flags of the connection to the kubelet.
+type kubeletFlags struct {
+	address string
+	config  kubelet.ClientConfig
+}
+
+func (f *kubeletFlags) addFlags(fs *flag.FlagSet) {
+	fs.StringVar(&f.address, "kubelet-address", "http://localhost:10255", "address of kubelet")
+	fs.StringVar(&f.config.CAFile, "kubelet-ca-file", "", "file containing CA certificates to verify the serving certificate of the kubelet with, default the system roots")
+	fs.BoolVar(&f.config.InsecureSkipTLSVerify, "kubelet-insecure-skip-tls-verify", false, "don't verify the serving certificate of the kubelet, e.g. if it is self-signed")
+	fs.StringVar(&f.config.TokenFile, "kubelet-token-file", "", "file containing a bearer token to authenticate to the kubelet with, e.g. "+serviceAccountTokenFile+", read again every minute")
+}
+
+// client creates a client of the kubelet.
+func (f *kubeletFlags) client() (*kubelet.Client, error) {
+	return kubelet.NewClient(f.address, f.config)
+}
+
+// summarySource is where a subcommand gets stats summaries from.
+type summarySource struct {
+	kubeletFlags
+	summaryFile string
+}
+
+func (s *summarySource) addFlags(fs *flag.FlagSet) {
+	s.kubeletFlags.addFlags(fs)
+	fs.StringVar(&s.summaryFile, "summary-file", "", "file with a recorded stats summary to read instead of fetching it from --kubelet-address")
+}
+
+// provider returns the provider of the summaries.
+func (s *summarySource) provider() (kubelet.SummaryProvider, error) {
+	if s.summaryFile != "" {
+		return kubelet.NewFileProvider(s.summaryFile), nil
+	}
+	return s.client()
+}
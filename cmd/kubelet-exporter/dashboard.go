@@ -0,0 +1,253 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html/template"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/api"
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+	"github.com/golang/glog"
+)
+
+// dashboardTemplate renders the status of the node and its volumes.
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<html>
+	<head>
+		<title>Kube Metrics Server</title>
+		<style>
+			body { font-family: sans-serif; margin: 2em; }
+			table { border-collapse: collapse; }
+			th, td { padding: 0.3em 0.8em; text-align: left; border-bottom: 1px solid #ddd; }
+			td.number { text-align: right; }
+			.bar { width: 10em; height: 0.9em; background: #eee; display: inline-block; vertical-align: middle; }
+			.bar div { height: 100%; background: #4a8; }
+			.bar div.warning { background: #e93; }
+			.bar div.critical { background: #d33; }
+			.error { color: #d33; }
+		</style>
+	</head>
+	<body>
+		<h1>{{if .NodeName}}{{.NodeName}}{{else}}Kube Metrics{{end}}</h1>
+		<table>
+			<tr><th>Kubelet</th><td>{{.Status.Address}}{{if .Status.KubeletVersion}} ({{.Status.KubeletVersion}}){{end}}</td></tr>
+			<tr><th>Reachable</th><td>{{if .Error}}<span class="error">no: {{.Error}}</span>{{else}}yes{{end}}</td></tr>
+			<tr><th>Last successful fetch</th><td>{{with .Status.LastSuccessTime}}{{.Format "2006-01-02 15:04:05 MST"}}{{else}}never{{end}}</td></tr>
+			{{with .Status.LastError}}<tr><th>Last error</th><td class="error">{{.}} at {{$.Status.LastErrorTime.Format "2006-01-02 15:04:05 MST"}}</td></tr>{{end}}
+		</table>
+		<h2>Volumes</h2>
+		{{if .Volumes}}
+		<table>
+			<tr>{{range .Columns}}<th>{{if .Key}}<a href="?sort={{.Sort}}">{{.Title}}</a>{{else}}{{.Title}}{{end}}</th>{{end}}</tr>
+			{{range .Volumes}}
+			<tr>
+				<td>{{.Namespace}}</td>
+				<td>{{.PersistentVolumeClaim}}</td>
+				<td>{{.PodNames}}</td>
+				<td class="number">{{.Capacity}}</td>
+				<td class="number">{{.Used}}</td>
+				<td><div class="bar"><div class="{{.UsedLevel}}" style="width: {{.UsedPercent}}%"></div></div> {{.UsedPercent}}%</td>
+				<td><div class="bar"><div class="{{.InodesLevel}}" style="width: {{.InodesPercent}}%"></div></div> {{.InodesPercent}}%</td>
+				<td class="number">{{.Age}}</td>
+				<td class="number">{{.Forecast}}</td>
+			</tr>
+			{{end}}
+		</table>
+		{{else}}
+		<p>No PVCs are used by pods on this node.</p>
+		{{end}}
+		<h2>Endpoints</h2>
+		<ul>
+			{{range .Links}}<li><a href="{{.Path}}">{{.Title}}</a></li>
+			{{end}}
+		</ul>
+	</body>
+</html>`))
+
+// dashboardColumn is a column of the volume table, sortable if it has a key.
+type dashboardColumn struct {
+	Title string
+	Key   string
+	// Sort is the sort parameter of the link of the column.
+	Sort string
+}
+
+// dashboardColumns are the columns of the volume table. Numeric columns sort
+// in descending order first.
+var dashboardColumns = []dashboardColumn{
+	{Title: "Namespace", Key: "namespace"},
+	{Title: "PVC", Key: "persistentVolumeClaim"},
+	{Title: "Pods"},
+	{Title: "Capacity", Key: "-capacityBytes"},
+	{Title: "Used", Key: "-usedBytes"},
+	{Title: "Used %", Key: "-usedRatio"},
+	{Title: "Inodes used %", Key: "-inodesUsedRatio"},
+	{Title: "Age", Key: "-ageSeconds"},
+	{Title: "Forecast full in"},
+}
+
+// dashboardLink is a link to another endpoint.
+type dashboardLink struct {
+	Title string
+	Path  string
+}
+
+// dashboardVolume is a row of the volume table.
+type dashboardVolume struct {
+	api.Volume
+	PodNames, Capacity, Used, Age, Forecast string
+	UsedPercent, InodesPercent              int
+	UsedLevel, InodesLevel                  string
+}
+
+// dashboardHandler serves a page with the status of the kubelet and the
+// usage of the PVCs on the node, sortable by the sort query parameter. If
+// tenants are configured, requests must authenticate and only see PVCs of
+// their namespaces.
+func dashboardHandler(config *serverConfig, links []dashboardLink) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" {
+			http.NotFound(w, r)
+			return
+		}
+		namespaces, ok := authorize(w, r, config.authn, config.resolver)
+		if !ok {
+			return
+		}
+		sortKey := r.URL.Query().Get("sort")
+		data := struct {
+			NodeName string
+			Status   kubelet.Status
+			Error    string
+			Columns  []dashboardColumn
+			Volumes  []dashboardVolume
+			Links    []dashboardLink
+		}{
+			NodeName: optNodeName,
+			Links:    links,
+		}
+		for _, c := range dashboardColumns {
+			if c.Key != "" {
+				c.Sort = c.Key
+				if c.Key == sortKey {
+					c.Sort = toggleSort(c.Key)
+				}
+			}
+			data.Columns = append(data.Columns, c)
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+		defer cancel()
+		summary, err := config.client.GetSummary(ctx)
+		data.Status = config.client.Status()
+		if err != nil {
+			data.Error = err.Error()
+		} else {
+			if summary.Node.NodeName != "" {
+				data.NodeName = summary.Node.NodeName
+			}
+			volumes := api.Volumes(summary, namespaces, time.Now())
+			if err := api.SortVolumes(volumes, sortKey); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			for _, v := range volumes {
+				data.Volumes = append(data.Volumes, newDashboardVolume(v, config.history))
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := dashboardTemplate.Execute(&buf, data); err != nil {
+			glog.Errorf("failed to render dashboard: %v", err)
+			http.Error(w, "failed to render dashboard", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Write(buf.Bytes())
+	}
+}
+
+func newDashboardVolume(v api.Volume, history *api.VolumeHistory) dashboardVolume {
+	row := dashboardVolume{
+		Volume:        v,
+		PodNames:      strings.Join(v.Pods, ", "),
+		Capacity:      formatBytes(v.CapacityBytes),
+		Used:          formatBytes(v.UsedBytes),
+		UsedPercent:   percent(v.UsedRatio()),
+		InodesPercent: percent(v.InodesUsedRatio()),
+		Age:           formatDuration(time.Duration(v.AgeSeconds) * time.Second),
+		Forecast:      "-",
+	}
+	row.UsedLevel = usageLevel(row.UsedPercent)
+	row.InodesLevel = usageLevel(row.InodesPercent)
+	if fill, ok := history.Forecast(&v); ok {
+		row.Forecast = formatDuration(fill)
+	}
+	return row
+}
+
+// percent converts a ratio to a percentage of at most 100.
+func percent(ratio float64) int {
+	if ratio > 1 {
+		ratio = 1
+	}
+	return int(ratio*100 + 0.5)
+}
+
+// usageLevel returns the class of usage bars by percentage.
+func usageLevel(percent int) string {
+	switch {
+	case percent >= 90:
+		return "critical"
+	case percent >= 75:
+		return "warning"
+	default:
+		return ""
+	}
+}
+
+// toggleSort reverses the order of a sort key.
+func toggleSort(key string) string {
+	if strings.HasPrefix(key, "-") {
+		return strings.TrimPrefix(key, "-")
+	}
+	return "-" + key
+}
+
+// formatBytes formats bytes with binary prefixes, e.g. 1.5 GiB.
+func formatBytes(b *uint64) string {
+	if b == nil {
+		return "-"
+	}
+	const unit = 1024
+	if *b < unit {
+		return fmt.Sprintf("%d B", *b)
+	}
+	div, exp := uint64(unit), 0
+	for n := *b / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(*b)/float64(div), "KMGTPE"[exp])
+}
+
+// formatDuration formats a duration with its two largest units, e.g. 3d4h.
+func formatDuration(d time.Duration) string {
+	if d < time.Minute {
+		return fmt.Sprintf("%ds", int(d.Seconds()))
+	}
+	days := int(d / (24 * time.Hour))
+	hours := int(d % (24 * time.Hour) / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, minutes)
+	default:
+		return fmt.Sprintf("%dm", minutes)
+	}
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/pprof"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/auth"
+	"github.com/cofyc/kubelet-exporter/pkg/collectors"
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+	"github.com/golang/glog"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+const (
+	debugPath               = "/debug/"
+	debugSummaryPath        = "/debug/summary"
+	debugSummaryDecodedPath = "/debug/summary/decoded"
+	debugVolumesPath        = "/debug/volumes"
+	debugPprofPath          = "/debug/pprof/"
+)
+
+// installDebugHandlers installs the debug handlers on mux. Only tenants
+// allowed to debug may access them.
+func installDebugHandlers(mux *http.ServeMux, client *kubelet.Client, authn *auth.Authenticator) {
+	handle := func(path string, h http.HandlerFunc) {
+		mux.Handle(path, requireDebug(authn, h))
+	}
+	handle(debugPath, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != debugPath {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`<html>
+	<head>
+		<title>Kube Metrics Server</title>
+	</head>
+	<body>
+		<h1>Debug</h1>
+		<ul>
+			<li><a href='` + debugSummaryPath + `'>raw summary</a></li>
+			<li><a href='` + debugSummaryDecodedPath + `'>decoded summary</a></li>
+			<li><a href='` + debugVolumesPath + `'>volumes</a></li>
+			<li><a href='` + debugPprofPath + `'>pprof</a></li>
+		</ul>
+	</body>
+</html>`))
+	})
+	handle(debugSummaryPath, debugSummaryHandler(client))
+	handle(debugSummaryDecodedPath, debugSummaryDecodedHandler(client))
+	handle(debugVolumesPath, debugVolumesHandler(client))
+	handle(debugPprofPath, pprof.Index)
+	handle(debugPprofPath+"cmdline", pprof.Cmdline)
+	handle(debugPprofPath+"profile", pprof.Profile)
+	handle(debugPprofPath+"symbol", pprof.Symbol)
+	handle(debugPprofPath+"trace", pprof.Trace)
+}
+
+func requireDebug(authn *auth.Authenticator, h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant, ok := authn.Authenticate(r)
+		if !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="kubelet-exporter"`)
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if !tenant.Debug {
+			http.Error(w, "Forbidden", http.StatusForbidden)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// debugSummaryHandler serves the last summary body received from the kubelet
+// as is.
+func debugSummaryHandler(client *kubelet.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, received := client.LastSummaryBody()
+		if body == nil {
+			http.Error(w, "no summary received from kubelet yet", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Last-Modified", received.UTC().Format(http.TimeFormat))
+		w.Write(body)
+	}
+}
+
+// debugSummaryDecodedHandler serves the last summary body received from the
+// kubelet as the exporter decoded it.
+func debugSummaryDecodedHandler(client *kubelet.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		summary, ok := lastSummary(w, client)
+		if !ok {
+			return
+		}
+		writeJSON(w, summary)
+	}
+}
+
+// debugVolumesHandler explains for every volume in the last summary whether
+// it was collected.
+func debugVolumesHandler(client *kubelet.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		summary, ok := lastSummary(w, client)
+		if !ok {
+			return
+		}
+		writeJSON(w, collectors.ExplainVolumeStats(summary))
+	}
+}
+
+// lastSummary decodes the last summary received from the kubelet, fetching
+// one if none was received yet. It writes an error to w on failure.
+func lastSummary(w http.ResponseWriter, client *kubelet.Client) (*v1alpha1.Summary, bool) {
+	body, _ := client.LastSummaryBody()
+	if body == nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		if _, err := client.GetSummary(ctx); err != nil {
+			http.Error(w, fmt.Sprintf("failed to get summary: %v", err), http.StatusBadGateway)
+			return nil, false
+		}
+		body, _ = client.LastSummaryBody()
+	}
+	summary := &v1alpha1.Summary{}
+	if err := json.Unmarshal(body, summary); err != nil {
+		http.Error(w, fmt.Sprintf("failed to decode last summary: %v", err), http.StatusBadGateway)
+		return nil, false
+	}
+	return summary, true
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		glog.Errorf("failed to encode response: %v", err)
+	}
+}
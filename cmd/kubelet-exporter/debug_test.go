@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/cofyc/kubelet-exporter/pkg/auth"
+)
+
+func TestRequireDebug(t *testing.T) {
+	authn := auth.NewAuthenticator(&auth.Config{Tenants: []auth.Tenant{
+		{Name: "prometheus", BearerToken: "scrape"},
+		{Name: "admin", BearerToken: "debug", Debug: true},
+	}})
+	served := false
+	handler := requireDebug(authn, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served = true
+	}))
+	tests := []struct {
+		name   string
+		header string
+		status int
+	}{
+		{name: "anonymous", status: http.StatusUnauthorized},
+		{name: "unknown token", header: "Bearer wrong", status: http.StatusUnauthorized},
+		{name: "non-debug tenant", header: "Bearer scrape", status: http.StatusForbidden},
+		{name: "debug tenant", header: "Bearer debug", status: http.StatusOK},
+	}
+	for _, test := range tests {
+		served = false
+		r := httptest.NewRequest("GET", debugSummaryPath, nil)
+		if test.header != "" {
+			r.Header.Set("Authorization", test.header)
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if w.Code != test.status {
+			t.Errorf("%s: got status %d, want %d", test.name, w.Code, test.status)
+		}
+		if want := test.status == http.StatusOK; served != want {
+			t.Errorf("%s: got handler served %v, want %v", test.name, served, want)
+		}
+	}
+}
+
+func TestDebugHandlersRequireDebug(t *testing.T) {
+	authn := auth.NewAuthenticator(&auth.Config{Tenants: []auth.Tenant{
+		{Name: "prometheus", BearerToken: "scrape"},
+	}})
+	mux := http.NewServeMux()
+	installDebugHandlers(mux, nil, authn)
+	for _, path := range []string{debugPath, debugSummaryPath, debugSummaryDecodedPath, debugVolumesPath, debugPprofPath, debugPprofPath + "cmdline"} {
+		for header, status := range map[string]int{"": http.StatusUnauthorized, "Bearer scrape": http.StatusForbidden} {
+			r := httptest.NewRequest("GET", path, nil)
+			if header != "" {
+				r.Header.Set("Authorization", header)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, r)
+			if w.Code != status {
+				t.Errorf("got status %d for %s with %q, want %d", w.Code, path, header, status)
+			}
+		}
+	}
+}
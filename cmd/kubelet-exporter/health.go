@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/collectors"
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+	"github.com/golang/glog"
+)
+
+// readyzHandler reports ready if a summary was fetched successfully within
+// window. If the last success is older, e.g. because nothing scraped us
+// lately, it fetches a summary itself before answering.
+func readyzHandler(client *kubelet.Client, window time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := client.Status()
+		if status.LastSuccessTime == nil || time.Since(*status.LastSuccessTime) > window {
+			ctx, cancel := context.WithTimeout(r.Context(), 10*time.Second)
+			defer cancel()
+			if _, err := client.GetSummary(ctx); err != nil {
+				glog.V(2).Infof("readiness check failed: %v", err)
+				http.Error(w, fmt.Sprintf("no summary fetched from kubelet within %v: %v", window, err), http.StatusServiceUnavailable)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}
+}
+
+// livezHandler reports not alive if a collector has been collecting for
+// longer than threshold, it is wedged then, e.g. on a hung filesystem.
+func livezHandler(set *collectors.Set, threshold time.Duration) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if name, start, ok := set.OldestCollect(); ok {
+			if d := time.Since(start); d > threshold {
+				http.Error(w, fmt.Sprintf("%s collector collecting for %v", name, d), http.StatusInternalServerError)
+				return
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte("ok"))
+	}
+}
+
+// statusHandler serves the kubelet client status as JSON.
+func statusHandler(client *kubelet.Client) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeJSON(w, client.Status())
+	}
+}
@@ -2,68 +2,398 @@
 package main
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
+	"io/ioutil"
 	"log"
 	"net/http"
-	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
 
+	"github.com/cofyc/kubelet-exporter/pkg/api"
+	"github.com/cofyc/kubelet-exporter/pkg/auth"
 	"github.com/cofyc/kubelet-exporter/pkg/collectors"
+	"github.com/cofyc/kubelet-exporter/pkg/cri"
+	"github.com/cofyc/kubelet-exporter/pkg/csi"
+	"github.com/cofyc/kubelet-exporter/pkg/kube"
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+	"github.com/cofyc/kubelet-exporter/pkg/node"
+	"github.com/cofyc/kubelet-exporter/pkg/openmetrics"
+	"github.com/cofyc/kubelet-exporter/pkg/otlp"
+	"github.com/cofyc/kubelet-exporter/pkg/remotewrite"
+	"github.com/cofyc/kubelet-exporter/pkg/sink"
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/prometheus/common/model"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/wait"
 )
 
 const (
 	metricsPath = "/metrics"
+	influxPath  = "/metrics/influx"
 	healthzPath = "/healthz"
+	readyzPath  = "/readyz"
+	livezPath   = "/livez"
+	statusPath  = "/status"
 )
 
-func metricsServer(registry prometheus.Gatherer, port int) {
+// serverConfig holds everything served by metricsServer.
+type serverConfig struct {
+	set       *collectors.Set
+	client    *kubelet.Client
+	authn     *auth.Authenticator
+	resolver  *auth.NamespaceResolver
+	tlsConfig *tls.Config
+	debug     bool
+	// history is nil if fill times of volumes are not forecast.
+	history *api.VolumeHistory
+}
+
+// metricsHandler serves the metrics of the collectors with the handler
+// handlerFor returns for their gatherer. The collect[] and namespace query
+// parameters select collectors and namespaces. If tenants are configured,
+// requests must authenticate and only see series of their namespaces.
+func metricsHandler(set *collectors.Set, authn *auth.Authenticator, resolver *auth.NamespaceResolver, handlerFor func(prometheus.Gatherer) http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Authenticate first, unauthenticated clients must not learn the
+		// names of the collectors from errors.
+		allowed, ok := authorize(w, r, authn, resolver)
+		if !ok {
+			return
+		}
+		query := r.URL.Query()
+		gatherer, err := set.Gatherer(query["collect[]"], query["namespace"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		gatherer = auth.NewNamespaceGatherer(gatherer, allowed)
+		handlerFor(gatherer).ServeHTTP(w, r)
+	})
+}
+
+// authorize authenticates r if tenants are configured, and returns the
+// namespaces the tenant may see, or nil if it may see everything. It writes
+// an error to w on failure.
+func authorize(w http.ResponseWriter, r *http.Request, authn *auth.Authenticator, resolver *auth.NamespaceResolver) (map[string]bool, bool) {
+	if authn == nil {
+		return nil, true
+	}
+	tenant, ok := authn.Authenticate(r)
+	if !ok {
+		w.Header().Set("WWW-Authenticate", `Basic realm="kubelet-exporter"`)
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 30*time.Second)
+	defer cancel()
+	allowed, err := resolver.Resolve(ctx, tenant)
+	if err != nil {
+		glog.Errorf("failed to resolve namespaces of tenant %s: %v", tenant.Name, err)
+		http.Error(w, "failed to resolve allowed namespaces", http.StatusInternalServerError)
+		return nil, false
+	}
+	return allowed, true
+}
+
+// expositionHandler serves the metrics of gatherer in the OpenMetrics text
+// format if the client accepts it, in the Prometheus text format otherwise.
+func expositionHandler(gatherer prometheus.Gatherer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if openmetrics.Accepts(r) {
+			openmetrics.Handler(gatherer, collectors.Unit).ServeHTTP(w, r)
+			return
+		}
+		promhttp.HandlerFor(openmetrics.WithoutCreated(gatherer), promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	})
+}
+
+func metricsServer(config *serverConfig, port int) {
 	// Address to listen on for web interface and telemetry
 	listenAddress := fmt.Sprintf(":%d", port)
 
 	glog.Infof("Starting metrics server: %s", listenAddress)
+	// Don't use http.DefaultServeMux, net/http/pprof installs itself there.
+	mux := http.NewServeMux()
 	// Add metricsPath
-	http.Handle(metricsPath, promhttp.HandlerFor(registry, promhttp.HandlerOpts{}))
+	mux.Handle(metricsPath, metricsHandler(config.set, config.authn, config.resolver, expositionHandler))
+	// Add influxPath
+	mux.Handle(influxPath, metricsHandler(config.set, config.authn, config.resolver, func(gatherer prometheus.Gatherer) http.Handler {
+		return sink.Handler(openmetrics.WithoutCreated(gatherer), sink.Influx{})
+	}))
 	// Add healthzPath
-	http.HandleFunc(healthzPath, func(w http.ResponseWriter, r *http.Request) {
+	mux.HandleFunc(healthzPath, func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(200)
 		w.Write([]byte("ok"))
 	})
-	// Add index
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		w.Write([]byte(`<html>
-	<head>
-		<title>Kube Metrics Server</title>
-	</head>
-	<body>
-		<h1>Kube Metrics</h1>
-		<ul>
-			<li><a href='` + metricsPath + `'>metrics</a></li>
-			<li><a href='` + healthzPath + `'>healthz</a></li>
-		</ul>
-	</body>
-</html>`))
-	})
-	log.Fatal(http.ListenAndServe(listenAddress, nil))
+	// Add readyzPath, livezPath and statusPath
+	mux.HandleFunc(readyzPath, readyzHandler(config.client, optReadinessWindow))
+	mux.HandleFunc(livezPath, livezHandler(config.set, optLivenessThreshold))
+	mux.HandleFunc(statusPath, statusHandler(config.client))
+	// Add apiVolumesPath and apiPodsPath
+	mux.HandleFunc(apiVolumesPath, apiVolumesHandler(config.client, config.authn, config.resolver))
+	mux.HandleFunc(apiPodsPath, apiPodsHandler(config.client, config.authn, config.resolver))
+	links := []dashboardLink{
+		{Title: "metrics", Path: metricsPath},
+		{Title: "metrics in InfluxDB line protocol", Path: influxPath},
+		{Title: "healthz", Path: healthzPath},
+		{Title: "readyz", Path: readyzPath},
+		{Title: "livez", Path: livezPath},
+		{Title: "status", Path: statusPath},
+		{Title: "volumes", Path: apiVolumesPath},
+		{Title: "pods", Path: apiPodsPath},
+	}
+	// Add debugPath
+	if config.debug {
+		installDebugHandlers(mux, config.client, config.authn)
+		links = append(links, dashboardLink{Title: "debug", Path: debugPath})
+	}
+	// Add dashboard
+	mux.HandleFunc("/", dashboardHandler(config, links))
+	if config.tlsConfig != nil {
+		server := &http.Server{Addr: listenAddress, Handler: mux, TLSConfig: config.tlsConfig}
+		log.Fatal(server.ListenAndServeTLS(optTLSCertFile, optTLSPrivateKeyFile))
+	}
+	log.Fatal(http.ListenAndServe(listenAddress, mux))
+}
+
+// serverTLSConfig returns the TLS configuration of the metrics server, or nil
+// if it serves plain HTTP.
+func serverTLSConfig() (*tls.Config, error) {
+	if optTLSCertFile == "" && optTLSPrivateKeyFile == "" {
+		if optClientCAFile != "" {
+			return nil, fmt.Errorf("--client-ca-file requires --tls-cert-file and --tls-private-key-file")
+		}
+		return nil, nil
+	}
+	if optTLSCertFile == "" || optTLSPrivateKeyFile == "" {
+		return nil, fmt.Errorf("--tls-cert-file and --tls-private-key-file must be specified together")
+	}
+	tlsConfig := &tls.Config{}
+	if optClientCAFile != "" {
+		caData, err := ioutil.ReadFile(optClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("no certificates found in %s", optClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	}
+	return tlsConfig, nil
 }
 
 var (
-	optHelp           bool
-	optPort           int
-	optKubeletAddress string
+	optHelp                bool
+	optPort                int
+	optKubelet             kubeletFlags
+	optAuthConfig          string
+	optTLSCertFile         string
+	optTLSPrivateKeyFile   string
+	optClientCAFile        string
+	optReadinessWindow     time.Duration
+	optLivenessThreshold   time.Duration
+	optDebugHandlers       bool
+	optMetricAllowlist     string
+	optMetricDenylist      string
+	optProcfs              string
+	optKubeletRootDir      string
+	optAPIServerPVCs       bool
+	optProbeInterval       time.Duration
+	optProbeTimeout        time.Duration
+	optProbeCanary         bool
+	optCRISocket           string
+	optCSIVolumeStats      bool
+	optCSIPluginSockets    string
+	optStatfsFallback      bool
+	optSampleTimestamps    bool
+	optSampleWindow        time.Duration
+	optLocalVolumeDirs     string
+	optNodeName            string
+	optOTLPEndpoint        string
+	optOTLPInterval        time.Duration
+	optRemoteWriteURL      string
+	optRemoteWriteInterval time.Duration
+	optRemoteWriteLabels   string
+	optRemoteWriteQueue    int
+	optStatsDAddress       string
+	optStatsDInterval      time.Duration
+	optForecastWindow      time.Duration
+	optPodLogsDir          string
+	optLogSizeThreshold    string
+	optOrphanGracePeriod   time.Duration
 )
 
 func init() {
 	flag.BoolVar(&optHelp, "help", false, "print help info and exit")
 	flag.IntVar(&optPort, "port", 9859, "port to expose metrics on")
-	flag.StringVar(&optKubeletAddress, "kubelet-address", "http://localhost:10255", "address of kubelet")
+	optKubelet.addFlags(flag.CommandLine)
+	flag.StringVar(&optProcfs, "procfs", "/proc", "procfs mountpoint")
+	flag.StringVar(&optKubeletRootDir, "kubelet-root-dir", node.DefaultKubeletRootDir, "root directory of kubelet, pod volumes must be visible under it as on the node")
+	flag.BoolVar(&optAPIServerPVCs, "apiserver-pvc-lookup", false, "look up which PVC a PV is bound to from the API server, needs permission to list PVCs")
+	flag.DurationVar(&optProbeInterval, "probe-interval", time.Minute, "interval between probes of PVC mounts by the probe collector")
+	flag.DurationVar(&optProbeTimeout, "probe-timeout", 10*time.Second, "time after which a probe of a PVC mount fails")
+	flag.BoolVar(&optProbeCanary, "probe-canary", false, "probe PVC mounts by writing, syncing and removing a tiny "+`".kubelet-exporter-canary"`+" file in addition to statfs")
+	flag.StringVar(&optCRISocket, "cri-socket", cri.DefaultSocket, "CRI socket of the container runtime, e.g. /var/run/crio/crio.sock for CRI-O")
+	flag.BoolVar(&optCSIVolumeStats, "csi-volume-stats", false, "collect stats of CSI volumes by calling NodeGetVolumeStats of their node plugins instead of from the kubelet")
+	flag.StringVar(&optCSIPluginSockets, "csi-plugin-sockets", "", "glob of the sockets of CSI node plugins, default <kubelet-root-dir>/plugins/*/csi.sock")
+	flag.BoolVar(&optStatfsFallback, "volume-stats-statfs-fallback", false, "statfs the mounts of PVCs the kubelet has no stats of, needs --apiserver-pvc-lookup; this adds a source label to every kubelet_volume_stats_* series, which breaks dashboards and recording rules matching on their labels")
+	flag.BoolVar(&optSampleTimestamps, "kubelet-sample-timestamps", false, "timestamp volume and container stats with the time the kubelet took them instead of the scrape time")
+	flag.DurationVar(&optSampleWindow, "kubelet-sample-timestamp-window", 5*time.Minute, "samples older than this are dropped with --kubelet-sample-timestamps")
+	flag.DurationVar(&optOrphanGracePeriod, "orphaned-volume-grace-period", 5*time.Minute, "time a pod must be missing from the summary before the orphans collector reports its volume directories, pods are set up before the kubelet reports them")
+	flag.StringVar(&optLocalVolumeDirs, "local-volume-dirs", "", "comma separated <storage-class>=<discovery-dir> pairs of the local static provisioner, e.g. local-ssd=/mnt/disks")
+	flag.StringVar(&optNodeName, "node-name", "", "name of the node, if set, the PVs of local volumes are looked up from the API server by the hostname label of the node, which needs permission to get the node and list PVs")
+	flag.StringVar(&optOTLPEndpoint, "otlp-endpoint", "", "OTLP/HTTP endpoint of an OpenTelemetry collector to push metrics to, e.g. http://otel-collector:4318")
+	flag.DurationVar(&optOTLPInterval, "otlp-interval", time.Minute, "interval between pushes to --otlp-endpoint")
+	flag.StringVar(&optRemoteWriteURL, "remote-write-url", "", "Prometheus remote write endpoint to push metrics to, e.g. http://prometheus:9090/api/v1/write")
+	flag.DurationVar(&optRemoteWriteInterval, "remote-write-interval", time.Minute, "interval between gathers of metrics for --remote-write-url")
+	flag.StringVar(&optRemoteWriteLabels, "remote-write-external-labels", "", "comma separated <name>=<value> labels added to all series written to --remote-write-url, e.g. cluster=edge-1")
+	flag.IntVar(&optRemoteWriteQueue, "remote-write-queue-size", 60, "maximum number of gathers queued for --remote-write-url, the oldest is dropped when full")
+	flag.StringVar(&optStatsDAddress, "statsd-address", "", "UDP address of a StatsD or DogStatsD server to push metrics to with tags, e.g. localhost:8125")
+	flag.DurationVar(&optStatsDInterval, "statsd-interval", 10*time.Second, "interval between pushes to --statsd-address")
+	flag.DurationVar(&optForecastWindow, "forecast-window", 0, "window of the usage history PVC fill times are forecast from on the dashboard, e.g. 6h, which fetches a summary every minute, 0 disables forecasts")
+	flag.StringVar(&optPodLogsDir, "pod-logs-dir", node.DefaultPodLogsDir, "directory of container logs of pods")
+	flag.StringVar(&optLogSizeThreshold, "container-log-size-threshold", "1Gi", "quantity of log files of a container above which the logs collector flags it")
+	flag.StringVar(&optAuthConfig, "auth-config", "", "file mapping authenticated tenants to the namespaces they may see; if empty, metrics are served unauthenticated")
+	flag.StringVar(&optTLSCertFile, "tls-cert-file", "", "file containing the x509 certificate to serve HTTPS with")
+	flag.StringVar(&optTLSPrivateKeyFile, "tls-private-key-file", "", "file containing the x509 private key matching --tls-cert-file")
+	flag.StringVar(&optClientCAFile, "client-ca-file", "", "file containing CA certificates to verify client certificates with")
+	flag.DurationVar(&optReadinessWindow, "readiness-window", 2*time.Minute, "report not ready if no summary was fetched from kubelet successfully within this window")
+	flag.StringVar(&optMetricAllowlist, "metric-allowlist", "", "regexp of metric families to collect, matched against the whole name")
+	flag.StringVar(&optMetricDenylist, "metric-denylist", "", "regexp of metric families not to collect, matched against the whole name")
+	flag.BoolVar(&optDebugHandlers, "enable-debug-handlers", false, "serve debug handlers under "+debugPath+" to tenants of --auth-config allowed to debug")
+	flag.DurationVar(&optLivenessThreshold, "liveness-threshold", 5*time.Minute, "report not alive if a collector has been collecting for longer than this")
+}
+
+// parseLocalVolumeDirs parses the <storage-class>=<discovery-dir> pairs of
+// --local-volume-dirs.
+func parseLocalVolumeDirs(s string) (map[string]string, error) {
+	dirs := map[string]string{}
+	if s == "" {
+		return dirs, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("%q is no <storage-class>=<discovery-dir> pair", pair)
+		}
+		dirs[parts[0]] = parts[1]
+	}
+	return dirs, nil
+}
+
+// parseExternalLabels parses the <name>=<value> pairs of
+// --remote-write-external-labels.
+func parseExternalLabels(s string) (map[string]string, error) {
+	labels := map[string]string{}
+	if s == "" {
+		return labels, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 || !model.LabelName(parts[0]).IsValid() || parts[1] == "" {
+			return nil, fmt.Errorf("%q is no <name>=<value> pair", pair)
+		}
+		labels[parts[0]] = parts[1]
+	}
+	return labels, nil
+}
+
+// newCollectorSet creates the set of the collectors enabled by flags and
+// their dependencies. The volume prober of the dependencies, if any, is not
+// running yet.
+func newCollectorSet(client *kubelet.Client) (*collectors.Set, *collectorDeps, error) {
+	var err error
+	filter := &collectors.Filter{}
+	if filter.FamilyAllowlist, err = collectors.CompileFamilyRegexp(optMetricAllowlist); err != nil {
+		return nil, nil, fmt.Errorf("invalid --metric-allowlist: %v", err)
+	}
+	if filter.FamilyDenylist, err = collectors.CompileFamilyRegexp(optMetricDenylist); err != nil {
+		return nil, nil, fmt.Errorf("invalid --metric-denylist: %v", err)
+	}
+	// The kubelet reports no PVCs of volumes it has no stats of, the PVCs
+	// bound to their PVs are looked up instead.
+	if optStatfsFallback && !optAPIServerPVCs {
+		return nil, nil, fmt.Errorf("--volume-stats-statfs-fallback needs --apiserver-pvc-lookup")
+	}
+	source := &collectors.NodeSource{
+		ProcfsRoot:     optProcfs,
+		KubeletRootDir: optKubeletRootDir,
+	}
+	if optAPIServerPVCs {
+		kubeClient, err := kube.NewInClusterClient()
+		if err != nil {
+			return nil, nil, err
+		}
+		source.Claims = node.NewClaimCache(kubeClient, time.Minute)
+	}
+	logSizeThreshold, err := resource.ParseQuantity(optLogSizeThreshold)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid --container-log-size-threshold: %v", err)
+	}
+	localVolumeDirs, err := parseLocalVolumeDirs(optLocalVolumeDirs)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid --local-volume-dirs: %v", err)
+	}
+	deps := &collectorDeps{
+		client:           client,
+		source:           source,
+		localVolumeDirs:  localVolumeDirs,
+		cri:              cri.NewSummaryProvider(cri.NewClient(optCRISocket)),
+		podLogsDir:       optPodLogsDir,
+		logSizeThreshold: logSizeThreshold.Value(),
+	}
+	if optCSIVolumeStats {
+		sockets := optCSIPluginSockets
+		if sockets == "" {
+			sockets = filepath.Join(optKubeletRootDir, "plugins", "*", "csi.sock")
+		}
+		deps.csi = &collectors.CSIStatsSource{Source: source, Plugins: csi.NewPlugins(sockets)}
+	}
+	if optNodeName != "" && collectorEnabled("local") {
+		kubeClient, err := kube.NewInClusterClient()
+		if err != nil {
+			return nil, nil, err
+		}
+		deps.localVolumes = node.NewLocalVolumeCache(kubeClient, optNodeName, time.Minute)
+	}
+	if optStatfsFallback {
+		deps.statfs = collectors.NewStatfsFallback(source, 10*time.Second)
+	}
+	if optSampleTimestamps {
+		deps.timestamps = &collectors.SampleTimestamps{Window: optSampleWindow}
+	}
+	if collectorEnabled("nfs") {
+		deps.nfsMounts = collectors.NewMountTimes()
+	}
+	if collectorEnabled("orphans") {
+		deps.orphans = collectors.NewOrphanTracker(optOrphanGracePeriod, 10*time.Minute)
+	}
+	if collectorEnabled("probe") {
+		deps.prober = collectors.NewVolumeProber(client, source, optProbeInterval, optProbeTimeout, optProbeCanary)
+	}
+	set, err := enabledCollectors(deps, filter)
+	if err != nil {
+		return nil, nil, err
+	}
+	return set, deps, nil
 }
 
 func main() {
 	// We log to stderr because glog will default to logging to a file.
 	flag.Set("logtostderr", "true")
+	if runCommand(os.Args[1:]) {
+		return
+	}
 	flag.Parse()
 
 	if optHelp {
@@ -71,12 +401,92 @@ func main() {
 		return
 	}
 
-	registry := prometheus.NewRegistry()
-	u, err := url.Parse(optKubeletAddress)
+	client, err := optKubelet.client()
+	if err != nil {
+		log.Fatal(err)
+	}
+	set, deps, err := newCollectorSet(client)
 	if err != nil {
 		log.Fatal(err)
 	}
-	u.Path = "stats/summary"
-	registry.MustRegister(collectors.NewVolumeStatsCollector(u.String()))
-	metricsServer(registry, optPort)
-}
\ No newline at end of file
+	if deps.prober != nil {
+		go deps.prober.Run(wait.NeverStop)
+	}
+	glog.Infof("Enabled collectors: %v", set.Names())
+	if optOTLPEndpoint != "" {
+		gatherer, err := set.Gatherer(nil, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		gatherer = openmetrics.WithoutCreated(gatherer)
+		exporter := otlp.NewExporter(optOTLPEndpoint, gatherer, optNodeName)
+		go exporter.Run(optOTLPInterval, wait.NeverStop)
+	}
+	if optRemoteWriteURL != "" {
+		externalLabels, err := parseExternalLabels(optRemoteWriteLabels)
+		if err != nil {
+			log.Fatalf("invalid --remote-write-external-labels: %v", err)
+		}
+		gatherer, err := set.Gatherer(nil, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		gatherer = openmetrics.WithoutCreated(gatherer)
+		writer := remotewrite.NewWriter(optRemoteWriteURL, gatherer, externalLabels, optRemoteWriteQueue)
+		go writer.Run(optRemoteWriteInterval, wait.NeverStop)
+	}
+	if optStatsDAddress != "" {
+		gatherer, err := set.Gatherer(nil, nil)
+		if err != nil {
+			log.Fatal(err)
+		}
+		gatherer = openmetrics.WithoutCreated(gatherer)
+		pusher := sink.NewUDPPusher(optStatsDAddress, gatherer, sink.NewStatsD())
+		go pusher.Run(optStatsDInterval, wait.NeverStop)
+	}
+
+	var history *api.VolumeHistory
+	if optForecastWindow > 0 {
+		history = api.NewVolumeHistory(client, optForecastWindow)
+		go history.Run(wait.NeverStop)
+	}
+
+	tlsConfig, err := serverTLSConfig()
+	if err != nil {
+		log.Fatal(err)
+	}
+	var (
+		authn    *auth.Authenticator
+		resolver *auth.NamespaceResolver
+	)
+	if optAuthConfig != "" {
+		authConfig, err := auth.LoadConfig(optAuthConfig)
+		if err != nil {
+			log.Fatal(err)
+		}
+		var lister auth.NamespaceLister
+		if authConfig.NeedsAPIServer() {
+			kubeClient, err := kube.NewInClusterClient()
+			if err != nil {
+				log.Fatal(err)
+			}
+			lister = kubeClient
+		}
+		if optDebugHandlers && !authConfig.HasDebugTenant() {
+			log.Fatal("--enable-debug-handlers requires a tenant with debug: true in --auth-config")
+		}
+		authn = auth.NewAuthenticator(authConfig)
+		resolver = auth.NewNamespaceResolver(lister, time.Minute)
+	} else if optDebugHandlers {
+		log.Fatal("--enable-debug-handlers requires --auth-config")
+	}
+	metricsServer(&serverConfig{
+		set:       set,
+		client:    client,
+		authn:     authn,
+		resolver:  resolver,
+		tlsConfig: tlsConfig,
+		debug:     optDebugHandlers,
+		history:   history,
+	}, optPort)
+}
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// makeCbreak makes the terminal fd pass keys as they are typed without
+// echoing them, keeping signals and output processing. It returns a function
+// restoring the terminal.
+func makeCbreak(fd int) (func(), error) {
+	var old syscall.Termios
+	if err := ioctl(fd, syscall.TCGETS, unsafe.Pointer(&old)); err != nil {
+		return nil, err
+	}
+	t := old
+	t.Lflag &^= syscall.ICANON | syscall.ECHO
+	t.Cc[syscall.VMIN] = 1
+	t.Cc[syscall.VTIME] = 0
+	if err := ioctl(fd, syscall.TCSETS, unsafe.Pointer(&t)); err != nil {
+		return nil, err
+	}
+	return func() {
+		ioctl(fd, syscall.TCSETS, unsafe.Pointer(&old))
+	}, nil
+}
+
+// terminalSize returns the number of columns and rows of the terminal fd.
+func terminalSize(fd int) (width, height int, err error) {
+	var ws struct {
+		Row, Col, Xpixel, Ypixel uint16
+	}
+	if err := ioctl(fd, syscall.TIOCGWINSZ, unsafe.Pointer(&ws)); err != nil {
+		return 0, 0, err
+	}
+	return int(ws.Col), int(ws.Row), nil
+}
+
+func ioctl(fd int, req uintptr, arg unsafe.Pointer) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(arg)); errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,20 @@
+//go:build !linux
+// +build !linux
+
+package main
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// makeCbreak makes the terminal fd pass keys as they are typed without
+// echoing them. It returns a function restoring the terminal.
+func makeCbreak(fd int) (func(), error) {
+	return nil, fmt.Errorf("terminal control is not supported on %s", runtime.GOOS)
+}
+
+// terminalSize returns the number of columns and rows of the terminal fd.
+func terminalSize(fd int) (width, height int, err error) {
+	return 0, 0, fmt.Errorf("terminal control is not supported on %s", runtime.GOOS)
+}
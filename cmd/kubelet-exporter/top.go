@@ -0,0 +1,357 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/api"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+const (
+	topPodsView    = "pods"
+	topVolumesView = "volumes"
+
+	topSortDisk   = "disk"
+	topSortMemory = "memory"
+	topSortCPU    = "cpu"
+
+	// topHelp is the footer of the top screen.
+	topHelp = "[p]ods [v]olumes [d]isk [m]emory [c]pu [n]amespace [q]uit"
+)
+
+// top is the state of the top command.
+type top struct {
+	source    string
+	view      string
+	sortBy    string
+	namespace string
+
+	summary *v1alpha1.Summary
+	err     error
+	fetched time.Time
+
+	// prompt is the namespace being typed, nil if none is.
+	prompt *string
+	// message is shown in the footer until the next key.
+	message string
+}
+
+// topResult is the result of a summary fetch.
+type topResult struct {
+	summary *v1alpha1.Summary
+	err     error
+	time    time.Time
+}
+
+// runTop shows the usage of the pods and volumes on the node in the terminal,
+// refreshing until q is pressed.
+func runTop(args []string) error {
+	fs := newCommandFlagSet("top", "Shows the usage of the pods and volumes on the node, refreshing until q is pressed.\n\n"+
+		"Keys: p and v switch to the pods and volumes, d, m and c sort by disk, memory and CPU,\n"+
+		"n filters by namespace and q quits.")
+	var source summarySource
+	source.addFlags(fs)
+	t := &top{}
+	var interval time.Duration
+	fs.DurationVar(&interval, "interval", 5*time.Second, "interval between refreshes")
+	fs.StringVar(&t.view, "view", topPodsView, "view to start with, pods or volumes")
+	fs.StringVar(&t.sortBy, "sort", topSortDisk, "order to start with, disk, memory or cpu; volumes are always sorted by disk")
+	fs.StringVar(&t.namespace, "namespace", "", "only show pods and volumes of the namespace")
+	fs.Parse(args)
+
+	if t.view != topPodsView && t.view != topVolumesView {
+		return fmt.Errorf("invalid --view %q, must be pods or volumes", t.view)
+	}
+	if t.sortBy != topSortDisk && t.sortBy != topSortMemory && t.sortBy != topSortCPU {
+		return fmt.Errorf("invalid --sort %q, must be disk, memory or cpu", t.sortBy)
+	}
+	if interval <= 0 {
+		return fmt.Errorf("invalid --interval %v, must be positive", interval)
+	}
+	provider, err := source.provider()
+	if err != nil {
+		return err
+	}
+	t.source = source.address
+	if source.summaryFile != "" {
+		t.source = source.summaryFile
+	}
+
+	keys := make(chan byte)
+	if restore, err := makeCbreak(int(os.Stdin.Fd())); err == nil {
+		defer restore()
+		go readKeys(os.Stdin, keys)
+	} else {
+		t.message = fmt.Sprintf("keys are disabled: %v", err)
+	}
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	results := make(chan topResult, 1)
+	fetching := false
+	fetch := func() {
+		if fetching {
+			return
+		}
+		fetching = true
+		go func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			summary, err := provider.GetSummary(ctx)
+			results <- topResult{summary: summary, err: err, time: time.Now()}
+		}()
+	}
+	fetch()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		t.draw(os.Stdout, time.Now())
+		select {
+		case <-signals:
+			return nil
+		case <-ticker.C:
+			fetch()
+		case r := <-results:
+			fetching = false
+			t.err = r.err
+			if r.err == nil {
+				t.summary, t.fetched = r.summary, r.time
+			}
+		case k, ok := <-keys:
+			if !ok {
+				keys = nil
+				continue
+			}
+			if t.handleKey(k) {
+				return nil
+			}
+		}
+	}
+}
+
+// readKeys sends the bytes read from r to keys, and closes it on errors.
+func readKeys(r io.Reader, keys chan<- byte) {
+	buf := make([]byte, 1)
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		if n == 1 {
+			keys <- buf[0]
+		}
+	}
+}
+
+// handleKey handles a key pressed, it returns true if top should quit.
+func (t *top) handleKey(k byte) bool {
+	if t.prompt != nil {
+		switch {
+		case k == '\r' || k == '\n':
+			t.namespace = strings.TrimSpace(*t.prompt)
+			t.prompt = nil
+		case k == 27: // escape
+			t.prompt = nil
+		case k == 127 || k == 8: // backspace
+			if p := *t.prompt; len(p) > 0 {
+				*t.prompt = p[:len(p)-1]
+			}
+		case k > ' ' && k < 127:
+			*t.prompt += string(k)
+		}
+		return false
+	}
+	t.message = ""
+	switch k {
+	case 'q':
+		return true
+	case 'p':
+		t.view = topPodsView
+	case 'v':
+		t.view = topVolumesView
+	case '\t':
+		if t.view == topPodsView {
+			t.view = topVolumesView
+		} else {
+			t.view = topPodsView
+		}
+	case 'd':
+		t.sortBy = topSortDisk
+	case 'm':
+		t.sortBy = topSortMemory
+	case 'c':
+		t.sortBy = topSortCPU
+	case 'n':
+		p := t.namespace
+		t.prompt = &p
+	}
+	if t.view == topVolumesView && t.sortBy != topSortDisk {
+		t.message = "volumes are sorted by disk"
+	}
+	return false
+}
+
+// draw draws the screen to w, cutting it to the size of the terminal.
+func (t *top) draw(w io.Writer, now time.Time) {
+	header, table, footer := t.render(now)
+	if width, height, err := terminalSize(int(os.Stdout.Fd())); err == nil && width > 0 && height > 0 {
+		// Keep the last row empty, writing it would scroll the screen.
+		if rows := height - 1 - len(header) - len(footer); rows >= 0 && len(table) > rows {
+			table = table[:rows]
+		}
+		for _, lines := range [][]string{header, table, footer} {
+			for i, line := range lines {
+				if len(line) > width {
+					lines[i] = line[:width]
+				}
+			}
+		}
+	}
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[H")
+	for _, lines := range [][]string{header, table, footer} {
+		for _, line := range lines {
+			buf.WriteString(line)
+			buf.WriteString("\x1b[K\n")
+		}
+	}
+	buf.WriteString("\x1b[J")
+	w.Write(buf.Bytes())
+}
+
+// render renders the header, the table and the footer of the screen.
+func (t *top) render(now time.Time) (header, table, footer []string) {
+	title := "kubelet-exporter top - " + t.source
+	if t.summary != nil && t.summary.Node.NodeName != "" {
+		title = fmt.Sprintf("kubelet-exporter top - %s (%s)", t.summary.Node.NodeName, t.source)
+	}
+	namespace := t.namespace
+	if namespace == "" {
+		namespace = "all"
+	}
+	header = append(header, title, fmt.Sprintf("View: %s  Sort: %s  Namespace: %s", t.view, t.sortBy, namespace))
+	if !t.fetched.IsZero() {
+		header = append(header, fmt.Sprintf("Updated: %s (%s ago)", t.fetched.Format("15:04:05"), formatDuration(now.Sub(t.fetched))))
+	}
+	if t.err != nil {
+		header = append(header, "Error: "+t.err.Error())
+	}
+	header = append(header, "")
+
+	if t.summary != nil {
+		var namespaces map[string]bool
+		if t.namespace != "" {
+			namespaces = map[string]bool{t.namespace: true}
+		}
+		var buf bytes.Buffer
+		tw := tabwriter.NewWriter(&buf, 0, 0, 2, ' ', 0)
+		if t.view == topVolumesView {
+			writeTopVolumes(tw, api.Volumes(t.summary, namespaces, now))
+		} else {
+			writeTopPods(tw, api.Pods(t.summary, namespaces, now), t.sortBy)
+		}
+		tw.Flush()
+		table = strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	}
+
+	footer = append(footer, "")
+	switch {
+	case t.prompt != nil:
+		footer = append(footer, "Namespace, empty for all: "+*t.prompt+"_")
+	case t.message != "":
+		footer = append(footer, t.message)
+	default:
+		footer = append(footer, topHelp)
+	}
+	return header, table, footer
+}
+
+// writeTopPods writes the pods sorted by sortBy in descending order as tab
+// separated rows.
+func writeTopPods(w io.Writer, pods []api.Pod, sortBy string) {
+	var value func(p *api.Pod) float64
+	switch sortBy {
+	case topSortMemory:
+		value = func(p *api.Pod) float64 { return float64Value(p.MemoryWorkingSetBytes) }
+	case topSortCPU:
+		value = func(p *api.Pod) float64 { return float64Value(p.CPUUsageNanoCores) }
+	default:
+		value = podDiskUsedBytes
+	}
+	sort.SliceStable(pods, func(i, j int) bool { return value(&pods[i]) > value(&pods[j]) })
+
+	fmt.Fprintln(w, "NAMESPACE\tPOD\tCPU\tMEMORY\tEPHEMERAL\tPVCS")
+	for i := range pods {
+		p := &pods[i]
+		var ephemeral *uint64
+		if p.EphemeralStorage != nil {
+			ephemeral = p.EphemeralStorage.UsedBytes
+		}
+		pvcs := uint64(podPVCUsedBytes(p))
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", p.Namespace, p.Name,
+			formatCPU(p.CPUUsageNanoCores), formatBytes(p.MemoryWorkingSetBytes), formatBytes(ephemeral), formatBytes(&pvcs))
+	}
+}
+
+// writeTopVolumes writes the volumes sorted by the fraction used in
+// descending order as tab separated rows.
+func writeTopVolumes(w io.Writer, volumes []api.Volume) {
+	api.SortVolumes(volumes, "-usedRatio")
+
+	fmt.Fprintln(w, "NAMESPACE\tPVC\tCAPACITY\tUSED\tUSED%\tINODES%\tPODS")
+	for i := range volumes {
+		v := &volumes[i]
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d%%\t%d%%\t%s\n", v.Namespace, v.PersistentVolumeClaim,
+			formatBytes(v.CapacityBytes), formatBytes(v.UsedBytes), percent(v.UsedRatio()), percent(v.InodesUsedRatio()), strings.Join(v.Pods, ","))
+	}
+}
+
+// podDiskUsedBytes returns the bytes used by the ephemeral storage and the
+// PVCs of a pod.
+func podDiskUsedBytes(p *api.Pod) float64 {
+	used := podPVCUsedBytes(p)
+	if p.EphemeralStorage != nil {
+		used += float64Value(p.EphemeralStorage.UsedBytes)
+	}
+	return used
+}
+
+// podPVCUsedBytes returns the bytes used by the PVCs of a pod. Other volumes
+// are part of its ephemeral storage.
+func podPVCUsedBytes(p *api.Pod) float64 {
+	var used float64
+	for i := range p.Volumes {
+		if p.Volumes[i].PersistentVolumeClaim != "" {
+			used += float64Value(p.Volumes[i].UsedBytes)
+		}
+	}
+	return used
+}
+
+func float64Value(v *uint64) float64 {
+	if v == nil {
+		return 0
+	}
+	return float64(*v)
+}
+
+// formatCPU formats nano cores as milli cores, e.g. 250m.
+func formatCPU(nanoCores *uint64) string {
+	if nanoCores == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%dm", *nanoCores/1000000)
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/api"
+)
+
+const (
+	volumeTypePVC       = "pvc"
+	volumeTypeEphemeral = "ephemeral"
+)
+
+// volumeRowSortKeys are the keys the volumes command can sort by.
+var volumeRowSortKeys = []string{"namespace", "name", "capacityBytes", "usedBytes", "availableBytes", "usedRatio", "inodesUsed", "inodesUsedRatio"}
+
+// volumeRow is a volume reported by the volumes command.
+type volumeRow struct {
+	Namespace string `json:"namespace"`
+	// Type is pvc or ephemeral.
+	Type string `json:"type"`
+	// Name is the name of the PVC, or of the volume in its pod if it is
+	// ephemeral.
+	Name string `json:"name"`
+	api.Usage
+	Pods []string `json:"pods"`
+}
+
+// volumeRowList is the JSON output of the volumes command.
+type volumeRowList struct {
+	Items []volumeRow `json:"items"`
+}
+
+// runVolumes prints the PVCs and ephemeral volumes of the pods on the node
+// with their usage once.
+func runVolumes(args []string) error {
+	fs := newCommandFlagSet("volumes", "Prints the PVCs and ephemeral volumes of the pods on the node with their usage.\n\n"+
+		"With --threshold, it exits with status 1 if any volume reaches the threshold.")
+	var source summarySource
+	source.addFlags(fs)
+	var (
+		output     string
+		sortKey    string
+		namespaces string
+		threshold  float64
+	)
+	fs.StringVar(&output, "output", "table", "output format, table, json or csv")
+	fs.StringVar(&sortKey, "sort", "namespace", "key to sort by, descending if prefixed with -, one of "+strings.Join(volumeRowSortKeys, ", "))
+	fs.StringVar(&namespaces, "namespace", "", "comma separated namespaces to print volumes of, default all")
+	fs.Float64Var(&threshold, "threshold", 0, "only print volumes with at least this percentage of bytes or inodes used")
+	fs.Parse(args)
+
+	var write func(io.Writer, []volumeRow) error
+	switch output {
+	case "table":
+		write = writeVolumeTable
+	case "json":
+		write = writeVolumeJSON
+	case "csv":
+		write = writeVolumeCSV
+	default:
+		return fmt.Errorf("invalid --output %q, must be table, json or csv", output)
+	}
+	if threshold < 0 || threshold > 100 {
+		return fmt.Errorf("invalid --threshold %v, must be a percentage", threshold)
+	}
+	provider, err := source.provider()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	summary, err := provider.GetSummary(ctx)
+	if err != nil {
+		return err
+	}
+
+	var selected map[string]bool
+	if namespaces != "" {
+		selected = map[string]bool{}
+		for _, ns := range strings.Split(namespaces, ",") {
+			selected[strings.TrimSpace(ns)] = true
+		}
+	}
+	now := time.Now()
+	rows := []volumeRow{}
+	add := func(row volumeRow) {
+		if threshold > 0 && row.UsedRatio()*100 < threshold && row.InodesUsedRatio()*100 < threshold {
+			return
+		}
+		rows = append(rows, row)
+	}
+	for _, v := range api.Volumes(summary, selected, now) {
+		add(volumeRow{Namespace: v.Namespace, Type: volumeTypePVC, Name: v.PersistentVolumeClaim, Usage: v.Usage, Pods: v.Pods})
+	}
+	for _, p := range api.Pods(summary, selected, now) {
+		for _, v := range p.Volumes {
+			if v.PersistentVolumeClaim == "" {
+				add(volumeRow{Namespace: p.Namespace, Type: volumeTypeEphemeral, Name: v.Name, Usage: v.Usage, Pods: []string{p.Name}})
+			}
+		}
+	}
+	if err := sortVolumeRows(rows, sortKey); err != nil {
+		return err
+	}
+
+	if err := write(os.Stdout, rows); err != nil {
+		return err
+	}
+	if threshold > 0 && len(rows) > 0 {
+		return fmt.Errorf("%d volumes have at least %v%% of bytes or inodes used", len(rows), threshold)
+	}
+	return nil
+}
+
+// sortVolumeRows sorts rows by one of volumeRowSortKeys, in descending order
+// if prefixed with "-". Ties are sorted by namespace, name and type.
+func sortVolumeRows(rows []volumeRow, key string) error {
+	desc, key := strings.HasPrefix(key, "-"), strings.TrimPrefix(key, "-")
+	var value func(r *volumeRow) float64
+	switch key {
+	case "", "namespace", "name":
+	case "capacityBytes":
+		value = func(r *volumeRow) float64 { return float64Value(r.CapacityBytes) }
+	case "usedBytes":
+		value = func(r *volumeRow) float64 { return float64Value(r.UsedBytes) }
+	case "availableBytes":
+		value = func(r *volumeRow) float64 { return float64Value(r.AvailableBytes) }
+	case "usedRatio":
+		value = func(r *volumeRow) float64 { return r.UsedRatio() }
+	case "inodesUsed":
+		value = func(r *volumeRow) float64 { return float64Value(r.InodesUsed) }
+	case "inodesUsedRatio":
+		value = func(r *volumeRow) float64 { return r.InodesUsedRatio() }
+	default:
+		return fmt.Errorf("unknown sort key %q, must be one of %s", key, strings.Join(volumeRowSortKeys, ", "))
+	}
+	byName := func(a, b *volumeRow) bool {
+		if key == "name" && a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		return a.Type < b.Type
+	}
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := &rows[i], &rows[j]
+		if desc {
+			a, b = b, a
+		}
+		if value == nil {
+			return byName(a, b)
+		}
+		if va, vb := value(a), value(b); va != vb {
+			return va < vb
+		}
+		return byName(&rows[i], &rows[j])
+	})
+	return nil
+}
+
+func writeVolumeTable(w io.Writer, rows []volumeRow) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAMESPACE\tTYPE\tNAME\tCAPACITY\tUSED\tUSED%\tINODES\tINODES%\tPODS")
+	for i := range rows {
+		r := &rows[i]
+		inodes := "-"
+		if r.InodesUsed != nil {
+			inodes = strconv.FormatUint(*r.InodesUsed, 10)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d%%\t%s\t%d%%\t%s\n", r.Namespace, r.Type, r.Name,
+			formatBytes(r.CapacityBytes), formatBytes(r.UsedBytes), percent(r.UsedRatio()), inodes, percent(r.InodesUsedRatio()), strings.Join(r.Pods, ","))
+	}
+	return tw.Flush()
+}
+
+func writeVolumeJSON(w io.Writer, rows []volumeRow) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(&volumeRowList{Items: rows})
+}
+
+// writeVolumeCSV writes rows as CSV with a header. Unknown values are empty,
+// pods are separated by spaces.
+func writeVolumeCSV(w io.Writer, rows []volumeRow) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"namespace", "type", "name", "capacityBytes", "usedBytes", "availableBytes", "usedRatio", "inodes", "inodesUsed", "inodesUsedRatio", "pods"})
+	optional := func(v *uint64) string {
+		if v == nil {
+			return ""
+		}
+		return strconv.FormatUint(*v, 10)
+	}
+	ratio := func(part, total *uint64, r float64) string {
+		if part == nil || total == nil || *total == 0 {
+			return ""
+		}
+		return strconv.FormatFloat(r, 'f', 4, 64)
+	}
+	for i := range rows {
+		r := &rows[i]
+		cw.Write([]string{
+			r.Namespace, r.Type, r.Name,
+			optional(r.CapacityBytes), optional(r.UsedBytes), optional(r.AvailableBytes),
+			ratio(r.UsedBytes, r.CapacityBytes, r.UsedRatio()),
+			optional(r.Inodes), optional(r.InodesUsed),
+			ratio(r.InodesUsed, r.Inodes, r.InodesUsedRatio()),
+			strings.Join(r.Pods, " "),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
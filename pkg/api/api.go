@@ -0,0 +1,324 @@
+// Package api builds the structured views of the volume usage on the node
+// served under /api/v1 from stats summaries.
+package api
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+// Usage is the usage of a filesystem. Fields the kubelet didn't report are
+// omitted.
+type Usage struct {
+	CapacityBytes  *uint64 `json:"capacityBytes,omitempty"`
+	UsedBytes      *uint64 `json:"usedBytes,omitempty"`
+	AvailableBytes *uint64 `json:"availableBytes,omitempty"`
+	Inodes         *uint64 `json:"inodes,omitempty"`
+	InodesUsed     *uint64 `json:"inodesUsed,omitempty"`
+	InodesFree     *uint64 `json:"inodesFree,omitempty"`
+	// Time is when the kubelet took the stats.
+	Time time.Time `json:"time"`
+	// AgeSeconds is how old the stats were when the view was built.
+	AgeSeconds float64 `json:"ageSeconds"`
+}
+
+func newUsage(fsStats *v1alpha1.FsStats, now time.Time) Usage {
+	return Usage{
+		CapacityBytes:  fsStats.CapacityBytes,
+		UsedBytes:      fsStats.UsedBytes,
+		AvailableBytes: fsStats.AvailableBytes,
+		Inodes:         fsStats.Inodes,
+		InodesUsed:     fsStats.InodesUsed,
+		InodesFree:     fsStats.InodesFree,
+		Time:           fsStats.Time.Time,
+		AgeSeconds:     now.Sub(fsStats.Time.Time).Seconds(),
+	}
+}
+
+// UsedRatio returns the fraction of the capacity used, 0 if unknown.
+func (u *Usage) UsedRatio() float64 {
+	return ratio(u.UsedBytes, u.CapacityBytes)
+}
+
+// InodesUsedRatio returns the fraction of the inodes used, 0 if unknown.
+func (u *Usage) InodesUsedRatio() float64 {
+	return ratio(u.InodesUsed, u.Inodes)
+}
+
+func ratio(part, total *uint64) float64 {
+	if part == nil || total == nil || *total == 0 {
+		return 0
+	}
+	return float64(*part) / float64(*total)
+}
+
+func value(v *uint64) float64 {
+	if v == nil {
+		return 0
+	}
+	return float64(*v)
+}
+
+// Volume is a PVC used by pods on the node.
+type Volume struct {
+	Namespace             string `json:"namespace"`
+	PersistentVolumeClaim string `json:"persistentVolumeClaim"`
+	Usage
+	// Pods are the names of the pods on the node mounting the PVC.
+	Pods []string `json:"pods"`
+}
+
+// VolumeList is the response of /api/v1/volumes.
+type VolumeList struct {
+	Items []Volume `json:"items"`
+}
+
+// PodVolume is a volume of a pod.
+type PodVolume struct {
+	Name string `json:"name"`
+	// PersistentVolumeClaim is empty if the volume is not a PVC.
+	PersistentVolumeClaim string `json:"persistentVolumeClaim,omitempty"`
+	Usage
+}
+
+// Pod is a pod on the node with the usage of its volumes.
+type Pod struct {
+	Namespace string    `json:"namespace"`
+	Name      string    `json:"name"`
+	UID       string    `json:"uid"`
+	StartTime time.Time `json:"startTime"`
+	// CPUUsageNanoCores and MemoryWorkingSetBytes are the CPU and memory
+	// usage of the pod, summed over its containers if the kubelet doesn't
+	// report them for the pod.
+	CPUUsageNanoCores     *uint64 `json:"cpuUsageNanoCores,omitempty"`
+	MemoryWorkingSetBytes *uint64 `json:"memoryWorkingSetBytes,omitempty"`
+	// EphemeralStorage is the usage of the local storage of the pod, i.e.
+	// its logs, writable layers and emptyDir volumes.
+	EphemeralStorage *Usage      `json:"ephemeralStorage,omitempty"`
+	Volumes          []PodVolume `json:"volumes"`
+}
+
+// VolumeUsedBytes returns the sum of the bytes used by the volumes of the
+// pod.
+func (p *Pod) VolumeUsedBytes() float64 {
+	var used float64
+	for i := range p.Volumes {
+		used += value(p.Volumes[i].UsedBytes)
+	}
+	return used
+}
+
+// PodList is the response of /api/v1/pods.
+type PodList struct {
+	Items []Pod `json:"items"`
+}
+
+// Volumes returns the PVCs used by the pods in summary in namespaces, or in
+// all namespaces if namespaces is nil, sorted by namespace and name. The
+// usage of a PVC mounted by several pods is taken from the first pod which
+// reports its capacity.
+func Volumes(summary *v1alpha1.Summary, namespaces map[string]bool, now time.Time) []Volume {
+	volumes := []Volume{}
+	index := map[v1alpha1.PVCReference]int{}
+	for _, podStats := range summary.Pods {
+		if namespaces != nil && !namespaces[podStats.PodRef.Namespace] {
+			continue
+		}
+		for j := range podStats.VolumeStats {
+			vs := &podStats.VolumeStats[j]
+			if vs.PVCRef == nil {
+				continue
+			}
+			i, ok := index[*vs.PVCRef]
+			if !ok {
+				i = len(volumes)
+				index[*vs.PVCRef] = i
+				volumes = append(volumes, Volume{
+					Namespace:             vs.PVCRef.Namespace,
+					PersistentVolumeClaim: vs.PVCRef.Name,
+					Usage:                 newUsage(&vs.FsStats, now),
+				})
+			} else if volumes[i].CapacityBytes == nil && vs.CapacityBytes != nil {
+				volumes[i].Usage = newUsage(&vs.FsStats, now)
+			}
+			volumes[i].Pods = append(volumes[i].Pods, podStats.PodRef.Name)
+		}
+	}
+	SortVolumes(volumes, "")
+	return volumes
+}
+
+// Pods returns the pods in summary in namespaces, or in all namespaces if
+// namespaces is nil, sorted by namespace and name.
+func Pods(summary *v1alpha1.Summary, namespaces map[string]bool, now time.Time) []Pod {
+	pods := []Pod{}
+	for _, podStats := range summary.Pods {
+		if namespaces != nil && !namespaces[podStats.PodRef.Namespace] {
+			continue
+		}
+		pod := Pod{
+			Namespace: podStats.PodRef.Namespace,
+			Name:      podStats.PodRef.Name,
+			UID:       podStats.PodRef.UID,
+			StartTime: podStats.StartTime.Time,
+			Volumes:   []PodVolume{},
+		}
+		pod.CPUUsageNanoCores, pod.MemoryWorkingSetBytes = podCPUAndMemory(&podStats)
+		if podStats.EphemeralStorage != nil {
+			usage := newUsage(podStats.EphemeralStorage, now)
+			pod.EphemeralStorage = &usage
+		}
+		for j := range podStats.VolumeStats {
+			vs := &podStats.VolumeStats[j]
+			v := PodVolume{Name: vs.Name, Usage: newUsage(&vs.FsStats, now)}
+			if vs.PVCRef != nil {
+				v.PersistentVolumeClaim = vs.PVCRef.Name
+			}
+			pod.Volumes = append(pod.Volumes, v)
+		}
+		pods = append(pods, pod)
+	}
+	SortPods(pods, "")
+	return pods
+}
+
+// podCPUAndMemory returns the CPU usage and memory working set of a pod, nil
+// if unknown.
+func podCPUAndMemory(podStats *v1alpha1.PodStats) (cpu, memory *uint64) {
+	if podStats.CPU != nil {
+		cpu = podStats.CPU.UsageNanoCores
+	}
+	if podStats.Memory != nil {
+		memory = podStats.Memory.WorkingSetBytes
+	}
+	for _, c := range podStats.Containers {
+		if podStats.CPU == nil && c.CPU != nil && c.CPU.UsageNanoCores != nil {
+			cpu = sum(cpu, *c.CPU.UsageNanoCores)
+		}
+		if podStats.Memory == nil && c.Memory != nil && c.Memory.WorkingSetBytes != nil {
+			memory = sum(memory, *c.Memory.WorkingSetBytes)
+		}
+	}
+	return cpu, memory
+}
+
+// sum returns the sum of a and b, b if a is nil.
+func sum(a *uint64, b uint64) *uint64 {
+	if a != nil {
+		b += *a
+	}
+	return &b
+}
+
+// VolumeSortKeys are the keys volumes can be sorted by.
+var VolumeSortKeys = []string{"namespace", "persistentVolumeClaim", "capacityBytes", "usedBytes", "availableBytes", "usedRatio", "inodesUsed", "inodesUsedRatio", "ageSeconds"}
+
+// PodSortKeys are the keys pods can be sorted by.
+var PodSortKeys = []string{"namespace", "name", "startTime", "cpuUsageNanoCores", "memoryWorkingSetBytes", "volumeUsedBytes", "ephemeralStorageUsedBytes"}
+
+// SortVolumes sorts volumes by one of VolumeSortKeys, in descending order if
+// prefixed with "-". Ties, and all volumes if key is empty, are sorted by
+// namespace and name.
+func SortVolumes(volumes []Volume, key string) error {
+	desc, key := strings.HasPrefix(key, "-"), strings.TrimPrefix(key, "-")
+	var less func(a, b *Volume) bool
+	switch key {
+	case "", "namespace", "persistentVolumeClaim":
+	case "capacityBytes":
+		less = func(a, b *Volume) bool { return value(a.CapacityBytes) < value(b.CapacityBytes) }
+	case "usedBytes":
+		less = func(a, b *Volume) bool { return value(a.UsedBytes) < value(b.UsedBytes) }
+	case "availableBytes":
+		less = func(a, b *Volume) bool { return value(a.AvailableBytes) < value(b.AvailableBytes) }
+	case "usedRatio":
+		less = func(a, b *Volume) bool { return a.UsedRatio() < b.UsedRatio() }
+	case "inodesUsed":
+		less = func(a, b *Volume) bool { return value(a.InodesUsed) < value(b.InodesUsed) }
+	case "inodesUsedRatio":
+		less = func(a, b *Volume) bool { return a.InodesUsedRatio() < b.InodesUsedRatio() }
+	case "ageSeconds":
+		less = func(a, b *Volume) bool { return a.AgeSeconds < b.AgeSeconds }
+	default:
+		return fmt.Errorf("unknown sort key %q, must be one of %s", key, strings.Join(VolumeSortKeys, ", "))
+	}
+	byName := func(a, b *Volume) bool {
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.PersistentVolumeClaim < b.PersistentVolumeClaim
+	}
+	if less == nil {
+		less = byName
+	}
+	sort.SliceStable(volumes, func(i, j int) bool {
+		a, b := &volumes[i], &volumes[j]
+		if desc {
+			a, b = b, a
+		}
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return byName(&volumes[i], &volumes[j])
+	})
+	return nil
+}
+
+// SortPods sorts pods by one of PodSortKeys, in descending order if prefixed
+// with "-". Ties, and all pods if key is empty, are sorted by namespace and
+// name.
+func SortPods(pods []Pod, key string) error {
+	desc, key := strings.HasPrefix(key, "-"), strings.TrimPrefix(key, "-")
+	var less func(a, b *Pod) bool
+	switch key {
+	case "", "namespace", "name":
+	case "startTime":
+		less = func(a, b *Pod) bool { return a.StartTime.Before(b.StartTime) }
+	case "cpuUsageNanoCores":
+		less = func(a, b *Pod) bool { return value(a.CPUUsageNanoCores) < value(b.CPUUsageNanoCores) }
+	case "memoryWorkingSetBytes":
+		less = func(a, b *Pod) bool { return value(a.MemoryWorkingSetBytes) < value(b.MemoryWorkingSetBytes) }
+	case "volumeUsedBytes":
+		less = func(a, b *Pod) bool { return a.VolumeUsedBytes() < b.VolumeUsedBytes() }
+	case "ephemeralStorageUsedBytes":
+		less = func(a, b *Pod) bool { return ephemeralUsedBytes(a) < ephemeralUsedBytes(b) }
+	default:
+		return fmt.Errorf("unknown sort key %q, must be one of %s", key, strings.Join(PodSortKeys, ", "))
+	}
+	byName := func(a, b *Pod) bool {
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	}
+	if less == nil {
+		less = byName
+	}
+	sort.SliceStable(pods, func(i, j int) bool {
+		a, b := &pods[i], &pods[j]
+		if desc {
+			a, b = b, a
+		}
+		if less(a, b) {
+			return true
+		}
+		if less(b, a) {
+			return false
+		}
+		return byName(&pods[i], &pods[j])
+	})
+	return nil
+}
+
+func ephemeralUsedBytes(p *Pod) float64 {
+	if p.EphemeralStorage == nil {
+		return 0
+	}
+	return value(p.EphemeralStorage.UsedBytes)
+}
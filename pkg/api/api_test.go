@@ -0,0 +1,264 @@
+package api
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+func uint64p(v uint64) *uint64 {
+	return &v
+}
+
+// newPVCStats returns the stats of a volume of the PVC name in namespace with
+// used bytes used, and of unknown capacity if capacity is nil.
+func newPVCStats(namespace, name string, capacity *uint64, used uint64, t time.Time) v1alpha1.VolumeStats {
+	return v1alpha1.VolumeStats{
+		Name:   "volume-" + name,
+		PVCRef: &v1alpha1.PVCReference{Namespace: namespace, Name: name},
+		FsStats: v1alpha1.FsStats{
+			Time:          metav1.NewTime(t),
+			CapacityBytes: capacity,
+			UsedBytes:     uint64p(used),
+		},
+	}
+}
+
+func newPodStats(namespace, name string, volumes ...v1alpha1.VolumeStats) v1alpha1.PodStats {
+	return v1alpha1.PodStats{
+		PodRef:      v1alpha1.PodReference{Namespace: namespace, Name: name, UID: name + "-uid"},
+		VolumeStats: volumes,
+	}
+}
+
+func volumeNames(volumes []Volume) []string {
+	var names []string
+	for _, v := range volumes {
+		names = append(names, v.Namespace+"/"+v.PersistentVolumeClaim)
+	}
+	return names
+}
+
+func podNames(pods []Pod) []string {
+	var names []string
+	for _, p := range pods {
+		names = append(names, p.Namespace+"/"+p.Name)
+	}
+	return names
+}
+
+func TestVolumes(t *testing.T) {
+	now := time.Unix(100000, 0)
+	summary := &v1alpha1.Summary{Pods: []v1alpha1.PodStats{
+		newPodStats("team-b", "cache-0", newPVCStats("team-b", "cache", uint64p(1000), 10, now)),
+		// The first pod mounting the PVC doesn't report its capacity yet.
+		newPodStats("team-a", "web-0", newPVCStats("team-a", "data", nil, 100, now.Add(-time.Minute))),
+		newPodStats("team-a", "web-1",
+			newPVCStats("team-a", "data", uint64p(1000), 200, now.Add(-time.Second)),
+			v1alpha1.VolumeStats{Name: "tmp"},
+		),
+		// The capacity is known already, the stats of this pod are ignored.
+		newPodStats("team-a", "web-2", newPVCStats("team-a", "data", uint64p(2000), 300, now)),
+	}}
+
+	volumes := Volumes(summary, nil, now)
+	if got, want := volumeNames(volumes), []string{"team-a/data", "team-b/cache"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got volumes %v, want %v", got, want)
+	}
+	data := volumes[0]
+	if got, want := data.Pods, []string{"web-0", "web-1", "web-2"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got pods %v, want %v", got, want)
+	}
+	if data.CapacityBytes == nil || *data.CapacityBytes != 1000 {
+		t.Errorf("got capacity %v, want 1000 from the first pod reporting it", data.CapacityBytes)
+	}
+	if got := value(data.UsedBytes); got != 200 {
+		t.Errorf("got %v used bytes, want 200", got)
+	}
+	if got := data.AgeSeconds; got != 1 {
+		t.Errorf("got age %v seconds, want 1", got)
+	}
+	if got := data.UsedRatio(); got != 0.2 {
+		t.Errorf("got used ratio %v, want 0.2", got)
+	}
+
+	volumes = Volumes(summary, map[string]bool{"team-b": true}, now)
+	if got, want := volumeNames(volumes), []string{"team-b/cache"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got volumes %v in team-b, want %v", got, want)
+	}
+	volumes = Volumes(summary, map[string]bool{}, now)
+	if len(volumes) != 0 {
+		t.Errorf("got volumes %v without namespaces, want none", volumeNames(volumes))
+	}
+}
+
+func TestPods(t *testing.T) {
+	now := time.Unix(100000, 0)
+	withContainers := newPodStats("team-a", "web-0", newPVCStats("team-a", "data", uint64p(1000), 100, now))
+	withContainers.Containers = []v1alpha1.ContainerStats{
+		{
+			Name:   "app",
+			CPU:    &v1alpha1.CPUStats{UsageNanoCores: uint64p(300)},
+			Memory: &v1alpha1.MemoryStats{WorkingSetBytes: uint64p(4000)},
+		},
+		{
+			Name:   "sidecar",
+			CPU:    &v1alpha1.CPUStats{UsageNanoCores: uint64p(20)},
+			Memory: &v1alpha1.MemoryStats{},
+		},
+		{Name: "starting"},
+	}
+	withContainers.EphemeralStorage = &v1alpha1.FsStats{Time: metav1.NewTime(now), UsedBytes: uint64p(50)}
+	withPod := newPodStats("team-a", "web-1")
+	withPod.CPU = &v1alpha1.CPUStats{UsageNanoCores: uint64p(7)}
+	withPod.Memory = &v1alpha1.MemoryStats{}
+	withPod.Containers = []v1alpha1.ContainerStats{{
+		Name:   "app",
+		CPU:    &v1alpha1.CPUStats{UsageNanoCores: uint64p(300)},
+		Memory: &v1alpha1.MemoryStats{WorkingSetBytes: uint64p(4000)},
+	}}
+	summary := &v1alpha1.Summary{Pods: []v1alpha1.PodStats{
+		withPod,
+		newPodStats("team-b", "cache-0"),
+		withContainers,
+	}}
+
+	pods := Pods(summary, nil, now)
+	if got, want := podNames(pods), []string{"team-a/web-0", "team-a/web-1", "team-b/cache-0"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("got pods %v, want %v", got, want)
+	}
+	web0, web1, cache0 := pods[0], pods[1], pods[2]
+	if got := value(web0.CPUUsageNanoCores); got != 320 {
+		t.Errorf("got %v CPU nanocores summed over containers, want 320", got)
+	}
+	if got := value(web0.MemoryWorkingSetBytes); got != 4000 {
+		t.Errorf("got %v memory bytes summed over containers, want 4000", got)
+	}
+	if web0.EphemeralStorage == nil || value(web0.EphemeralStorage.UsedBytes) != 50 {
+		t.Errorf("got ephemeral storage %+v, want 50 bytes used", web0.EphemeralStorage)
+	}
+	if len(web0.Volumes) != 1 || web0.Volumes[0].PersistentVolumeClaim != "data" || web0.VolumeUsedBytes() != 100 {
+		t.Errorf("got volumes %+v, want data with 100 bytes used", web0.Volumes)
+	}
+	// The stats of the pod win over those of its containers, even if
+	// incomplete.
+	if got := value(web1.CPUUsageNanoCores); got != 7 {
+		t.Errorf("got %v CPU nanocores, want 7 of the pod", got)
+	}
+	if web1.MemoryWorkingSetBytes != nil {
+		t.Errorf("got %v memory bytes, want unknown like the pod", *web1.MemoryWorkingSetBytes)
+	}
+	if cache0.CPUUsageNanoCores != nil || cache0.MemoryWorkingSetBytes != nil || cache0.EphemeralStorage != nil {
+		t.Errorf("got usage %+v of a pod without stats, want unknown", cache0)
+	}
+	if cache0.Volumes == nil {
+		t.Error("got nil volumes, want an empty list")
+	}
+
+	pods = Pods(summary, map[string]bool{"team-b": true}, now)
+	if got, want := podNames(pods), []string{"team-b/cache-0"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got pods %v in team-b, want %v", got, want)
+	}
+}
+
+func TestSortVolumes(t *testing.T) {
+	volume := func(namespace, name string, used, inodesUsed uint64) Volume {
+		return Volume{
+			Namespace:             namespace,
+			PersistentVolumeClaim: name,
+			Usage:                 Usage{CapacityBytes: uint64p(1000), UsedBytes: uint64p(used), InodesUsed: uint64p(inodesUsed)},
+		}
+	}
+	tests := []struct {
+		key     string
+		want    []string
+		wantErr bool
+	}{
+		{key: "", want: []string{"team-a/data", "team-a/logs", "team-b/cache", "team-b/data"}},
+		{key: "namespace", want: []string{"team-a/data", "team-a/logs", "team-b/cache", "team-b/data"}},
+		{key: "-persistentVolumeClaim", want: []string{"team-b/data", "team-b/cache", "team-a/logs", "team-a/data"}},
+		// Ties are sorted by namespace and name in ascending order either
+		// way.
+		{key: "usedBytes", want: []string{"team-b/cache", "team-a/data", "team-b/data", "team-a/logs"}},
+		{key: "-usedBytes", want: []string{"team-a/logs", "team-a/data", "team-b/data", "team-b/cache"}},
+		{key: "-usedRatio", want: []string{"team-a/logs", "team-a/data", "team-b/data", "team-b/cache"}},
+		{key: "-inodesUsed", want: []string{"team-b/cache", "team-a/data", "team-a/logs", "team-b/data"}},
+		{key: "size", wantErr: true},
+		{key: "-size", wantErr: true},
+		{key: "--usedBytes", wantErr: true},
+	}
+	for _, test := range tests {
+		volumes := []Volume{
+			volume("team-b", "data", 500, 1),
+			volume("team-a", "logs", 900, 1),
+			volume("team-b", "cache", 100, 3),
+			volume("team-a", "data", 500, 1),
+		}
+		err := SortVolumes(volumes, test.key)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("got no error for key %q, want one", test.key)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("got error %v for key %q", err, test.key)
+			continue
+		}
+		if got := volumeNames(volumes); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("got %v sorted by %q, want %v", got, test.key, test.want)
+		}
+	}
+}
+
+func TestSortPods(t *testing.T) {
+	start := time.Unix(100000, 0)
+	pod := func(namespace, name string, startTime time.Time, memory *uint64, ephemeralUsed uint64) Pod {
+		return Pod{
+			Namespace:             namespace,
+			Name:                  name,
+			StartTime:             startTime,
+			MemoryWorkingSetBytes: memory,
+			EphemeralStorage:      &Usage{UsedBytes: uint64p(ephemeralUsed)},
+		}
+	}
+	tests := []struct {
+		key     string
+		want    []string
+		wantErr bool
+	}{
+		{key: "", want: []string{"team-a/web-0", "team-a/web-1", "team-b/cache-0"}},
+		{key: "-name", want: []string{"team-b/cache-0", "team-a/web-1", "team-a/web-0"}},
+		{key: "startTime", want: []string{"team-a/web-1", "team-a/web-0", "team-b/cache-0"}},
+		{key: "-startTime", want: []string{"team-a/web-0", "team-b/cache-0", "team-a/web-1"}},
+		// Unknown memory sorts as 0.
+		{key: "-memoryWorkingSetBytes", want: []string{"team-a/web-1", "team-a/web-0", "team-b/cache-0"}},
+		{key: "ephemeralStorageUsedBytes", want: []string{"team-a/web-0", "team-a/web-1", "team-b/cache-0"}},
+		{key: "memory", wantErr: true},
+		{key: "-memory", wantErr: true},
+	}
+	for _, test := range tests {
+		pods := []Pod{
+			pod("team-b", "cache-0", start, nil, 10),
+			pod("team-a", "web-1", start.Add(-time.Hour), uint64p(2000), 10),
+			pod("team-a", "web-0", start, uint64p(1000), 10),
+		}
+		err := SortPods(pods, test.key)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("got no error for key %q, want one", test.key)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("got error %v for key %q", err, test.key)
+			continue
+		}
+		if got := podNames(pods); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("got %v sorted by %q, want %v", got, test.key, test.want)
+		}
+	}
+}
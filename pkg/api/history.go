@@ -0,0 +1,143 @@
+package api
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+	"github.com/golang/glog"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+const (
+	// HistoryInterval is the interval between samples of the history, the
+	// default interval at which the kubelet refreshes volume stats.
+	HistoryInterval = time.Minute
+
+	// minForecastSpan is the minimum time the samples of a forecast must
+	// span.
+	minForecastSpan = 15 * time.Minute
+	// maxForecast is the longest forecast, volumes filling up later are
+	// considered not to fill up.
+	maxForecast = 365 * 24 * time.Hour
+)
+
+// usageSample is the used bytes of a volume at a time.
+type usageSample struct {
+	time      time.Time
+	usedBytes float64
+}
+
+// VolumeHistory keeps the used bytes of PVCs over a window to forecast when
+// they fill up.
+type VolumeHistory struct {
+	provider kubelet.SummaryProvider
+	window   time.Duration
+
+	mu      sync.Mutex
+	samples map[v1alpha1.PVCReference][]usageSample
+}
+
+// NewVolumeHistory creates a history of the PVCs in the summaries of
+// provider, keeping samples for window.
+func NewVolumeHistory(provider kubelet.SummaryProvider, window time.Duration) *VolumeHistory {
+	return &VolumeHistory{
+		provider: provider,
+		window:   window,
+		samples:  map[v1alpha1.PVCReference][]usageSample{},
+	}
+}
+
+// Run samples every HistoryInterval until stopCh is closed.
+func (h *VolumeHistory) Run(stopCh <-chan struct{}) {
+	wait.Until(h.sample, HistoryInterval, stopCh)
+}
+
+func (h *VolumeHistory) sample() {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+	summary, err := h.provider.GetSummary(ctx)
+	if err != nil {
+		glog.Errorf("failed to get stats summary: %v", err)
+		return
+	}
+	now := time.Now()
+	h.Record(Volumes(summary, nil, now), now)
+}
+
+// Record records the used bytes of volumes at now. Samples older than the
+// window are forgotten, so are volumes without samples left, but a volume
+// missing from a single summary keeps its history.
+func (h *VolumeHistory) Record(volumes []Volume, now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i := range volumes {
+		v := &volumes[i]
+		if v.UsedBytes == nil || v.Time.IsZero() {
+			continue
+		}
+		ref := v1alpha1.PVCReference{Namespace: v.Namespace, Name: v.PersistentVolumeClaim}
+		s := h.samples[ref]
+		// The kubelet may not have refreshed the stats since the last sample.
+		if n := len(s); n == 0 || v.Time.After(s[n-1].time) {
+			h.samples[ref] = append(s, usageSample{time: v.Time, usedBytes: float64(*v.UsedBytes)})
+		}
+	}
+	for ref, s := range h.samples {
+		for len(s) > 0 && now.Sub(s[0].time) > h.window {
+			s = s[1:]
+		}
+		if len(s) == 0 {
+			delete(h.samples, ref)
+		} else {
+			h.samples[ref] = s
+		}
+	}
+}
+
+// Forecast returns how long after its latest sample the volume is forecast
+// to fill its capacity, by a linear fit of its used bytes over the window.
+// ok is false if there are too few samples, or the usage doesn't grow
+// enough to fill the volume within a year.
+func (h *VolumeHistory) Forecast(v *Volume) (fill time.Duration, ok bool) {
+	if h == nil || v.CapacityBytes == nil {
+		return 0, false
+	}
+	h.mu.Lock()
+	s := h.samples[v1alpha1.PVCReference{Namespace: v.Namespace, Name: v.PersistentVolumeClaim}]
+	h.mu.Unlock()
+	if len(s) < 3 || s[len(s)-1].time.Sub(s[0].time) < minForecastSpan {
+		return 0, false
+	}
+
+	// Least squares fit of used bytes over seconds since the first sample.
+	var sumX, sumY, sumXX, sumXY float64
+	for _, sample := range s {
+		x := sample.time.Sub(s[0].time).Seconds()
+		sumX += x
+		sumY += sample.usedBytes
+		sumXX += x * x
+		sumXY += x * sample.usedBytes
+	}
+	n := float64(len(s))
+	denominator := n*sumXX - sumX*sumX
+	if denominator == 0 {
+		return 0, false
+	}
+	slope := (n*sumXY - sumX*sumY) / denominator
+	if slope <= 0 {
+		return 0, false
+	}
+	intercept := (sumY - slope*sumX) / n
+	last := s[len(s)-1].time.Sub(s[0].time).Seconds()
+	remaining := float64(*v.CapacityBytes) - (intercept + slope*last)
+	if remaining <= 0 {
+		return 0, true
+	}
+	if seconds := remaining / slope; seconds < maxForecast.Seconds() {
+		return time.Duration(seconds * float64(time.Second)), true
+	}
+	return 0, false
+}
@@ -0,0 +1,60 @@
+package api
+
+import (
+	"testing"
+	"time"
+
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+// newVolume creates a volume of the PVC data in team-a of 1000 bytes with
+// usedBytes used at t.
+func newVolume(t time.Time, usedBytes uint64) Volume {
+	capacity := uint64(1000)
+	return Volume{
+		Namespace:             "team-a",
+		PersistentVolumeClaim: "data",
+		Usage:                 Usage{CapacityBytes: &capacity, UsedBytes: &usedBytes, Time: t},
+	}
+}
+
+func TestVolumeHistoryForecast(t *testing.T) {
+	h := NewVolumeHistory(nil, time.Hour)
+	start := time.Unix(100000, 0)
+	// 10 bytes a minute, the volume is missing from every third summary.
+	for i := 0; i <= 30; i++ {
+		now := start.Add(time.Duration(i) * time.Minute)
+		var volumes []Volume
+		if i%3 != 2 {
+			volumes = append(volumes, newVolume(now, uint64(100+10*i)))
+		}
+		h.Record(volumes, now)
+	}
+	v := newVolume(start.Add(30*time.Minute), 400)
+	fill, ok := h.Forecast(&v)
+	if !ok {
+		t.Fatal("got no forecast of a growing volume")
+	}
+	if want := 60 * time.Minute; fill < want-time.Second || fill > want+time.Second {
+		t.Errorf("got fill time %v, want %v", fill, want)
+	}
+}
+
+func TestVolumeHistoryWindow(t *testing.T) {
+	h := NewVolumeHistory(nil, 10*time.Minute)
+	start := time.Unix(100000, 0)
+	h.Record([]Volume{newVolume(start, 100)}, start)
+	// Not refreshed by the kubelet.
+	h.Record([]Volume{newVolume(start, 100)}, start.Add(time.Minute))
+	h.Record(nil, start.Add(2*time.Minute))
+	if n := len(h.samples); n != 1 {
+		t.Fatalf("got %d volumes with samples, want the missing one kept", n)
+	}
+	if n := len(h.samples[v1alpha1.PVCReference{Namespace: "team-a", Name: "data"}]); n != 1 {
+		t.Errorf("got %d samples, want 1", n)
+	}
+	h.Record(nil, start.Add(11*time.Minute))
+	if n := len(h.samples); n != 0 {
+		t.Errorf("got %d volumes with samples after the window, want none", n)
+	}
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+// Authenticator identifies the tenant of a request.
+type Authenticator struct {
+	tenants []Tenant
+}
+
+// NewAuthenticator creates an authenticator for the tenants of config.
+func NewAuthenticator(config *Config) *Authenticator {
+	return &Authenticator{tenants: config.Tenants}
+}
+
+// Authenticate returns the tenant matching the credentials of r. Verified
+// client certificates take precedence over bearer tokens, which take
+// precedence over basic auth.
+func (a *Authenticator) Authenticate(r *http.Request) (*Tenant, bool) {
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 && len(r.TLS.VerifiedChains[0]) > 0 {
+		cn := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		for i := range a.tenants {
+			if a.tenants[i].ClientCertCommonName != "" && a.tenants[i].ClientCertCommonName == cn {
+				return &a.tenants[i], true
+			}
+		}
+	}
+	if h := r.Header.Get("Authorization"); strings.HasPrefix(h, "Bearer ") {
+		token := strings.TrimSpace(strings.TrimPrefix(h, "Bearer "))
+		for i := range a.tenants {
+			if a.tenants[i].BearerToken != "" && secureCompare(a.tenants[i].BearerToken, token) {
+				return &a.tenants[i], true
+			}
+		}
+		return nil, false
+	}
+	if username, password, ok := r.BasicAuth(); ok {
+		for i := range a.tenants {
+			ba := a.tenants[i].BasicAuth
+			if ba != nil && ba.Username == username && secureCompare(ba.Password, password) {
+				return &a.tenants[i], true
+			}
+		}
+	}
+	return nil, false
+}
+
+func secureCompare(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
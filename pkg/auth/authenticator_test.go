@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"testing"
+)
+
+func TestAuthenticate(t *testing.T) {
+	authenticator := NewAuthenticator(&Config{Tenants: []Tenant{
+		{Name: "basic", BasicAuth: &BasicAuth{Username: "alice", Password: "secret"}},
+		{Name: "bearer", BearerToken: "token"},
+		{Name: "cert", ClientCertCommonName: "prometheus"},
+	}})
+	verifiedCert := func(cn string) *tls.ConnectionState {
+		return &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{Subject: pkix.Name{CommonName: cn}}}}}
+	}
+	tests := []struct {
+		name     string
+		tls      *tls.ConnectionState
+		header   string
+		username string
+		password string
+		// tenant is empty if the request is not authenticated.
+		tenant string
+	}{
+		{name: "anonymous"},
+		{name: "basic auth", username: "alice", password: "secret", tenant: "basic"},
+		{name: "wrong password", username: "alice", password: "wrong"},
+		{name: "unknown user", username: "bob", password: "secret"},
+		{name: "bearer token", header: "Bearer token", tenant: "bearer"},
+		{name: "bearer token with spaces", header: "Bearer  token ", tenant: "bearer"},
+		// Not falling through to basic auth.
+		{name: "wrong bearer token", header: "Bearer wrong"},
+		{name: "basic auth credentials as bearer token", header: "Bearer secret"},
+		{name: "empty bearer token", header: "Bearer "},
+		{name: "client certificate", tls: verifiedCert("prometheus"), tenant: "cert"},
+		{name: "unknown client certificate", tls: verifiedCert("other")},
+		{name: "unverified client certificate", tls: &tls.ConnectionState{PeerCertificates: []*x509.Certificate{{Subject: pkix.Name{CommonName: "prometheus"}}}}},
+		{name: "certificate over bearer token", tls: verifiedCert("prometheus"), header: "Bearer token", tenant: "cert"},
+		{name: "bearer token after unknown certificate", tls: verifiedCert("other"), header: "Bearer token", tenant: "bearer"},
+		{name: "certificate over basic auth", tls: verifiedCert("prometheus"), username: "alice", password: "secret", tenant: "cert"},
+	}
+	for _, test := range tests {
+		r, err := http.NewRequest("GET", "/metrics", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.TLS = test.tls
+		if test.username != "" {
+			r.SetBasicAuth(test.username, test.password)
+		}
+		if test.header != "" {
+			r.Header.Set("Authorization", test.header)
+		}
+		tenant, ok := authenticator.Authenticate(r)
+		var got string
+		if ok {
+			got = tenant.Name
+		}
+		if got != test.tenant {
+			t.Errorf("%s: got tenant %q, want %q", test.name, got, test.tenant)
+		}
+	}
+}
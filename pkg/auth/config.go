@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/ghodss/yaml"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+// Config maps authenticated identities to the namespaces they may see.
+type Config struct {
+	Tenants []Tenant `json:"tenants"`
+}
+
+// Tenant is an identity scraping the exporter. A tenant is matched by any of
+// its credentials and sees only series of the namespaces it is allowed.
+type Tenant struct {
+	Name string `json:"name"`
+
+	// BasicAuth matches requests carrying these basic auth credentials.
+	BasicAuth *BasicAuth `json:"basicAuth,omitempty"`
+	// BearerToken matches requests carrying "Authorization: Bearer <token>".
+	BearerToken string `json:"bearerToken,omitempty"`
+	// ClientCertCommonName matches requests presenting a verified client
+	// certificate with this common name.
+	ClientCertCommonName string `json:"clientCertCommonName,omitempty"`
+
+	// AllNamespaces grants access to every series, including node level ones.
+	AllNamespaces bool `json:"allNamespaces,omitempty"`
+	// Namespaces is a static list of allowed namespaces.
+	Namespaces []string `json:"namespaces,omitempty"`
+	// NamespaceSelector is a label selector of allowed namespaces, resolved
+	// against the API server.
+	NamespaceSelector string `json:"namespaceSelector,omitempty"`
+
+	// Debug grants access to the debug handlers. Debug handlers expose data
+	// of every namespace, grant it to administrators only.
+	Debug bool `json:"debug,omitempty"`
+}
+
+// BasicAuth holds basic auth credentials.
+type BasicAuth struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+// LoadConfig reads and validates a tenant configuration file.
+func LoadConfig(filename string) (*Config, error) {
+	data, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	config := &Config{}
+	if err := yaml.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", filename, err)
+	}
+	if err := config.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid %s: %v", filename, err)
+	}
+	return config, nil
+}
+
+// Validate checks that every tenant has credentials and a namespace scope,
+// and that no two tenants share a credential.
+func (c *Config) Validate() error {
+	names := map[string]bool{}
+	usernames := map[string]bool{}
+	tokens := map[string]bool{}
+	commonNames := map[string]bool{}
+	for i, t := range c.Tenants {
+		if t.Name == "" {
+			return fmt.Errorf("tenants[%d]: name is required", i)
+		}
+		if names[t.Name] {
+			return fmt.Errorf("tenant %q: duplicate name", t.Name)
+		}
+		names[t.Name] = true
+		if t.BasicAuth == nil && t.BearerToken == "" && t.ClientCertCommonName == "" {
+			return fmt.Errorf("tenant %q: one of basicAuth, bearerToken or clientCertCommonName is required", t.Name)
+		}
+		if t.BasicAuth != nil {
+			if t.BasicAuth.Username == "" || t.BasicAuth.Password == "" {
+				return fmt.Errorf("tenant %q: basicAuth requires username and password", t.Name)
+			}
+			if usernames[t.BasicAuth.Username] {
+				return fmt.Errorf("tenant %q: basic auth username %q is already used", t.Name, t.BasicAuth.Username)
+			}
+			usernames[t.BasicAuth.Username] = true
+		}
+		if t.BearerToken != "" {
+			if tokens[t.BearerToken] {
+				return fmt.Errorf("tenant %q: bearer token is already used", t.Name)
+			}
+			tokens[t.BearerToken] = true
+		}
+		if t.ClientCertCommonName != "" {
+			if commonNames[t.ClientCertCommonName] {
+				return fmt.Errorf("tenant %q: client certificate common name %q is already used", t.Name, t.ClientCertCommonName)
+			}
+			commonNames[t.ClientCertCommonName] = true
+		}
+		if !t.AllNamespaces && len(t.Namespaces) == 0 && t.NamespaceSelector == "" {
+			return fmt.Errorf("tenant %q: one of allNamespaces, namespaces or namespaceSelector is required", t.Name)
+		}
+		if t.NamespaceSelector != "" {
+			if _, err := labels.Parse(t.NamespaceSelector); err != nil {
+				return fmt.Errorf("tenant %q: invalid namespaceSelector: %v", t.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// HasDebugTenant returns true if any tenant may access the debug handlers.
+func (c *Config) HasDebugTenant() bool {
+	for _, t := range c.Tenants {
+		if t.Debug {
+			return true
+		}
+	}
+	return false
+}
+
+// NeedsAPIServer returns true if any tenant uses a namespace selector.
+func (c *Config) NeedsAPIServer() bool {
+	for _, t := range c.Tenants {
+		if t.NamespaceSelector != "" && !t.AllNamespaces {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,100 @@
+package auth
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigValidate(t *testing.T) {
+	basic := func(username string) *BasicAuth { return &BasicAuth{Username: username, Password: "secret"} }
+	tests := []struct {
+		name    string
+		tenants []Tenant
+		// err is a substring of the error, empty if the config is valid.
+		err string
+	}{
+		{name: "no tenants"},
+		{name: "valid", tenants: []Tenant{
+			{Name: "admin", BasicAuth: basic("admin"), AllNamespaces: true, Debug: true},
+			{Name: "team-a", BearerToken: "token-a", Namespaces: []string{"team-a"}},
+			{Name: "team-b", ClientCertCommonName: "team-b", NamespaceSelector: "team=b"},
+		}},
+		{name: "missing name", tenants: []Tenant{{BearerToken: "token", AllNamespaces: true}}, err: "name is required"},
+		{name: "duplicate name", tenants: []Tenant{
+			{Name: "a", BearerToken: "token-1", AllNamespaces: true},
+			{Name: "a", BearerToken: "token-2", AllNamespaces: true},
+		}, err: "duplicate name"},
+		{name: "no credentials", tenants: []Tenant{{Name: "a", AllNamespaces: true}}, err: "one of basicAuth, bearerToken or clientCertCommonName is required"},
+		{name: "basic auth without password", tenants: []Tenant{{Name: "a", BasicAuth: &BasicAuth{Username: "a"}, AllNamespaces: true}}, err: "requires username and password"},
+		{name: "duplicate username", tenants: []Tenant{
+			{Name: "a", BasicAuth: basic("user"), AllNamespaces: true},
+			{Name: "b", BasicAuth: basic("user"), AllNamespaces: true},
+		}, err: `username "user" is already used`},
+		{name: "duplicate bearer token", tenants: []Tenant{
+			{Name: "a", BearerToken: "token", AllNamespaces: true},
+			{Name: "b", BearerToken: "token", AllNamespaces: true},
+		}, err: "bearer token is already used"},
+		{name: "duplicate common name", tenants: []Tenant{
+			{Name: "a", ClientCertCommonName: "cn", AllNamespaces: true},
+			{Name: "b", ClientCertCommonName: "cn", AllNamespaces: true},
+		}, err: `common name "cn" is already used`},
+		{name: "no namespace scope", tenants: []Tenant{{Name: "a", BearerToken: "token"}}, err: "one of allNamespaces, namespaces or namespaceSelector is required"},
+		{name: "invalid selector", tenants: []Tenant{{Name: "a", BearerToken: "token", NamespaceSelector: "team in (a"}}, err: "invalid namespaceSelector"},
+	}
+	for _, test := range tests {
+		err := (&Config{Tenants: test.tenants}).Validate()
+		switch {
+		case test.err == "" && err != nil:
+			t.Errorf("%s: got error %v, want none", test.name, err)
+		case test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)):
+			t.Errorf("%s: got error %v, want one containing %q", test.name, err, test.err)
+		}
+	}
+}
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "auth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	write := func(name, data string) string {
+		path := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	config, err := LoadConfig(write("valid.yaml", `
+tenants:
+- name: admin
+  basicAuth:
+    username: admin
+    password: secret
+  allNamespaces: true
+  debug: true
+- name: team-a
+  bearerToken: token-a
+  namespaceSelector: team=a
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(config.Tenants) != 2 || config.Tenants[0].BasicAuth.Password != "secret" || config.Tenants[1].NamespaceSelector != "team=a" {
+		t.Errorf("got tenants %+v", config.Tenants)
+	}
+	if !config.HasDebugTenant() || !config.NeedsAPIServer() {
+		t.Errorf("got debug tenant %v and API server needed %v, want both", config.HasDebugTenant(), config.NeedsAPIServer())
+	}
+
+	if _, err := LoadConfig(write("invalid.yaml", "tenants:\n- name: a\n")); err == nil || !strings.Contains(err.Error(), "invalid") {
+		t.Errorf("got error %v of an invalid config", err)
+	}
+	if _, err := LoadConfig(write("malformed.yaml", "tenants: {")); err == nil || !strings.Contains(err.Error(), "failed to parse") {
+		t.Errorf("got error %v of a malformed config", err)
+	}
+}
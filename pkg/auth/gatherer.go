@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// namespaceLabel is the label carrying the namespace of a series.
+const namespaceLabel = "namespace"
+
+// namespaceGatherer drops series not belonging to an allowed namespace.
+type namespaceGatherer struct {
+	gatherer prometheus.Gatherer
+	allowed  map[string]bool
+}
+
+// NewNamespaceGatherer wraps gatherer so only series whose namespace label is
+// in allowed are returned. Series without a namespace label are node level
+// and dropped as well. A nil allowed set returns everything.
+func NewNamespaceGatherer(gatherer prometheus.Gatherer, allowed map[string]bool) prometheus.Gatherer {
+	if allowed == nil {
+		return gatherer
+	}
+	return &namespaceGatherer{gatherer: gatherer, allowed: allowed}
+}
+
+// Gather implements the prometheus.Gatherer interface.
+func (g *namespaceGatherer) Gather() ([]*dto.MetricFamily, error) {
+	mfs, err := g.gatherer.Gather()
+	filtered := make([]*dto.MetricFamily, 0, len(mfs))
+	for _, mf := range mfs {
+		var metrics []*dto.Metric
+		for _, m := range mf.Metric {
+			if g.allowed[namespaceOf(m)] {
+				metrics = append(metrics, m)
+			}
+		}
+		if len(metrics) == 0 {
+			continue
+		}
+		mf.Metric = metrics
+		filtered = append(filtered, mf)
+	}
+	return filtered, err
+}
+
+func namespaceOf(m *dto.Metric) string {
+	for _, lp := range m.Label {
+		if lp.GetName() == namespaceLabel {
+			return lp.GetValue()
+		}
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package auth
+
+import (
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+func newTestRegistry() *prometheus.Registry {
+	used := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kubelet_volume_stats_used_bytes",
+		Help: "Number of used bytes in the volume",
+	}, []string{"namespace", "persistentvolumeclaim"})
+	used.WithLabelValues("team-a", "data").Set(1)
+	used.WithLabelValues("team-b", "data").Set(2)
+	// Unbound volumes have an empty namespace.
+	used.WithLabelValues("", "").Set(3)
+	dropped := prometheus.NewCounter(prometheus.CounterOpts{
+		Name: "kubelet_exporter_series_dropped_total",
+		Help: "Number of series dropped",
+	})
+	dropped.Add(4)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(used, dropped)
+	return registry
+}
+
+func TestNamespaceGatherer(t *testing.T) {
+	tests := []struct {
+		name    string
+		allowed map[string]bool
+		// series are the families and namespaces of the series returned.
+		series []string
+	}{
+		{
+			name:    "all namespaces, including node level series",
+			allowed: nil,
+			series: []string{
+				"kubelet_exporter_series_dropped_total/",
+				"kubelet_volume_stats_used_bytes/",
+				"kubelet_volume_stats_used_bytes/team-a",
+				"kubelet_volume_stats_used_bytes/team-b",
+			},
+		},
+		{
+			name:    "one namespace, without node level series",
+			allowed: map[string]bool{"team-a": true},
+			series:  []string{"kubelet_volume_stats_used_bytes/team-a"},
+		},
+		{
+			name:    "two namespaces",
+			allowed: map[string]bool{"team-a": true, "team-b": true, "team-c": true},
+			series:  []string{"kubelet_volume_stats_used_bytes/team-a", "kubelet_volume_stats_used_bytes/team-b"},
+		},
+		{
+			name:    "no namespaces",
+			allowed: map[string]bool{},
+		},
+	}
+	for _, test := range tests {
+		families, err := NewNamespaceGatherer(newTestRegistry(), test.allowed).Gather()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var series []string
+		for _, family := range families {
+			if len(family.Metric) == 0 {
+				t.Errorf("%s: got empty family %s", test.name, family.GetName())
+			}
+			for _, m := range family.Metric {
+				series = append(series, family.GetName()+"/"+namespaceOf(m))
+			}
+		}
+		sort.Strings(series)
+		if !reflect.DeepEqual(series, test.series) {
+			t.Errorf("%s: got series %v, want %v", test.name, series, test.series)
+		}
+	}
+}
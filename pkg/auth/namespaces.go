@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kube"
+)
+
+// NamespaceLister lists namespaces by label selector.
+type NamespaceLister interface {
+	ListNamespaces(ctx context.Context, labelSelector string) (*kube.NamespaceList, error)
+}
+
+// NamespaceResolver resolves the namespaces a tenant may see. Results of
+// namespace selectors are cached for a while, scrapes must not hit the API
+// server every time.
+type NamespaceResolver struct {
+	lister NamespaceLister
+	ttl    time.Duration
+
+	mu    sync.Mutex
+	cache map[string]selectorResult
+}
+
+type selectorResult struct {
+	namespaces []string
+	expiry     time.Time
+}
+
+// NewNamespaceResolver creates a resolver. lister may be nil if no tenant
+// uses a namespace selector.
+func NewNamespaceResolver(lister NamespaceLister, ttl time.Duration) *NamespaceResolver {
+	return &NamespaceResolver{
+		lister: lister,
+		ttl:    ttl,
+		cache:  map[string]selectorResult{},
+	}
+}
+
+// Resolve returns the set of namespaces the tenant may see, or nil if it may
+// see everything.
+func (r *NamespaceResolver) Resolve(ctx context.Context, tenant *Tenant) (map[string]bool, error) {
+	if tenant.AllNamespaces {
+		return nil, nil
+	}
+	allowed := map[string]bool{}
+	for _, ns := range tenant.Namespaces {
+		allowed[ns] = true
+	}
+	if tenant.NamespaceSelector != "" {
+		namespaces, err := r.selectNamespaces(ctx, tenant.NamespaceSelector)
+		if err != nil {
+			return nil, err
+		}
+		for _, ns := range namespaces {
+			allowed[ns] = true
+		}
+	}
+	return allowed, nil
+}
+
+func (r *NamespaceResolver) selectNamespaces(ctx context.Context, selector string) ([]string, error) {
+	if r.lister == nil {
+		return nil, fmt.Errorf("no API server client to resolve namespace selector %q", selector)
+	}
+	r.mu.Lock()
+	result, ok := r.cache[selector]
+	r.mu.Unlock()
+	if ok && time.Now().Before(result.expiry) {
+		return result.namespaces, nil
+	}
+
+	list, err := r.lister.ListNamespaces(ctx, selector)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces matching %q: %v", selector, err)
+	}
+	namespaces := make([]string, 0, len(list.Items))
+	for _, ns := range list.Items {
+		namespaces = append(namespaces, ns.Name)
+	}
+	r.mu.Lock()
+	r.cache[selector] = selectorResult{namespaces: namespaces, expiry: time.Now().Add(r.ttl)}
+	r.mu.Unlock()
+	return namespaces, nil
+}
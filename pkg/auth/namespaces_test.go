@@ -0,0 +1,78 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kube"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// fakeNamespaceLister lists namespaces by selector, counting the requests.
+type fakeNamespaceLister struct {
+	bySelector map[string][]string
+	err        error
+	lists      int
+}
+
+func (l *fakeNamespaceLister) ListNamespaces(ctx context.Context, labelSelector string) (*kube.NamespaceList, error) {
+	l.lists++
+	if l.err != nil {
+		return nil, l.err
+	}
+	list := &kube.NamespaceList{}
+	for _, name := range l.bySelector[labelSelector] {
+		list.Items = append(list.Items, kube.Namespace{ObjectMeta: metav1.ObjectMeta{Name: name}})
+	}
+	return list, nil
+}
+
+func TestNamespaceResolver(t *testing.T) {
+	lister := &fakeNamespaceLister{bySelector: map[string][]string{
+		"team=a":    {"team-a", "team-a-staging"},
+		"team=none": nil,
+	}}
+	resolver := NewNamespaceResolver(lister, time.Hour)
+	tests := []struct {
+		name    string
+		tenant  Tenant
+		allowed map[string]bool
+	}{
+		{name: "all namespaces", tenant: Tenant{AllNamespaces: true, Namespaces: []string{"team-a"}}},
+		{name: "static namespaces", tenant: Tenant{Namespaces: []string{"team-a", "team-b"}}, allowed: map[string]bool{"team-a": true, "team-b": true}},
+		{name: "selector", tenant: Tenant{NamespaceSelector: "team=a"}, allowed: map[string]bool{"team-a": true, "team-a-staging": true}},
+		{name: "selector and static namespaces", tenant: Tenant{Namespaces: []string{"team-b"}, NamespaceSelector: "team=a"}, allowed: map[string]bool{"team-a": true, "team-a-staging": true, "team-b": true}},
+		{name: "selector matching nothing", tenant: Tenant{NamespaceSelector: "team=none"}, allowed: map[string]bool{}},
+	}
+	for _, test := range tests {
+		allowed, err := resolver.Resolve(context.Background(), &test.tenant)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(allowed, test.allowed) {
+			t.Errorf("%s: got namespaces %v, want %v", test.name, allowed, test.allowed)
+		}
+	}
+	// The selectors are cached.
+	if lister.lists != 2 {
+		t.Errorf("got %d namespace lists, want 2", lister.lists)
+	}
+}
+
+func TestNamespaceResolverErrors(t *testing.T) {
+	tenant := &Tenant{Namespaces: []string{"team-b"}, NamespaceSelector: "team=a"}
+	if _, err := NewNamespaceResolver(nil, time.Hour).Resolve(context.Background(), tenant); err == nil {
+		t.Error("got no error resolving a selector without API server client")
+	}
+	// A failed selector fails the resolution, the static namespaces alone
+	// aren't what the tenant may see.
+	lister := &fakeNamespaceLister{err: fmt.Errorf("forbidden")}
+	allowed, err := NewNamespaceResolver(lister, time.Hour).Resolve(context.Background(), tenant)
+	if err == nil || allowed != nil {
+		t.Errorf("got namespaces %v and error %v, want an error", allowed, err)
+	}
+}
@@ -0,0 +1,106 @@
+package collectors
+
+import (
+	"sort"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	seriesDroppedKey = "kubelet_exporter_series_dropped_total"
+
+	// otherBucket is the label value of the series the items exceeding a
+	// budget are rolled into. Object names can't contain underscores, so it
+	// never collides with a real PVC, pod or container.
+	otherBucket = "__other__"
+)
+
+var seriesDropped = prometheus.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: seriesDroppedKey,
+		Help: "Number of series not exported because the collector exceeded its series budget",
+	},
+	[]string{"collector"},
+)
+
+// Budget limits the number of series a collector builds. If a collector has
+// more items than fit, only the top items by RankBy are exported and the
+// rest is rolled into a single otherBucket item.
+type Budget struct {
+	// MaxSeries is the maximum number of series, zero means unlimited.
+	MaxSeries int
+	// RankBy is the value items are ranked by, see the rank keys of each
+	// collector.
+	RankBy string
+}
+
+// split returns the indexes of the items to export individually and of the
+// items to roll into the other bucket of each namespace. values are the
+// values items are ranked by, namespaces the namespaces of the items. Each
+// other bucket takes the series of one item and is kept in the namespace of
+// its items, so that scrapes of a namespace see it. If there are more of
+// these namespaces than fit, the items are rolled into a single other bucket
+// with an empty namespace instead.
+func (b *Budget) split(values []float64, namespaces []string, seriesPerItem int) (keep []int, others map[string][]int) {
+	indexes := make([]int, len(values))
+	for i := range indexes {
+		indexes[i] = i
+	}
+	if b == nil || b.MaxSeries <= 0 || seriesPerItem == 0 || len(values)*seriesPerItem <= b.MaxSeries {
+		return indexes, nil
+	}
+	sort.SliceStable(indexes, func(i, j int) bool {
+		return values[indexes[i]] > values[indexes[j]]
+	})
+	// bucketsFrom[n] is the number of other buckets if the first n items
+	// are kept.
+	bucketsFrom := make([]int, len(indexes)+1)
+	seen := map[string]bool{}
+	for n := len(indexes) - 1; n >= 0; n-- {
+		bucketsFrom[n] = bucketsFrom[n+1]
+		if ns := namespaces[indexes[n]]; !seen[ns] {
+			seen[ns] = true
+			bucketsFrom[n]++
+		}
+	}
+	slots := b.MaxSeries / seriesPerItem
+	n := -1
+	for i := slots; i >= 0; i-- {
+		if i < len(indexes) && i+bucketsFrom[i] <= slots {
+			n = i
+			break
+		}
+	}
+	others = map[string][]int{}
+	if n < 0 {
+		n = slots - 1
+		if n < 0 {
+			n = 0
+		}
+		others[""] = indexes[n:]
+	} else {
+		for _, i := range indexes[n:] {
+			others[namespaces[i]] = append(others[namespaces[i]], i)
+		}
+	}
+	keep = indexes[:n]
+	sort.Ints(keep)
+	return keep, others
+}
+
+// otherNamespaces returns the namespaces of the other buckets in order.
+func otherNamespaces(others map[string][]int) []string {
+	namespaces := make([]string, 0, len(others))
+	for ns := range others {
+		namespaces = append(namespaces, ns)
+	}
+	sort.Strings(namespaces)
+	return namespaces
+}
+
+// recordDropped counts series dropped by collector.
+func recordDropped(collector string, series int) {
+	if series > 0 {
+		seriesDropped.WithLabelValues(collector).Add(float64(series))
+	}
+}
@@ -0,0 +1,61 @@
+package collectors
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBudgetSplit(t *testing.T) {
+	tests := []struct {
+		name       string
+		budget     *Budget
+		values     []float64
+		namespaces []string
+		keep       []int
+		others     map[string][]int
+	}{
+		{
+			name:       "no budget",
+			values:     []float64{1, 2},
+			namespaces: []string{"a", "b"},
+			keep:       []int{0, 1},
+		},
+		{
+			name:       "within budget",
+			budget:     &Budget{MaxSeries: 4},
+			values:     []float64{1, 2},
+			namespaces: []string{"a", "b"},
+			keep:       []int{0, 1},
+		},
+		{
+			name:       "other bucket per namespace",
+			budget:     &Budget{MaxSeries: 4},
+			values:     []float64{1, 5, 3, 2, 4},
+			namespaces: []string{"a", "a", "b", "b", "a"},
+			keep:       []int{1, 4},
+			others:     map[string][]int{"a": {0}, "b": {2, 3}},
+		},
+		{
+			name:       "other bucket of the top namespace",
+			budget:     &Budget{MaxSeries: 4},
+			values:     []float64{1, 5, 3, 2, 4},
+			namespaces: []string{"a", "a", "a", "a", "b"},
+			keep:       []int{1, 2, 4},
+			others:     map[string][]int{"a": {3, 0}},
+		},
+		{
+			name:       "more namespaces than buckets",
+			budget:     &Budget{MaxSeries: 2},
+			values:     []float64{1, 5, 3},
+			namespaces: []string{"a", "b", "c"},
+			keep:       []int{1},
+			others:     map[string][]int{"": {2, 0}},
+		},
+	}
+	for _, test := range tests {
+		keep, others := test.budget.split(test.values, test.namespaces, 1)
+		if !reflect.DeepEqual(keep, test.keep) || !reflect.DeepEqual(others, test.others) {
+			t.Errorf("%s: got %v and others %v, want %v and others %v", test.name, keep, others, test.keep, test.others)
+		}
+	}
+}
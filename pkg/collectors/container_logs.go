@@ -0,0 +1,173 @@
+package collectors
+
+import (
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/node"
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	containerLogRotatedFilesKey  = "kubelet_container_log_rotated_files"
+	containerLogBytesKey         = "kubelet_container_log_bytes"
+	containerLogNewestFileKey    = "kubelet_container_log_newest_file_bytes"
+	containerLogLastWriteAgeKey  = "kubelet_container_log_last_write_age_seconds"
+	containerLogOverThresholdKey = "kubelet_container_log_over_threshold"
+)
+
+var (
+	containerLogLabels = []string{"namespace", "pod", "container"}
+
+	containerLogRotatedFiles = prometheus.NewDesc(
+		containerLogRotatedFilesKey,
+		"Number of rotated log files of the container",
+		containerLogLabels, nil,
+	)
+	containerLogBytes = prometheus.NewDesc(
+		containerLogBytesKey,
+		"Total size of the log files of the container in bytes",
+		containerLogLabels, nil,
+	)
+	containerLogNewestFile = prometheus.NewDesc(
+		containerLogNewestFileKey,
+		"Size of the log file of the container being written in bytes",
+		containerLogLabels, nil,
+	)
+	containerLogLastWriteAge = prometheus.NewDesc(
+		containerLogLastWriteAgeKey,
+		"Number of seconds since the container last wrote to its logs",
+		containerLogLabels, nil,
+	)
+	containerLogOverThreshold = prometheus.NewDesc(
+		containerLogOverThresholdKey,
+		"Whether the total size of the log files of the container exceeds the configured threshold",
+		containerLogLabels, nil,
+	)
+)
+
+// ContainerLogsRankKeys are the values the container logs collector can rank
+// containers by when it exceeds its budget.
+var ContainerLogsRankKeys = []string{"bytes", "newest_file_bytes"}
+
+// containerLogsCollector collects the usage of the log files of containers
+// in the pod logs directory.
+type containerLogsCollector struct {
+	podLogsDir string
+	threshold  int64
+	filter     *Filter
+	budget     *Budget
+}
+
+// NewContainerLogsCollector creates a new container logs prometheus
+// collector. Containers with logs larger than threshold bytes are flagged.
+func NewContainerLogsCollector(podLogsDir string, threshold int64, filter *Filter, budget *Budget) prometheus.Collector {
+	return &containerLogsCollector{podLogsDir: podLogsDir, threshold: threshold, filter: filter, budget: budget}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (collector *containerLogsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- containerLogRotatedFiles
+	ch <- containerLogBytes
+	ch <- containerLogNewestFile
+	ch <- containerLogLastWriteAge
+	ch <- containerLogOverThreshold
+}
+
+// Collect implements the prometheus.Collector interface.
+func (collector *containerLogsCollector) Collect(ch chan<- prometheus.Metric) {
+	logs, err := node.ListContainerLogs(collector.podLogsDir)
+	if err != nil {
+		glog.Errorf("failed to list container logs: %v", err)
+		return
+	}
+
+	add := func(key string, desc *prometheus.Desc, v float64, lv ...string) {
+		if !collector.filter.Family(key) {
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
+	}
+
+	// A pod recreated with the same name, e.g. of a stateful set, has a
+	// directory per UID until the old one is removed.
+	type containerKey struct{ namespace, pod, container string }
+	merged := map[containerKey]*node.ContainerLogs{}
+	var keys []containerKey
+	for i := range logs {
+		l := &logs[i]
+		if !collector.filter.Namespace(l.Namespace) {
+			continue
+		}
+		key := containerKey{l.Namespace, l.Pod, l.Container}
+		m, ok := merged[key]
+		if !ok {
+			merged[key] = l
+			keys = append(keys, key)
+			continue
+		}
+		m.Files += l.Files
+		m.RotatedFiles += l.RotatedFiles
+		m.Bytes += l.Bytes
+		if l.LastWrite.After(m.LastWrite) {
+			m.LastWrite, m.NewestFileBytes = l.LastWrite, l.NewestFileBytes
+		}
+	}
+
+	families := 0
+	for _, key := range []string{containerLogRotatedFilesKey, containerLogBytesKey, containerLogNewestFileKey, containerLogLastWriteAgeKey, containerLogOverThresholdKey} {
+		if collector.filter.Family(key) {
+			families++
+		}
+	}
+	ranks := make([]float64, len(keys))
+	namespaces := make([]string, len(keys))
+	for i, key := range keys {
+		ranks[i] = containerLogsRank(merged[key], collector.budget)
+		namespaces[i] = key.namespace
+	}
+
+	now := time.Now()
+	keep, others := collector.budget.split(ranks, namespaces, families)
+	for _, i := range keep {
+		l := merged[keys[i]]
+		lv := []string{l.Namespace, l.Pod, l.Container}
+		add(containerLogRotatedFilesKey, containerLogRotatedFiles, float64(l.RotatedFiles), lv...)
+		add(containerLogBytesKey, containerLogBytes, float64(l.Bytes), lv...)
+		add(containerLogNewestFileKey, containerLogNewestFile, float64(l.NewestFileBytes), lv...)
+		if !l.LastWrite.IsZero() {
+			add(containerLogLastWriteAgeKey, containerLogLastWriteAge, now.Sub(l.LastWrite).Seconds(), lv...)
+		}
+		add(containerLogOverThresholdKey, containerLogOverThreshold, boolFloat64(collector.threshold > 0 && l.Bytes > uint64(collector.threshold)), lv...)
+	}
+	// The age and threshold of a sum mean nothing, the other buckets only
+	// have the sizes.
+	for _, namespace := range otherNamespaces(others) {
+		var rotated int
+		var bytes, newest uint64
+		for _, i := range others[namespace] {
+			l := merged[keys[i]]
+			rotated += l.RotatedFiles
+			bytes += l.Bytes
+			newest += l.NewestFileBytes
+		}
+		lv := []string{namespace, otherBucket, otherBucket}
+		add(containerLogRotatedFilesKey, containerLogRotatedFiles, float64(rotated), lv...)
+		add(containerLogBytesKey, containerLogBytes, float64(bytes), lv...)
+		add(containerLogNewestFileKey, containerLogNewestFile, float64(newest), lv...)
+		recordDropped("logs", len(others[namespace])*families)
+	}
+}
+
+// containerLogsRank returns the value the logs are ranked by in budget.
+func containerLogsRank(l *node.ContainerLogs, budget *Budget) float64 {
+	if budget == nil {
+		return 0
+	}
+	switch budget.RankBy {
+	case "newest_file_bytes":
+		return float64(l.NewestFileBytes)
+	default:
+		return float64(l.Bytes)
+	}
+}
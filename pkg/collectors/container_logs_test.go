@@ -0,0 +1,43 @@
+package collectors
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	collectorstesting "github.com/cofyc/kubelet-exporter/pkg/collectors/testing"
+)
+
+func TestContainerLogsCollectorMergesPodUIDs(t *testing.T) {
+	podLogsDir, err := ioutil.TempDir("", "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(podLogsDir)
+	// The pod was recreated, the directory of the old UID is not removed
+	// yet.
+	for _, uid := range []string{"uid-1", "uid-2"} {
+		dir := filepath.Join(podLogsDir, "team-a_web-0_"+uid, "app")
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range []string{"0.log", "0.log.20240101-000000.gz"} {
+			if err := ioutil.WriteFile(filepath.Join(dir, name), make([]byte, 10), 0600); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	expected := `
+		# HELP kubelet_container_log_bytes Total size of the log files of the container in bytes
+		# TYPE kubelet_container_log_bytes gauge
+		kubelet_container_log_bytes{container="app",namespace="team-a",pod="web-0"} 40
+		# HELP kubelet_container_log_rotated_files Number of rotated log files of the container
+		# TYPE kubelet_container_log_rotated_files gauge
+		kubelet_container_log_rotated_files{container="app",namespace="team-a",pod="web-0"} 2
+	`
+	if err := collectorstesting.GatherAndCompare(NewContainerLogsCollector(podLogsDir, 0, nil, nil), expected, []string{containerLogBytesKey, containerLogRotatedFilesKey}); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,236 @@
+package collectors
+
+import (
+	"context"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+const (
+	containerCPUUsageKey         = "kubelet_container_cpu_usage_seconds_total"
+	containerMemoryWorkingSetKey = "kubelet_container_memory_working_set_bytes"
+	containerMemoryUsageKey      = "kubelet_container_memory_usage_bytes"
+	containerMemoryRSSKey        = "kubelet_container_memory_rss_bytes"
+	containerRootfsUsedBytesKey  = "kubelet_container_rootfs_used_bytes"
+	containerRootfsInodesUsedKey = "kubelet_container_rootfs_inodes_used"
+)
+
+// containerStatsDescs are the descriptions of the container stats of a
+// source. The source is a constant label, so that collectors of several
+// sources can be registered together to cross-check them.
+type containerStatsDescs struct {
+	cpuUsage, cpuUsageCreated, memoryWorkingSet, memoryUsage, memoryRSS, rootfsUsedBytes, rootfsInodesUsed *prometheus.Desc
+}
+
+func newContainerStatsDescs(source string) *containerStatsDescs {
+	labels := []string{"namespace", "pod", "container"}
+	constLabels := prometheus.Labels{"source": source}
+	return &containerStatsDescs{
+		cpuUsage: prometheus.NewDesc(
+			containerCPUUsageKey,
+			"Cumulative CPU time consumed by the container in seconds",
+			labels, constLabels,
+		),
+		cpuUsageCreated: newCreatedDesc(containerCPUUsageKey, labels, constLabels),
+		memoryWorkingSet: prometheus.NewDesc(
+			containerMemoryWorkingSetKey,
+			"Working set memory of the container in bytes",
+			labels, constLabels,
+		),
+		memoryUsage: prometheus.NewDesc(
+			containerMemoryUsageKey,
+			"Memory usage of the container in bytes, including caches",
+			labels, constLabels,
+		),
+		memoryRSS: prometheus.NewDesc(
+			containerMemoryRSSKey,
+			"Anonymous and swap cache memory of the container in bytes",
+			labels, constLabels,
+		),
+		rootfsUsedBytes: prometheus.NewDesc(
+			containerRootfsUsedBytesKey,
+			"Number of bytes used by the writable layer of the container",
+			labels, constLabels,
+		),
+		rootfsInodesUsed: prometheus.NewDesc(
+			containerRootfsInodesUsedKey,
+			"Number of inodes used by the writable layer of the container",
+			labels, constLabels,
+		),
+	}
+}
+
+// ContainerStatsRankKeys are the values the container stats collectors can
+// rank containers by when they exceed their budget.
+var ContainerStatsRankKeys = []string{"memory_working_set_bytes", "cpu_usage_seconds", "rootfs_used_bytes"}
+
+// containerStatsCollector collects the CPU, memory and writable layer usage
+// of containers from a stats summary.
+type containerStatsCollector struct {
+	provider kubelet.SummaryProvider
+	// name is the name of the collector the series dropped by the budget
+	// are counted for.
+	name   string
+	descs  *containerStatsDescs
+	filter *Filter
+	budget *Budget
+	// timestamps, if not nil, attaches the times the stats were taken to the
+	// metrics.
+	timestamps *SampleTimestamps
+}
+
+// NewContainerStatsCollector creates a new container stats prometheus
+// collector. Metrics are labeled with source, e.g. kubelet or cri.
+func NewContainerStatsCollector(provider kubelet.SummaryProvider, source string, filter *Filter, budget *Budget, timestamps *SampleTimestamps) prometheus.Collector {
+	// The collector of the stats of the kubelet is named containers.
+	name := source
+	if source == "kubelet" {
+		name = "containers"
+	}
+	return &containerStatsCollector{provider: provider, name: name, descs: newContainerStatsDescs(source), filter: filter, budget: budget, timestamps: timestamps}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (collector *containerStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collector.descs.cpuUsage
+	ch <- collector.descs.cpuUsageCreated
+	ch <- collector.descs.memoryWorkingSet
+	ch <- collector.descs.memoryUsage
+	ch <- collector.descs.memoryRSS
+	ch <- collector.descs.rootfsUsedBytes
+	ch <- collector.descs.rootfsInodesUsed
+}
+
+// Collect implements the prometheus.Collector interface.
+func (collector *containerStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	statsSummary, err := collector.provider.GetSummary(ctx)
+	if err != nil {
+		glog.Errorf("failed to get stats summary: %v", err)
+		return
+	}
+
+	now := time.Now()
+	add := func(key string, desc *prometheus.Desc, valueType prometheus.ValueType, v *uint64, scale float64, t metav1.Time, lv ...string) {
+		if v == nil || !collector.filter.Family(key) {
+			return
+		}
+		if m := collector.timestamps.attach(prometheus.MustNewConstMetric(desc, valueType, float64(*v)*scale, lv...), t.Time, now); m != nil {
+			ch <- m
+		}
+	}
+	families := 0
+	for _, key := range []string{containerCPUUsageKey, containerMemoryWorkingSetKey, containerMemoryUsageKey, containerMemoryRSSKey, containerRootfsUsedBytesKey, containerRootfsInodesUsedKey} {
+		if collector.filter.Family(key) {
+			families++
+		}
+	}
+
+	var (
+		containers []*v1alpha1.ContainerStats
+		pods       []*v1alpha1.PodReference
+		ranks      []float64
+		namespaces []string
+	)
+	for i := range statsSummary.Pods {
+		podStats := &statsSummary.Pods[i]
+		if !collector.filter.Namespace(podStats.PodRef.Namespace) {
+			continue
+		}
+		for j := range podStats.Containers {
+			c := &podStats.Containers[j]
+			containers = append(containers, c)
+			pods = append(pods, &podStats.PodRef)
+			ranks = append(ranks, containerRank(c, collector.budget))
+			namespaces = append(namespaces, podStats.PodRef.Namespace)
+		}
+	}
+
+	d := collector.descs
+	keep, others := collector.budget.split(ranks, namespaces, families)
+	for _, i := range keep {
+		c := containers[i]
+		lv := []string{pods[i].Namespace, pods[i].Name, c.Name}
+		if c.CPU != nil {
+			add(containerCPUUsageKey, d.cpuUsage, prometheus.CounterValue, c.CPU.UsageCoreNanoSeconds, 1e-9, c.CPU.Time, lv...)
+			// The CPU usage of a container counts from its start.
+			if c.CPU.UsageCoreNanoSeconds != nil && !c.StartTime.IsZero() && collector.filter.Family(containerCPUUsageKey) {
+				ch <- prometheus.MustNewConstMetric(d.cpuUsageCreated, prometheus.GaugeValue, float64(c.StartTime.Unix()), lv...)
+			}
+		}
+		if c.Memory != nil {
+			add(containerMemoryWorkingSetKey, d.memoryWorkingSet, prometheus.GaugeValue, c.Memory.WorkingSetBytes, 1, c.Memory.Time, lv...)
+			add(containerMemoryUsageKey, d.memoryUsage, prometheus.GaugeValue, c.Memory.UsageBytes, 1, c.Memory.Time, lv...)
+			add(containerMemoryRSSKey, d.memoryRSS, prometheus.GaugeValue, c.Memory.RSSBytes, 1, c.Memory.Time, lv...)
+		}
+		if c.Rootfs != nil {
+			add(containerRootfsUsedBytesKey, d.rootfsUsedBytes, prometheus.GaugeValue, c.Rootfs.UsedBytes, 1, c.Rootfs.Time, lv...)
+			add(containerRootfsInodesUsedKey, d.rootfsInodesUsed, prometheus.GaugeValue, c.Rootfs.InodesUsed, 1, c.Rootfs.Time, lv...)
+		}
+	}
+	// The other buckets are sums taken while collecting, the CPU usage of a
+	// bucket resets when its containers change.
+	for _, namespace := range otherNamespaces(others) {
+		var cpu, workingSet, usage, rss, rootfsBytes, rootfsInodes uint64
+		for _, i := range others[namespace] {
+			c := containers[i]
+			if c.CPU != nil {
+				cpu += uint64Value(c.CPU.UsageCoreNanoSeconds)
+			}
+			if c.Memory != nil {
+				workingSet += uint64Value(c.Memory.WorkingSetBytes)
+				usage += uint64Value(c.Memory.UsageBytes)
+				rss += uint64Value(c.Memory.RSSBytes)
+			}
+			if c.Rootfs != nil {
+				rootfsBytes += uint64Value(c.Rootfs.UsedBytes)
+				rootfsInodes += uint64Value(c.Rootfs.InodesUsed)
+			}
+		}
+		lv := []string{namespace, otherBucket, otherBucket}
+		add(containerCPUUsageKey, d.cpuUsage, prometheus.CounterValue, &cpu, 1e-9, metav1.Time{}, lv...)
+		add(containerMemoryWorkingSetKey, d.memoryWorkingSet, prometheus.GaugeValue, &workingSet, 1, metav1.Time{}, lv...)
+		add(containerMemoryUsageKey, d.memoryUsage, prometheus.GaugeValue, &usage, 1, metav1.Time{}, lv...)
+		add(containerMemoryRSSKey, d.memoryRSS, prometheus.GaugeValue, &rss, 1, metav1.Time{}, lv...)
+		add(containerRootfsUsedBytesKey, d.rootfsUsedBytes, prometheus.GaugeValue, &rootfsBytes, 1, metav1.Time{}, lv...)
+		add(containerRootfsInodesUsedKey, d.rootfsInodesUsed, prometheus.GaugeValue, &rootfsInodes, 1, metav1.Time{}, lv...)
+		recordDropped(collector.name, len(others[namespace])*families)
+	}
+}
+
+// containerRank returns the value the container is ranked by in budget.
+func containerRank(c *v1alpha1.ContainerStats, budget *Budget) float64 {
+	if budget == nil {
+		return 0
+	}
+	var v *uint64
+	switch budget.RankBy {
+	case "cpu_usage_seconds":
+		if c.CPU != nil {
+			v = c.CPU.UsageCoreNanoSeconds
+		}
+	case "rootfs_used_bytes":
+		if c.Rootfs != nil {
+			v = c.Rootfs.UsedBytes
+		}
+	default:
+		if c.Memory != nil {
+			v = c.Memory.WorkingSetBytes
+		}
+	}
+	return float64(uint64Value(v))
+}
+
+func uint64Value(v *uint64) uint64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
@@ -0,0 +1,126 @@
+package collectors
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+	"time"
+
+	collectorstesting "github.com/cofyc/kubelet-exporter/pkg/collectors/testing"
+	"github.com/cofyc/kubelet-exporter/pkg/cri"
+	critesting "github.com/cofyc/kubelet-exporter/pkg/cri/testing"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+// newCRIContainer returns the stats of a container created by the kubelet.
+func newCRIContainer(namespace, pod, uid, name string, attempt uint32) *cri.ContainerStats {
+	return &cri.ContainerStats{Attributes: &cri.ContainerAttributes{
+		Metadata: &cri.ContainerMetadata{Name: name, Attempt: attempt},
+		Labels: map[string]string{
+			"io.kubernetes.pod.namespace":  namespace,
+			"io.kubernetes.pod.name":       pod,
+			"io.kubernetes.pod.uid":        uid,
+			"io.kubernetes.container.name": name,
+		},
+	}}
+}
+
+func TestContainerStatsCollectorCRI(t *testing.T) {
+	now := time.Now().UnixNano()
+	exited := newCRIContainer("team-a", "web-0", "uid-1", "app", 0)
+	exited.Cpu = &cri.CpuUsage{Timestamp: now, UsageCoreNanoSeconds: &cri.UInt64Value{Value: 9e9}}
+	app := newCRIContainer("team-a", "web-0", "uid-1", "app", 1)
+	app.Cpu = &cri.CpuUsage{Timestamp: now, UsageCoreNanoSeconds: &cri.UInt64Value{Value: 2.5e9}}
+	app.Memory = &cri.MemoryUsage{
+		Timestamp:       now,
+		WorkingSetBytes: &cri.UInt64Value{Value: 1000},
+		UsageBytes:      &cri.UInt64Value{Value: 1500},
+		RssBytes:        &cri.UInt64Value{Value: 800},
+	}
+	app.WritableLayer = &cri.FilesystemUsage{Timestamp: now, UsedBytes: &cri.UInt64Value{Value: 4096}, InodesUsed: &cri.UInt64Value{Value: 12}}
+	sidecar := newCRIContainer("team-b", "db-0", "uid-2", "proxy", 0)
+	sidecar.Memory = &cri.MemoryUsage{Timestamp: now, WorkingSetBytes: &cri.UInt64Value{Value: 200}}
+	// Not created by the kubelet.
+	other := &cri.ContainerStats{
+		Attributes: &cri.ContainerAttributes{Metadata: &cri.ContainerMetadata{Name: "buildkit"}},
+		Cpu:        &cri.CpuUsage{Timestamp: now, UsageCoreNanoSeconds: &cri.UInt64Value{Value: 1e9}},
+	}
+	runtime := &critesting.FakeRuntime{Containers: []*cri.ContainerStats{exited, app, sidecar, other}}
+
+	dir, err := ioutil.TempDir("", "cri")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	socket := filepath.Join(dir, "cri.sock")
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go runtime.Serve(l)
+
+	provider := cri.NewSummaryProvider(cri.NewClient(socket))
+	expected := `
+		# HELP kubelet_container_cpu_usage_seconds_total Cumulative CPU time consumed by the container in seconds
+		# TYPE kubelet_container_cpu_usage_seconds_total counter
+		kubelet_container_cpu_usage_seconds_total{container="app",namespace="team-a",pod="web-0",source="cri"} 2.5
+		# HELP kubelet_container_memory_rss_bytes Anonymous and swap cache memory of the container in bytes
+		# TYPE kubelet_container_memory_rss_bytes gauge
+		kubelet_container_memory_rss_bytes{container="app",namespace="team-a",pod="web-0",source="cri"} 800
+		# HELP kubelet_container_memory_usage_bytes Memory usage of the container in bytes, including caches
+		# TYPE kubelet_container_memory_usage_bytes gauge
+		kubelet_container_memory_usage_bytes{container="app",namespace="team-a",pod="web-0",source="cri"} 1500
+		# HELP kubelet_container_memory_working_set_bytes Working set memory of the container in bytes
+		# TYPE kubelet_container_memory_working_set_bytes gauge
+		kubelet_container_memory_working_set_bytes{container="app",namespace="team-a",pod="web-0",source="cri"} 1000
+		kubelet_container_memory_working_set_bytes{container="proxy",namespace="team-b",pod="db-0",source="cri"} 200
+		# HELP kubelet_container_rootfs_inodes_used Number of inodes used by the writable layer of the container
+		# TYPE kubelet_container_rootfs_inodes_used gauge
+		kubelet_container_rootfs_inodes_used{container="app",namespace="team-a",pod="web-0",source="cri"} 12
+		# HELP kubelet_container_rootfs_used_bytes Number of bytes used by the writable layer of the container
+		# TYPE kubelet_container_rootfs_used_bytes gauge
+		kubelet_container_rootfs_used_bytes{container="app",namespace="team-a",pod="web-0",source="cri"} 4096
+	`
+	if err := collectorstesting.GatherAndCompare(NewContainerStatsCollector(provider, "cri", nil, nil, nil), expected, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestContainerStatsCollectorBudget(t *testing.T) {
+	container := func(name string, workingSet uint64) v1alpha1.ContainerStats {
+		return v1alpha1.ContainerStats{Name: name, Memory: &v1alpha1.MemoryStats{Time: metav1.Now(), WorkingSetBytes: &workingSet}}
+	}
+	summary := &v1alpha1.Summary{Pods: []v1alpha1.PodStats{
+		{PodRef: v1alpha1.PodReference{Name: "web-0", Namespace: "team-a"}, Containers: []v1alpha1.ContainerStats{container("app", 300), container("proxy", 10)}},
+		{PodRef: v1alpha1.PodReference{Name: "web-1", Namespace: "team-a"}, Containers: []v1alpha1.ContainerStats{container("app", 200), container("proxy", 20)}},
+		{PodRef: v1alpha1.PodReference{Name: "db-0", Namespace: "team-b"}, Containers: []v1alpha1.ContainerStats{container("db", 100)}},
+	}}
+	// With the working set family only, three containers fit: the top one
+	// and the other buckets of both namespaces.
+	filter := &Filter{FamilyAllowlist: mustCompileFamilyRegexp(containerMemoryWorkingSetKey)}
+	budget := &Budget{MaxSeries: 3, RankBy: "memory_working_set_bytes"}
+	expected := `
+		# HELP kubelet_container_memory_working_set_bytes Working set memory of the container in bytes
+		# TYPE kubelet_container_memory_working_set_bytes gauge
+		kubelet_container_memory_working_set_bytes{container="app",namespace="team-a",pod="web-0",source="kubelet"} 300
+		kubelet_container_memory_working_set_bytes{container="__other__",namespace="team-a",pod="__other__",source="kubelet"} 230
+		kubelet_container_memory_working_set_bytes{container="__other__",namespace="team-b",pod="__other__",source="kubelet"} 100
+	`
+	collector := NewContainerStatsCollector(&fakeProvider{summary: summary}, "kubelet", filter, budget, nil)
+	if err := collectorstesting.GatherAndCompare(collector, expected, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func mustCompileFamilyRegexp(expr string) *regexp.Regexp {
+	re, err := CompileFamilyRegexp(expr)
+	if err != nil {
+		panic(err)
+	}
+	return re
+}
@@ -0,0 +1,97 @@
+package collectors
+
+import (
+	"context"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/csi"
+	"github.com/cofyc/kubelet-exporter/pkg/node"
+	"github.com/golang/glog"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+// csiCallTimeout bounds each call to a CSI plugin.
+const csiCallTimeout = 10 * time.Second
+
+// CSIStatsSource gets the stats of CSI volumes from their node plugins
+// instead of the kubelet, whose cached stats may lag by minutes.
+type CSIStatsSource struct {
+	Source  *NodeSource
+	Plugins *csi.Plugins
+}
+
+// csiVolumeStats are the stats of a CSI volume as reported by its plugin.
+type csiVolumeStats struct {
+	bytes, inodes *csi.VolumeUsage
+	// condition is nil if the plugin doesn't report volume conditions.
+	condition *csi.VolumeCondition
+}
+
+// merge returns usage with the units reported by the plugin replaced, and
+// whether all of usage is known. usage is nil if the kubelet has no stats.
+// The merged usage keeps the time of usage unless the plugin reported all
+// units, which were taken while collecting.
+func (s *csiVolumeStats) merge(usage *volumeUsage) (volumeUsage, bool) {
+	var merged volumeUsage
+	if usage != nil {
+		merged = *usage
+	}
+	if s.bytes != nil {
+		merged.capacityBytes = float64(s.bytes.Total)
+		merged.availableBytes = float64(s.bytes.Available)
+		merged.usedBytes = float64(s.bytes.Used)
+	}
+	if s.inodes != nil {
+		merged.inodes = float64(s.inodes.Total)
+		merged.inodesFree = float64(s.inodes.Available)
+		merged.inodesUsed = float64(s.inodes.Used)
+	}
+	if s.bytes != nil && s.inodes != nil {
+		merged.time = time.Time{}
+	}
+	return merged, usage != nil || (s.bytes != nil && s.inodes != nil)
+}
+
+// volumeStats returns the stats of the CSI PVCs in namespaces allowed by
+// filter. PVCs whose plugin can't be reached are missing.
+func (s *CSIStatsSource) volumeStats(ctx context.Context, statsSummary *v1alpha1.Summary, filter *Filter) map[v1alpha1.PVCReference]*csiVolumeStats {
+	volumes, err := s.Source.pvcVolumes(ctx, statsSummary, filter)
+	if err != nil {
+		glog.Errorf("failed to list pod volumes: %v", err)
+		return nil
+	}
+	stats := map[v1alpha1.PVCReference]*csiVolumeStats{}
+	for _, v := range volumes {
+		if v.Plugin != node.CSIPlugin {
+			continue
+		}
+		volumeData, err := csi.ReadVolumeData(v.Dir)
+		if err != nil {
+			glog.Warningf("failed to read CSI volume data of %s/%s: %v", v.PVC.Namespace, v.PVC.Name, err)
+			continue
+		}
+		client, err := s.Plugins.Client(ctx, volumeData.DriverName)
+		if err != nil || client == nil {
+			glog.V(4).Infof("no CSI plugin of driver %s found: %v", volumeData.DriverName, err)
+			continue
+		}
+		callCtx, cancel := context.WithTimeout(ctx, csiCallTimeout)
+		resp, err := client.NodeGetVolumeStats(callCtx, volumeData.VolumeHandle, v.Path)
+		cancel()
+		if err != nil {
+			glog.Warningf("failed to get stats of CSI volume %s of %s/%s from %s: %v", volumeData.VolumeHandle, v.PVC.Namespace, v.PVC.Name, client.Socket(), err)
+			continue
+		}
+		volumeStats := &csiVolumeStats{condition: resp.VolumeCondition}
+		for _, usage := range resp.Usage {
+			switch usage.Unit {
+			case csi.UnitBytes:
+				volumeStats.bytes = usage
+			case csi.UnitInodes:
+				volumeStats.inodes = usage
+			}
+		}
+		stats[*v.PVC] = volumeStats
+	}
+	return stats
+}
@@ -0,0 +1,160 @@
+package collectors
+
+import (
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	collectorstesting "github.com/cofyc/kubelet-exporter/pkg/collectors/testing"
+	"github.com/cofyc/kubelet-exporter/pkg/csi"
+	csitesting "github.com/cofyc/kubelet-exporter/pkg/csi/testing"
+	"github.com/cofyc/kubelet-exporter/pkg/node"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+// newFsStats returns filesystem stats taken at t.
+func newFsStats(t time.Time, capacity, available, used, inodes, inodesFree, inodesUsed uint64) v1alpha1.FsStats {
+	return v1alpha1.FsStats{
+		Time:           metav1.NewTime(t),
+		CapacityBytes:  &capacity,
+		AvailableBytes: &available,
+		UsedBytes:      &used,
+		Inodes:         &inodes,
+		InodesFree:     &inodesFree,
+		InodesUsed:     &inodesUsed,
+	}
+}
+
+func TestVolumeStatsCollectorCSI(t *testing.T) {
+	root, err := ioutil.TempDir("", "csi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	kubeletRootDir := filepath.Join(root, "kubelet")
+
+	// Each pod has a CSI PVC, the volume directories are named after the
+	// PVs and resolved as the single PVC of their pods.
+	summary := &v1alpha1.Summary{}
+	plugin := &csitesting.FakePlugin{Name: "fake.csi.example.com", Volumes: map[string]*csi.NodeGetVolumeStatsResponse{}}
+	now := time.Now()
+	for _, v := range []struct {
+		uid, namespace, pvc string
+		resp                *csi.NodeGetVolumeStatsResponse
+	}{
+		{"uid-1", "team-a", "data", &csi.NodeGetVolumeStatsResponse{
+			Usage: []*csi.VolumeUsage{
+				{Unit: csi.UnitBytes, Total: 100, Available: 40, Used: 60},
+				{Unit: csi.UnitInodes, Total: 10, Available: 4, Used: 6},
+			},
+			VolumeCondition: &csi.VolumeCondition{Abnormal: true, Message: "I/O errors"},
+		}},
+		{"uid-2", "team-a", "cache", &csi.NodeGetVolumeStatsResponse{
+			Usage:           []*csi.VolumeUsage{{Unit: csi.UnitBytes, Total: 200, Available: 150, Used: 50}},
+			VolumeCondition: &csi.VolumeCondition{},
+		}},
+		// Not found by the plugin.
+		{"uid-3", "team-b", "logs", nil},
+	} {
+		dir := filepath.Join(kubeletRootDir, "pods", v.uid, "volumes", node.CSIPlugin, "pvc-"+v.uid)
+		if err := os.MkdirAll(filepath.Join(dir, "mount"), 0700); err != nil {
+			t.Fatal(err)
+		}
+		volumeData := `{"driverName":"fake.csi.example.com","volumeHandle":"vol-` + v.uid + `"}`
+		if err := ioutil.WriteFile(filepath.Join(dir, "vol_data.json"), []byte(volumeData), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if v.resp != nil {
+			plugin.Volumes[filepath.Join(dir, "mount")] = v.resp
+		}
+		summary.Pods = append(summary.Pods, v1alpha1.PodStats{
+			PodRef: v1alpha1.PodReference{Name: "pod-" + v.uid, Namespace: v.namespace, UID: v.uid},
+			VolumeStats: []v1alpha1.VolumeStats{{
+				Name:    "volume",
+				PVCRef:  &v1alpha1.PVCReference{Name: v.pvc, Namespace: v.namespace},
+				FsStats: newFsStats(now, 1000, 400, 600, 100, 40, 60),
+			}},
+		})
+	}
+
+	socketDir := filepath.Join(kubeletRootDir, "plugins", "fake")
+	if err := os.MkdirAll(socketDir, 0700); err != nil {
+		t.Fatal(err)
+	}
+	l, err := net.Listen("unix", filepath.Join(socketDir, "csi.sock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go plugin.Serve(l)
+
+	source := &NodeSource{KubeletRootDir: kubeletRootDir}
+	csiSource := &CSIStatsSource{Source: source, Plugins: csi.NewPlugins(filepath.Join(kubeletRootDir, "plugins", "*", "csi.sock"))}
+	collector := NewVolumeStatsCollector(&fakeProvider{summary: summary}, nil, nil, csiSource, nil, nil)
+
+	// The usage reported by the plugin replaces the one of the kubelet, the
+	// condition is only exported for volumes whose plugin reports it.
+	expected := `
+		# HELP kubelet_volume_condition_abnormal Whether the CSI plugin of the volume reports an abnormal condition
+		# TYPE kubelet_volume_condition_abnormal gauge
+		kubelet_volume_condition_abnormal{namespace="team-a",persistentvolumeclaim="cache"} 0
+		kubelet_volume_condition_abnormal{namespace="team-a",persistentvolumeclaim="data"} 1
+		# HELP kubelet_volume_stats_available_bytes Number of available bytes in the volume
+		# TYPE kubelet_volume_stats_available_bytes gauge
+		kubelet_volume_stats_available_bytes{namespace="team-a",persistentvolumeclaim="cache"} 150
+		kubelet_volume_stats_available_bytes{namespace="team-a",persistentvolumeclaim="data"} 40
+		kubelet_volume_stats_available_bytes{namespace="team-b",persistentvolumeclaim="logs"} 400
+		# HELP kubelet_volume_stats_capacity_bytes Capacity in bytes of the volume
+		# TYPE kubelet_volume_stats_capacity_bytes gauge
+		kubelet_volume_stats_capacity_bytes{namespace="team-a",persistentvolumeclaim="cache"} 200
+		kubelet_volume_stats_capacity_bytes{namespace="team-a",persistentvolumeclaim="data"} 100
+		kubelet_volume_stats_capacity_bytes{namespace="team-b",persistentvolumeclaim="logs"} 1000
+		# HELP kubelet_volume_stats_inodes Maximum number of inodes in the volume
+		# TYPE kubelet_volume_stats_inodes gauge
+		kubelet_volume_stats_inodes{namespace="team-a",persistentvolumeclaim="cache"} 100
+		kubelet_volume_stats_inodes{namespace="team-a",persistentvolumeclaim="data"} 10
+		kubelet_volume_stats_inodes{namespace="team-b",persistentvolumeclaim="logs"} 100
+		# HELP kubelet_volume_stats_inodes_free Number of free inodes in the volume
+		# TYPE kubelet_volume_stats_inodes_free gauge
+		kubelet_volume_stats_inodes_free{namespace="team-a",persistentvolumeclaim="cache"} 40
+		kubelet_volume_stats_inodes_free{namespace="team-a",persistentvolumeclaim="data"} 4
+		kubelet_volume_stats_inodes_free{namespace="team-b",persistentvolumeclaim="logs"} 40
+		# HELP kubelet_volume_stats_inodes_used Number of used inodes in the volume
+		# TYPE kubelet_volume_stats_inodes_used gauge
+		kubelet_volume_stats_inodes_used{namespace="team-a",persistentvolumeclaim="cache"} 60
+		kubelet_volume_stats_inodes_used{namespace="team-a",persistentvolumeclaim="data"} 6
+		kubelet_volume_stats_inodes_used{namespace="team-b",persistentvolumeclaim="logs"} 60
+		# HELP kubelet_volume_stats_used_bytes Number of used bytes in the volume
+		# TYPE kubelet_volume_stats_used_bytes gauge
+		kubelet_volume_stats_used_bytes{namespace="team-a",persistentvolumeclaim="cache"} 50
+		kubelet_volume_stats_used_bytes{namespace="team-a",persistentvolumeclaim="data"} 60
+		kubelet_volume_stats_used_bytes{namespace="team-b",persistentvolumeclaim="logs"} 600
+	`
+	metrics := []string{
+		volumeConditionAbnormalKey, volumeStatsAvailableBytesKey, volumeStatsCapacityBytesKey,
+		volumeStatsInodesKey, volumeStatsInodesFreeKey, volumeStatsInodesUsedKey, volumeStatsUsedBytesKey,
+	}
+	if err := collectorstesting.GatherAndCompare(collector, expected, metrics); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestCSIVolumeStatsMergeTime(t *testing.T) {
+	taken := time.Unix(1000, 0)
+	fsStats := newFsStats(taken, 1000, 400, 600, 100, 40, 60)
+	kubelet := newVolumeUsage(&fsStats)
+	bytes := &csi.VolumeUsage{Unit: csi.UnitBytes, Total: 100, Available: 40, Used: 60}
+	inodes := &csi.VolumeUsage{Unit: csi.UnitInodes, Total: 10, Available: 4, Used: 6}
+
+	// The inodes still come from the kubelet.
+	if merged, _ := (&csiVolumeStats{bytes: bytes}).merge(&kubelet); !merged.time.Equal(taken) {
+		t.Errorf("got time %v of usage merged with bytes, want the kubelet's %v", merged.time, taken)
+	}
+	if merged, _ := (&csiVolumeStats{bytes: bytes, inodes: inodes}).merge(&kubelet); !merged.time.IsZero() {
+		t.Errorf("got time %v of usage replaced by the plugin, want none", merged.time)
+	}
+}
@@ -0,0 +1,65 @@
+package collectors
+
+import (
+	"regexp"
+)
+
+// Filter restricts the series collectors build. A nil *Filter restricts
+// nothing.
+type Filter struct {
+	// FamilyAllowlist, if set, is matched against every metric family name,
+	// only matching families are collected.
+	FamilyAllowlist *regexp.Regexp
+	// FamilyDenylist, if set, is matched against every metric family name,
+	// matching families are not collected.
+	FamilyDenylist *regexp.Regexp
+	// Namespaces, if not nil, restricts series carrying a namespace to these
+	// namespaces. Node level series, which have an empty namespace, are not
+	// affected.
+	Namespaces map[string]bool
+}
+
+// Family returns true if the metric family name should be collected.
+func (f *Filter) Family(name string) bool {
+	if f == nil {
+		return true
+	}
+	if f.FamilyAllowlist != nil && !f.FamilyAllowlist.MatchString(name) {
+		return false
+	}
+	if f.FamilyDenylist != nil && f.FamilyDenylist.MatchString(name) {
+		return false
+	}
+	return true
+}
+
+// Namespace returns true if series of namespace should be collected. Node
+// level series, with an empty namespace, are always collected.
+func (f *Filter) Namespace(namespace string) bool {
+	if f == nil || f.Namespaces == nil || namespace == "" {
+		return true
+	}
+	return f.Namespaces[namespace]
+}
+
+// WithNamespaces returns a copy of f restricted to namespaces.
+func (f *Filter) WithNamespaces(namespaces []string) *Filter {
+	filter := &Filter{}
+	if f != nil {
+		*filter = *f
+	}
+	filter.Namespaces = map[string]bool{}
+	for _, ns := range namespaces {
+		filter.Namespaces[ns] = true
+	}
+	return filter
+}
+
+// CompileFamilyRegexp compiles a metric family regexp. The expression must
+// match the whole name. An empty expression returns nil.
+func CompileFamilyRegexp(expr string) (*regexp.Regexp, error) {
+	if expr == "" {
+		return nil, nil
+	}
+	return regexp.Compile("^(?:" + expr + ")$")
+}
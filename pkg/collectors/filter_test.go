@@ -0,0 +1,25 @@
+package collectors
+
+import (
+	"testing"
+)
+
+func TestFilterNamespace(t *testing.T) {
+	tests := []struct {
+		filter    *Filter
+		namespace string
+		want      bool
+	}{
+		{nil, "a", true},
+		{&Filter{}, "a", true},
+		{(&Filter{}).WithNamespaces([]string{"a"}), "a", true},
+		{(&Filter{}).WithNamespaces([]string{"a"}), "b", false},
+		// Node level series are never filtered.
+		{(&Filter{}).WithNamespaces([]string{"a"}), "", true},
+	}
+	for i, test := range tests {
+		if got := test.filter.Namespace(test.namespace); got != test.want {
+			t.Errorf("%d: Namespace(%q) = %v, want %v", i, test.namespace, got, test.want)
+		}
+	}
+}
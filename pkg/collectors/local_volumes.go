@@ -0,0 +1,160 @@
+package collectors
+
+import (
+	"context"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+	"github.com/cofyc/kubelet-exporter/pkg/node"
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+const (
+	localVolumeCapacityBytesKey        = "kubelet_local_volume_capacity_bytes"
+	localVolumeUsedBytesKey            = "kubelet_local_volume_used_bytes"
+	localVolumeBoundKey                = "kubelet_local_volume_bound"
+	localVolumeUnboundCapacityBytesKey = "kubelet_local_volume_unbound_capacity_bytes"
+)
+
+var (
+	localVolumeLabels = []string{"storageclass", "path", "device", "namespace", "persistentvolumeclaim"}
+
+	localVolumeCapacityBytes = prometheus.NewDesc(
+		localVolumeCapacityBytesKey,
+		"Capacity in bytes of the local volume",
+		localVolumeLabels, nil,
+	)
+	localVolumeUsedBytes = prometheus.NewDesc(
+		localVolumeUsedBytesKey,
+		"Number of used bytes in the local volume",
+		localVolumeLabels, nil,
+	)
+	localVolumeBound = prometheus.NewDesc(
+		localVolumeBoundKey,
+		"Whether the local volume is bound to a PVC",
+		localVolumeLabels, nil,
+	)
+	localVolumeUnboundCapacityBytes = prometheus.NewDesc(
+		localVolumeUnboundCapacityBytesKey,
+		"Total capacity in bytes of the local volumes of the storage class not bound to a PVC",
+		[]string{"storageclass"}, nil,
+	)
+)
+
+// localVolumesCollector collects the capacity of the local volumes in the
+// discovery directories of the local static provisioner.
+type localVolumesCollector struct {
+	provider      kubelet.SummaryProvider
+	source        *NodeSource
+	discoveryDirs map[string]string
+	pvs           *node.LocalVolumeCache
+	filter        *Filter
+}
+
+// NewLocalVolumesCollector creates a new local volumes prometheus collector.
+// discoveryDirs are the discovery directories by storage class. If pvs is
+// nil, volumes are bound if pods on the node mount them.
+func NewLocalVolumesCollector(provider kubelet.SummaryProvider, source *NodeSource, discoveryDirs map[string]string, pvs *node.LocalVolumeCache, filter *Filter) prometheus.Collector {
+	return &localVolumesCollector{provider: provider, source: source, discoveryDirs: discoveryDirs, pvs: pvs, filter: filter}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (collector *localVolumesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- localVolumeCapacityBytes
+	ch <- localVolumeUsedBytes
+	ch <- localVolumeBound
+	ch <- localVolumeUnboundCapacityBytes
+}
+
+// Collect implements the prometheus.Collector interface.
+func (collector *localVolumesCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	mounts, err := node.ReadMountInfo(collector.source.ProcfsRoot)
+	if err != nil {
+		glog.Errorf("failed to read mountinfo: %v", err)
+		return
+	}
+	volumes, err := node.ListLocalVolumes(collector.discoveryDirs, node.MountsByPoint(mounts))
+	if err != nil {
+		glog.Errorf("failed to list local volumes: %v", err)
+		return
+	}
+	claims, err := collector.claims(ctx, mounts)
+	if err != nil {
+		glog.Errorf("failed to find the PVCs of local volumes: %v", err)
+		return
+	}
+
+	add := func(key string, desc *prometheus.Desc, v float64, lv ...string) {
+		if !collector.filter.Family(key) {
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
+	}
+
+	unbound := map[string]float64{}
+	for class := range collector.discoveryDirs {
+		unbound[class] = 0
+	}
+	for _, v := range volumes {
+		stats, err := node.Statfs(v.Path)
+		if err != nil {
+			glog.Warningf("failed to statfs local volume %s: %v", v.Path, err)
+			continue
+		}
+		claim := claims[v.Path]
+		if claim == nil {
+			unbound[v.StorageClass] += float64(stats.CapacityBytes)
+			claim = &v1alpha1.PVCReference{}
+		}
+		if !collector.filter.Namespace(claim.Namespace) {
+			continue
+		}
+		lv := []string{v.StorageClass, v.Path, v.Mount.Source, claim.Namespace, claim.Name}
+		add(localVolumeCapacityBytesKey, localVolumeCapacityBytes, float64(stats.CapacityBytes), lv...)
+		add(localVolumeUsedBytesKey, localVolumeUsedBytes, float64(stats.UsedBytes), lv...)
+		add(localVolumeBoundKey, localVolumeBound, boolFloat64(claim.Name != ""), lv...)
+	}
+	for class, capacity := range unbound {
+		add(localVolumeUnboundCapacityBytesKey, localVolumeUnboundCapacityBytes, capacity, class)
+	}
+}
+
+// claims returns the PVCs the local volumes are bound to by path. Without
+// PVs from the API server, a volume is bound to the PVC of a pod volume
+// mounting the same filesystem root.
+func (collector *localVolumesCollector) claims(ctx context.Context, mounts []node.MountInfo) (map[string]*v1alpha1.PVCReference, error) {
+	if collector.pvs != nil {
+		return collector.pvs.ClaimsByPath(ctx)
+	}
+	statsSummary, err := collector.provider.GetSummary(ctx)
+	if err != nil {
+		return nil, err
+	}
+	podVolumes, err := collector.source.podVolumes(ctx, statsSummary)
+	if err != nil {
+		return nil, err
+	}
+	type fsRoot struct {
+		major, minor int
+		root         string
+	}
+	mountsByPoint := node.MountsByPoint(mounts)
+	claimsByRoot := map[fsRoot]*v1alpha1.PVCReference{}
+	for _, v := range podVolumes {
+		if m, ok := mountsByPoint[v.Path]; ok && v.PVC != nil {
+			claimsByRoot[fsRoot{m.Major, m.Minor, m.Root}] = v.PVC
+		}
+	}
+	claims := map[string]*v1alpha1.PVCReference{}
+	for _, m := range mounts {
+		if claim, ok := claimsByRoot[fsRoot{m.Major, m.Minor, m.Root}]; ok {
+			claims[m.MountPoint] = claim
+		}
+	}
+	return claims, nil
+}
@@ -0,0 +1,55 @@
+package collectors
+
+import (
+	"context"
+
+	"github.com/cofyc/kubelet-exporter/pkg/node"
+	"github.com/golang/glog"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+// NodeSource locates what collectors inspecting the node directly read.
+type NodeSource struct {
+	// ProcfsRoot is where the procfs is mounted, e.g. /proc.
+	ProcfsRoot string
+	// KubeletRootDir is the --root-dir of the kubelet, e.g. /var/lib/kubelet.
+	KubeletRootDir string
+	// Claims maps PVs to PVCs, it may be nil.
+	Claims *node.ClaimCache
+}
+
+// podVolumes returns the volume directories of the pods on the node and what
+// they belong to.
+func (s *NodeSource) podVolumes(ctx context.Context, statsSummary *v1alpha1.Summary) ([]node.ResolvedVolume, error) {
+	volumes, err := node.ListPodVolumes(s.KubeletRootDir)
+	if err != nil {
+		return nil, err
+	}
+	var claims map[string]v1alpha1.PVCReference
+	if s.Claims != nil {
+		claims, err = s.Claims.ClaimsByVolume(ctx)
+		if err != nil {
+			glog.Warningf("failed to list PVCs, PV backed volumes may not be resolved: %v", err)
+		}
+	}
+	return node.ResolveVolumes(statsSummary, volumes, claims), nil
+}
+
+// pvcVolumes returns the volume directories of PVCs in namespaces allowed by
+// filter, one per PVC.
+func (s *NodeSource) pvcVolumes(ctx context.Context, statsSummary *v1alpha1.Summary, filter *Filter) ([]node.ResolvedVolume, error) {
+	volumes, err := s.podVolumes(ctx, statsSummary)
+	if err != nil {
+		return nil, err
+	}
+	var pvcVolumes []node.ResolvedVolume
+	seen := map[v1alpha1.PVCReference]bool{}
+	for _, v := range volumes {
+		if v.PVC == nil || seen[*v.PVC] || !filter.Namespace(v.PVC.Namespace) {
+			continue
+		}
+		seen[*v.PVC] = true
+		pvcVolumes = append(pvcVolumes, v)
+	}
+	return pvcVolumes, nil
+}
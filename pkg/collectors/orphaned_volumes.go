@@ -0,0 +1,216 @@
+package collectors
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+	"github.com/cofyc/kubelet-exporter/pkg/node"
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	orphanedVolumeBytesKey   = "kubelet_orphaned_volume_directory_bytes"
+	orphanedVolumeMountedKey = "kubelet_orphaned_volume_directory_mounted"
+)
+
+var (
+	orphanedVolumeLabels = []string{"pod_uid", "plugin", "volume"}
+
+	orphanedVolumeBytes = prometheus.NewDesc(
+		orphanedVolumeBytesKey,
+		"Number of bytes used on the filesystem of the kubelet root dir by the volume directory of a pod the kubelet no longer runs",
+		orphanedVolumeLabels, nil,
+	)
+	orphanedVolumeMounted = prometheus.NewDesc(
+		orphanedVolumeMountedKey,
+		"Whether the volume directory of a pod the kubelet no longer runs is still mounted",
+		orphanedVolumeLabels, nil,
+	)
+)
+
+// OrphanTracker tracks the pods with volume directories which are missing
+// from the stats summary across scrapes. Pods are only orphaned after they
+// have been missing for a grace period, as the volume directories of a pod
+// are set up before the kubelet reports it. The disk usage of the volume
+// directories of orphaned pods, which hardly changes, is cached. A nil
+// *OrphanTracker orphans pods as soon as they are missing and caches nothing.
+type OrphanTracker struct {
+	grace    time.Duration
+	usageTTL time.Duration
+
+	mu sync.Mutex
+	// missingSince is when pods were first found missing by UID.
+	missingSince map[string]time.Time
+	// usage is the disk usage of volume directories.
+	usage map[string]cachedDiskUsage
+}
+
+type cachedDiskUsage struct {
+	bytes uint64
+	time  time.Time
+}
+
+// NewOrphanTracker creates a tracker orphaning pods missing for grace, and
+// caching disk usage for usageTTL.
+func NewOrphanTracker(grace, usageTTL time.Duration) *OrphanTracker {
+	return &OrphanTracker{
+		grace:        grace,
+		usageTTL:     usageTTL,
+		missingSince: map[string]time.Time{},
+		usage:        map[string]cachedDiskUsage{},
+	}
+}
+
+// orphaned returns the UIDs of the pods in missing which have been missing
+// for the grace period. Pods not in missing anymore are forgotten.
+func (t *OrphanTracker) orphaned(missing map[string]bool, now time.Time) map[string]bool {
+	if t == nil {
+		return missing
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	orphaned := map[string]bool{}
+	for uid := range missing {
+		since, ok := t.missingSince[uid]
+		if !ok {
+			since = now
+			t.missingSince[uid] = now
+		}
+		if now.Sub(since) >= t.grace {
+			orphaned[uid] = true
+		}
+	}
+	for uid := range t.missingSince {
+		if !missing[uid] {
+			delete(t.missingSince, uid)
+		}
+	}
+	return orphaned
+}
+
+// diskUsage returns the disk usage of dir, cached for the usage TTL.
+func (t *OrphanTracker) diskUsage(dir string, skip map[string]bool, now time.Time) (uint64, error) {
+	if t == nil {
+		return node.DiskUsage(dir, skip)
+	}
+	t.mu.Lock()
+	cached, ok := t.usage[dir]
+	t.mu.Unlock()
+	if ok && now.Sub(cached.time) < t.usageTTL {
+		return cached.bytes, nil
+	}
+	usage, err := node.DiskUsage(dir, skip)
+	if err != nil {
+		return 0, err
+	}
+	t.mu.Lock()
+	t.usage[dir] = cachedDiskUsage{bytes: usage, time: now}
+	t.mu.Unlock()
+	return usage, nil
+}
+
+// pruneUsage forgets the disk usage of the directories not in dirs.
+func (t *OrphanTracker) pruneUsage(dirs map[string]bool) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for dir := range t.usage {
+		if !dirs[dir] {
+			delete(t.usage, dir)
+		}
+	}
+}
+
+// orphanedVolumesCollector collects the volume directories of pods which
+// are not in the stats summary anymore.
+type orphanedVolumesCollector struct {
+	provider kubelet.SummaryProvider
+	source   *NodeSource
+	tracker  *OrphanTracker
+	filter   *Filter
+}
+
+// NewOrphanedVolumesCollector creates a new orphaned volume directories
+// prometheus collector. tracker must be shared by the collectors of all
+// scrapes.
+func NewOrphanedVolumesCollector(provider kubelet.SummaryProvider, source *NodeSource, tracker *OrphanTracker, filter *Filter) prometheus.Collector {
+	return &orphanedVolumesCollector{provider: provider, source: source, tracker: tracker, filter: filter}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (collector *orphanedVolumesCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- orphanedVolumeBytes
+	ch <- orphanedVolumeMounted
+}
+
+// Collect implements the prometheus.Collector interface.
+func (collector *orphanedVolumesCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	statsSummary, err := collector.provider.GetSummary(ctx)
+	if err != nil {
+		glog.Errorf("failed to get stats summary: %v", err)
+		return
+	}
+	volumes, err := node.ListPodVolumes(collector.source.KubeletRootDir)
+	if err != nil {
+		glog.Errorf("failed to list pod volumes: %v", err)
+		return
+	}
+	mounts, err := node.ReadMountInfo(collector.source.ProcfsRoot)
+	if err != nil {
+		glog.Errorf("failed to read mountinfo: %v", err)
+		return
+	}
+	mountPoints := make(map[string]bool, len(mounts))
+	for _, m := range mounts {
+		mountPoints[m.MountPoint] = true
+	}
+	running := make(map[string]bool, len(statsSummary.Pods))
+	for _, pod := range statsSummary.Pods {
+		running[pod.PodRef.UID] = true
+	}
+	missing := map[string]bool{}
+	for _, v := range volumes {
+		if !running[v.PodUID] {
+			missing[v.PodUID] = true
+		}
+	}
+	now := time.Now()
+	orphaned := collector.tracker.orphaned(missing, now)
+
+	add := func(key string, desc *prometheus.Desc, v float64, lv ...string) {
+		if !collector.filter.Family(key) {
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
+	}
+
+	dirs := map[string]bool{}
+	for _, v := range volumes {
+		if !orphaned[v.PodUID] {
+			continue
+		}
+		lv := []string{v.PodUID, v.Plugin, v.Name}
+		add(orphanedVolumeMountedKey, orphanedVolumeMounted, boolFloat64(mountPoints[v.Dir] || mountPoints[v.Path]), lv...)
+		if !collector.filter.Family(orphanedVolumeBytesKey) {
+			continue
+		}
+		dirs[v.Dir] = true
+		usage, err := collector.tracker.diskUsage(v.Dir, mountPoints, now)
+		if err != nil {
+			glog.Warningf("failed to get disk usage of %s: %v", v.Dir, err)
+			continue
+		}
+		add(orphanedVolumeBytesKey, orphanedVolumeBytes, float64(usage), lv...)
+	}
+	if collector.filter.Family(orphanedVolumeBytesKey) {
+		collector.tracker.pruneUsage(dirs)
+	}
+}
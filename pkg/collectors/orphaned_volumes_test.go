@@ -0,0 +1,109 @@
+package collectors
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	collectorstesting "github.com/cofyc/kubelet-exporter/pkg/collectors/testing"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+// fakeProvider provides a fixed summary, or fails with err.
+type fakeProvider struct {
+	summary *v1alpha1.Summary
+	err     error
+}
+
+func (p *fakeProvider) GetSummary(ctx context.Context) (*v1alpha1.Summary, error) {
+	return p.summary, p.err
+}
+
+func TestOrphanTrackerGracePeriod(t *testing.T) {
+	tracker := NewOrphanTracker(time.Minute, time.Minute)
+	start := time.Now()
+	if orphaned := tracker.orphaned(map[string]bool{"a": true}, start); len(orphaned) != 0 {
+		t.Errorf("got orphans %v as soon as they are missing", orphaned)
+	}
+	orphaned := tracker.orphaned(map[string]bool{"a": true, "b": true}, start.Add(time.Minute))
+	if len(orphaned) != 1 || !orphaned["a"] {
+		t.Errorf("got orphans %v, want a after the grace period", orphaned)
+	}
+	// A pod reported again starts over.
+	tracker.orphaned(map[string]bool{"b": true}, start.Add(2*time.Minute))
+	orphaned = tracker.orphaned(map[string]bool{"a": true, "b": true}, start.Add(2*time.Minute))
+	if len(orphaned) != 1 || !orphaned["b"] {
+		t.Errorf("got orphans %v, want b", orphaned)
+	}
+}
+
+func TestOrphanTrackerDiskUsage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "orphans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tracker := NewOrphanTracker(0, time.Minute)
+	now := time.Now()
+	before, err := tracker.diskUsage(dir, nil, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "data"), make([]byte, 1<<20), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if usage, _ := tracker.diskUsage(dir, nil, now.Add(time.Second)); usage != before {
+		t.Errorf("got usage %d within the TTL, want the cached %d", usage, before)
+	}
+	if usage, _ := tracker.diskUsage(dir, nil, now.Add(time.Minute)); usage <= before {
+		t.Errorf("got usage %d after the TTL, want more than %d", usage, before)
+	}
+	tracker.pruneUsage(nil)
+	if len(tracker.usage) != 0 {
+		t.Errorf("got cached usage %v after pruning", tracker.usage)
+	}
+}
+
+func TestOrphanedVolumesCollector(t *testing.T) {
+	root, err := ioutil.TempDir("", "orphans")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	for _, dir := range []string{
+		"kubelet/pods/running/volumes/kubernetes.io~empty-dir/cache",
+		"kubelet/pods/orphaned/volumes/kubernetes.io~csi/pvc-1/mount",
+		"proc/self",
+	} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mountinfo := "1 0 8:1 / " + filepath.Join(root, "kubelet/pods/orphaned/volumes/kubernetes.io~csi/pvc-1/mount") + " rw - ext4 /dev/sdb rw\n"
+	if err := ioutil.WriteFile(filepath.Join(root, "proc/self/mountinfo"), []byte(mountinfo), 0600); err != nil {
+		t.Fatal(err)
+	}
+	provider := &fakeProvider{summary: &v1alpha1.Summary{Pods: []v1alpha1.PodStats{
+		{PodRef: v1alpha1.PodReference{Name: "web-0", Namespace: "team-a", UID: "running"}},
+	}}}
+	source := &NodeSource{ProcfsRoot: filepath.Join(root, "proc"), KubeletRootDir: filepath.Join(root, "kubelet")}
+	metrics := []string{orphanedVolumeMountedKey}
+
+	tracker := NewOrphanTracker(time.Hour, time.Hour)
+	if err := collectorstesting.GatherAndCompare(NewOrphanedVolumesCollector(provider, source, tracker, nil), "", nil); err != nil {
+		t.Errorf("pod reported within the grace period: %v", err)
+	}
+
+	expected := `
+		# HELP kubelet_orphaned_volume_directory_mounted Whether the volume directory of a pod the kubelet no longer runs is still mounted
+		# TYPE kubelet_orphaned_volume_directory_mounted gauge
+		kubelet_orphaned_volume_directory_mounted{plugin="kubernetes.io~csi",pod_uid="orphaned",volume="pvc-1"} 1
+	`
+	tracker = NewOrphanTracker(0, time.Hour)
+	if err := collectorstesting.GatherAndCompare(NewOrphanedVolumesCollector(provider, source, tracker, nil), expected, metrics); err != nil {
+		t.Error(err)
+	}
+}
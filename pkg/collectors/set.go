@@ -0,0 +1,151 @@
+package collectors
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Factory creates a collector building only the series filter allows. The
+// collector gets its summaries from summaries, see Summaries.Of.
+type Factory func(summaries *Summaries, filter *Filter) prometheus.Collector
+
+// Set is a set of named collectors sharing a filter. It keeps track of the
+// collections in progress to detect wedged collectors.
+type Set struct {
+	factories map[string]Factory
+	filter    *Filter
+
+	mu sync.Mutex
+	// collects are the collections in progress by ID.
+	collects    map[uint64]collect
+	nextCollect uint64
+}
+
+// collect is a collection in progress.
+type collect struct {
+	name  string
+	start time.Time
+}
+
+// NewSet creates an empty set of collectors applying filter.
+func NewSet(filter *Filter) *Set {
+	return &Set{
+		factories: map[string]Factory{},
+		filter:    filter,
+		collects:  map[uint64]collect{},
+	}
+}
+
+// Add adds a collector to the set.
+func (s *Set) Add(name string, factory Factory) {
+	s.factories[name] = factory
+}
+
+// Names returns the names of the collectors in the set, sorted.
+func (s *Set) Names() []string {
+	names := make([]string, 0, len(s.factories))
+	for name := range s.factories {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Gatherer returns a gatherer of the named collectors, restricted to
+// namespaces. All collectors are gathered if names is empty, and all
+// namespaces if namespaces is empty. Each summary is fetched once per gather
+// and shared by the collectors.
+func (s *Set) Gatherer(names []string, namespaces []string) (prometheus.Gatherer, error) {
+	if len(names) == 0 {
+		names = s.Names()
+	}
+	filter := s.filter
+	if len(namespaces) > 0 {
+		filter = filter.WithNamespaces(namespaces)
+	}
+	registry := prometheus.NewRegistry()
+	if filter.Family(seriesDroppedKey) {
+		registry.MustRegister(seriesDropped)
+	}
+	summaries := NewSummaries()
+	registered := map[string]bool{}
+	for _, name := range names {
+		factory, ok := s.factories[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown collector %q", name)
+		}
+		if registered[name] {
+			continue
+		}
+		if err := registry.Register(&trackedCollector{Collector: factory(summaries, filter), set: s, name: name}); err != nil {
+			return nil, fmt.Errorf("failed to register collector %q: %v", name, err)
+		}
+		registered[name] = true
+	}
+	return &summaryGatherer{registry: registry, summaries: summaries}, nil
+}
+
+// summaryGatherer gathers a registry with fresh summaries. Gathers are
+// serialized, as they share the summaries.
+type summaryGatherer struct {
+	mu        sync.Mutex
+	registry  *prometheus.Registry
+	summaries *Summaries
+}
+
+// Gather implements the prometheus.Gatherer interface.
+func (g *summaryGatherer) Gather() ([]*dto.MetricFamily, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.summaries.reset()
+	return g.registry.Gather()
+}
+
+// OldestCollect returns the name of the collector of the oldest collection
+// in progress and when it started. It returns false if none is in progress.
+func (s *Set) OldestCollect() (string, time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var oldest collect
+	for _, c := range s.collects {
+		if oldest.start.IsZero() || c.start.Before(oldest.start) {
+			oldest = c
+		}
+	}
+	return oldest.name, oldest.start, !oldest.start.IsZero()
+}
+
+func (s *Set) startCollect(name string) uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextCollect
+	s.nextCollect++
+	s.collects[id] = collect{name: name, start: time.Now()}
+	return id
+}
+
+func (s *Set) finishCollect(id uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.collects, id)
+}
+
+// trackedCollector records its collections as in progress in its set until
+// they return.
+type trackedCollector struct {
+	prometheus.Collector
+	set  *Set
+	name string
+}
+
+// Collect implements the prometheus.Collector interface.
+func (c *trackedCollector) Collect(ch chan<- prometheus.Metric) {
+	id := c.set.startCollect(c.name)
+	defer c.set.finishCollect(id)
+	c.Collector.Collect(ch)
+}
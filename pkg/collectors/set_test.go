@@ -0,0 +1,113 @@
+package collectors
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+// blockingCollector blocks in Collect until release is closed.
+type blockingCollector struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+var blockingDesc = prometheus.NewDesc("blocking", "Never collected", nil, nil)
+
+func (c *blockingCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- blockingDesc
+}
+
+func (c *blockingCollector) Collect(ch chan<- prometheus.Metric) {
+	close(c.started)
+	<-c.release
+}
+
+func TestSetOldestCollect(t *testing.T) {
+	c := &blockingCollector{started: make(chan struct{}), release: make(chan struct{})}
+	set := NewSet(&Filter{})
+	set.Add("blocking", func(summaries *Summaries, filter *Filter) prometheus.Collector { return c })
+	gatherer, err := set.Gatherer(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, ok := set.OldestCollect(); ok {
+		t.Fatal("collection in progress before gathering")
+	}
+
+	done := make(chan struct{})
+	before := time.Now()
+	go func() {
+		gatherer.Gather()
+		close(done)
+	}()
+	<-c.started
+	name, start, ok := set.OldestCollect()
+	if !ok || name != "blocking" || start.Before(before) {
+		t.Errorf("got collection %q started at %v (%v), want blocking started after %v", name, start, ok, before)
+	}
+
+	close(c.release)
+	<-done
+	if name, _, ok := set.OldestCollect(); ok {
+		t.Errorf("collection of %q in progress after gathering", name)
+	}
+}
+
+// countingProvider counts the summaries it is asked for.
+type countingProvider struct {
+	mu      sync.Mutex
+	fetches int
+}
+
+func (p *countingProvider) GetSummary(ctx context.Context) (*v1alpha1.Summary, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.fetches++
+	return &v1alpha1.Summary{}, nil
+}
+
+// summaryCollector asks its provider for a summary in Collect.
+type summaryCollector struct {
+	provider kubelet.SummaryProvider
+	desc     *prometheus.Desc
+}
+
+func (c *summaryCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *summaryCollector) Collect(ch chan<- prometheus.Metric) {
+	c.provider.GetSummary(context.Background())
+}
+
+func TestSetGathererFetchesSummaryOnce(t *testing.T) {
+	kubeletProvider, criProvider := &countingProvider{}, &countingProvider{}
+	set := NewSet(&Filter{})
+	for _, name := range []string{"a", "b", "c"} {
+		desc := prometheus.NewDesc(name, "Never collected", nil, nil)
+		set.Add(name, func(summaries *Summaries, filter *Filter) prometheus.Collector {
+			return &summaryCollector{provider: summaries.Of(kubeletProvider), desc: desc}
+		})
+	}
+	set.Add("cri", func(summaries *Summaries, filter *Filter) prometheus.Collector {
+		return &summaryCollector{provider: summaries.Of(criProvider), desc: prometheus.NewDesc("cri", "Never collected", nil, nil)}
+	})
+	gatherer, err := set.Gatherer(nil, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 1; i <= 2; i++ {
+		if _, err := gatherer.Gather(); err != nil {
+			t.Fatal(err)
+		}
+		if kubeletProvider.fetches != i || criProvider.fetches != i {
+			t.Errorf("got %d and %d summaries after %d gathers, want one of each provider per gather", kubeletProvider.fetches, criProvider.fetches, i)
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/node"
+	"github.com/golang/glog"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+// StatfsFallback gets the stats of PVCs the kubelet has no stats of by
+// statfs'ing their mounts. The kubelet computes volume stats lazily, e.g.
+// they are missing right after pods start.
+type StatfsFallback struct {
+	source  *NodeSource
+	timeout time.Duration
+
+	mu sync.Mutex
+	// hung are the paths with a statfs which didn't return yet. They are not
+	// statfs'ed again until it returns.
+	hung map[string]bool
+}
+
+// NewStatfsFallback creates a fallback statfs'ing the mounts of the volumes
+// found by source, giving up after timeout.
+func NewStatfsFallback(source *NodeSource, timeout time.Duration) *StatfsFallback {
+	return &StatfsFallback{source: source, timeout: timeout, hung: map[string]bool{}}
+}
+
+// volumeStats returns the stats of the mounted PVCs in namespaces allowed by
+// filter which are not collected yet.
+func (f *StatfsFallback) volumeStats(ctx context.Context, statsSummary *v1alpha1.Summary, filter *Filter, collected map[v1alpha1.PVCReference]bool) map[v1alpha1.PVCReference]volumeUsage {
+	volumes, err := f.source.pvcVolumes(ctx, statsSummary, filter)
+	if err != nil {
+		glog.Errorf("failed to list pod volumes: %v", err)
+		return nil
+	}
+	mounts, err := node.ReadMountInfo(f.source.ProcfsRoot)
+	if err != nil {
+		glog.Errorf("failed to read mountinfo: %v", err)
+		return nil
+	}
+	mountsByPoint := node.MountsByPoint(mounts)
+
+	usages := map[v1alpha1.PVCReference]volumeUsage{}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, v := range volumes {
+		// Unmounted volume directories are on the root filesystem.
+		if collected[*v.PVC] || mountsByPoint[v.Path] == nil {
+			continue
+		}
+		wg.Add(1)
+		go func(pvc v1alpha1.PVCReference, path string) {
+			defer wg.Done()
+			stats, err := f.statfs(path)
+			if err != nil {
+				glog.Warningf("failed to statfs volume %s/%s at %s: %v", pvc.Namespace, pvc.Name, path, err)
+				return
+			}
+			mu.Lock()
+			usages[pvc] = volumeUsage{
+				source:         volumeStatsSourceStatfs,
+				capacityBytes:  float64(stats.CapacityBytes),
+				availableBytes: float64(stats.AvailableBytes),
+				usedBytes:      float64(stats.UsedBytes),
+				inodes:         float64(stats.Inodes),
+				inodesFree:     float64(stats.InodesFree),
+				inodesUsed:     float64(stats.InodesUsed),
+			}
+			mu.Unlock()
+		}(*v.PVC, v.Path)
+	}
+	wg.Wait()
+	return usages
+}
+
+// statfs statfs'es path, giving up after the timeout.
+func (f *StatfsFallback) statfs(path string) (*node.FsStats, error) {
+	f.mu.Lock()
+	if f.hung[path] {
+		f.mu.Unlock()
+		return nil, fmt.Errorf("previous statfs did not return yet")
+	}
+	f.hung[path] = true
+	f.mu.Unlock()
+
+	type result struct {
+		stats *node.FsStats
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		stats, err := node.Statfs(path)
+		f.mu.Lock()
+		delete(f.hung, path)
+		f.mu.Unlock()
+		done <- result{stats, err}
+	}()
+	select {
+	case r := <-done:
+		return r.stats, r.err
+	case <-time.After(f.timeout):
+		return nil, fmt.Errorf("statfs timed out after %v", f.timeout)
+	}
+}
@@ -0,0 +1,64 @@
+package collectors
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kube"
+	"github.com/cofyc/kubelet-exporter/pkg/node"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+// fakeClaimLister lists fixed PVCs.
+type fakeClaimLister struct {
+	claims []kube.PersistentVolumeClaim
+}
+
+func (l *fakeClaimLister) ListPersistentVolumeClaims(ctx context.Context) (*kube.PersistentVolumeClaimList, error) {
+	return &kube.PersistentVolumeClaimList{Items: l.claims}, nil
+}
+
+func TestStatfsFallbackWithoutVolumeStats(t *testing.T) {
+	root, err := ioutil.TempDir("", "statfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	kubeletRootDir := filepath.Join(root, "kubelet")
+	mountPath := filepath.Join(kubeletRootDir, "pods", "uid-1", "volumes", node.CSIPlugin, "pvc-1", "mount")
+	if err := os.MkdirAll(mountPath, 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(root, "proc", "self"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	mountinfo := "1 0 8:1 / " + mountPath + " rw - ext4 /dev/sdb rw\n"
+	if err := ioutil.WriteFile(filepath.Join(root, "proc", "self", "mountinfo"), []byte(mountinfo), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	// The pod just started, the kubelet has no stats of its volumes yet.
+	summary := &v1alpha1.Summary{Pods: []v1alpha1.PodStats{
+		{PodRef: v1alpha1.PodReference{Name: "web-0", Namespace: "team-a", UID: "uid-1"}},
+	}}
+	lister := &fakeClaimLister{claims: []kube.PersistentVolumeClaim{{
+		ObjectMeta: metav1.ObjectMeta{Name: "data", Namespace: "team-a"},
+		Spec:       kube.PersistentVolumeClaimSpec{VolumeName: "pvc-1"},
+	}}}
+	source := &NodeSource{ProcfsRoot: filepath.Join(root, "proc"), KubeletRootDir: kubeletRootDir, Claims: node.NewClaimCache(lister, time.Minute)}
+	fallback := NewStatfsFallback(source, 10*time.Second)
+
+	usages := fallback.volumeStats(context.Background(), summary, nil, map[v1alpha1.PVCReference]bool{})
+	usage, ok := usages[v1alpha1.PVCReference{Name: "data", Namespace: "team-a"}]
+	if len(usages) != 1 || !ok {
+		t.Fatalf("got usage of %v, want team-a/data", usages)
+	}
+	if usage.source != volumeStatsSourceStatfs || usage.capacityBytes <= 0 {
+		t.Errorf("got usage %+v, want the capacity of the filesystem from statfs", usage)
+	}
+}
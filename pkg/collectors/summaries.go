@@ -0,0 +1,76 @@
+package collectors
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+// Summaries shares the summaries of a gather between its collectors, so that
+// each provider is asked for a summary once per gather instead of once per
+// collector.
+type Summaries struct {
+	mu        sync.Mutex
+	providers map[kubelet.SummaryProvider]*gatherSummary
+}
+
+// NewSummaries creates an empty set of summaries.
+func NewSummaries() *Summaries {
+	return &Summaries{providers: map[kubelet.SummaryProvider]*gatherSummary{}}
+}
+
+// Of returns a provider returning the summary of provider fetched first in
+// the current gather.
+func (s *Summaries) Of(provider kubelet.SummaryProvider) kubelet.SummaryProvider {
+	return &summaryOf{summaries: s, provider: provider}
+}
+
+// reset forgets the summaries of the previous gather.
+func (s *Summaries) reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.providers = map[kubelet.SummaryProvider]*gatherSummary{}
+}
+
+func (s *Summaries) get(provider kubelet.SummaryProvider) *gatherSummary {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	summary, ok := s.providers[provider]
+	if !ok {
+		summary = &gatherSummary{}
+		s.providers[provider] = summary
+	}
+	return summary
+}
+
+// gatherSummary is the summary of a provider in a gather.
+type gatherSummary struct {
+	mu      sync.Mutex
+	fetched bool
+	summary *v1alpha1.Summary
+	err     error
+}
+
+// summaryOf is the provider of a summary shared by the collectors of a
+// gather.
+type summaryOf struct {
+	summaries *Summaries
+	provider  kubelet.SummaryProvider
+}
+
+var _ kubelet.SummaryProvider = &summaryOf{}
+
+// GetSummary implements the kubelet.SummaryProvider interface. Collectors
+// asking while the summary is fetched wait for it, errors are shared too.
+func (p *summaryOf) GetSummary(ctx context.Context) (*v1alpha1.Summary, error) {
+	s := p.summaries.get(p.provider)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !s.fetched {
+		s.summary, s.err = p.provider.GetSummary(ctx)
+		s.fetched = true
+	}
+	return s.summary, s.err
+}
@@ -0,0 +1,47 @@
+package collectors
+
+import (
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// SampleTimestamps attaches the times the kubelet took samples to the
+// metrics built from them, instead of leaving them at scrape time. A nil
+// *SampleTimestamps attaches none.
+type SampleTimestamps struct {
+	// Window is how old samples may be. Older samples are dropped, as
+	// stamping them with the scrape time instead would put them after the
+	// next sample of the series.
+	Window time.Duration
+}
+
+// attach returns m with the timestamp t, or nil if t is older than the window
+// of now. m is returned as is if t is zero, i.e. the sample was taken while
+// collecting.
+func (s *SampleTimestamps) attach(m prometheus.Metric, t, now time.Time) prometheus.Metric {
+	if s == nil || t.IsZero() {
+		return m
+	}
+	if now.Sub(t) > s.Window {
+		return nil
+	}
+	return &timestampedMetric{Metric: m, timestampMs: t.UnixNano() / int64(time.Millisecond)}
+}
+
+// timestampedMetric is a metric with an explicit timestamp.
+type timestampedMetric struct {
+	prometheus.Metric
+	timestampMs int64
+}
+
+// Write implements the prometheus.Metric interface.
+func (m *timestampedMetric) Write(out *dto.Metric) error {
+	if err := m.Metric.Write(out); err != nil {
+		return err
+	}
+	out.TimestampMs = proto.Int64(m.timestampMs)
+	return nil
+}
@@ -0,0 +1,49 @@
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestSampleTimestampsAttach(t *testing.T) {
+	desc := prometheus.NewDesc("sample", "A sample", nil, nil)
+	now := time.Unix(1000, 0)
+	timestamps := &SampleTimestamps{Window: time.Minute}
+	tests := []struct {
+		name       string
+		timestamps *SampleTimestamps
+		t          time.Time
+		dropped    bool
+		// timestampMs is the timestamp of the sample, 0 for none.
+		timestampMs int64
+	}{
+		{name: "disabled", t: now.Add(-time.Second)},
+		{name: "taken while collecting", timestamps: timestamps},
+		{name: "within the window", timestamps: timestamps, t: now.Add(-30 * time.Second), timestampMs: 970000},
+		{name: "older than the window", timestamps: timestamps, t: now.Add(-2 * time.Minute), dropped: true},
+		{name: "from the future", timestamps: timestamps, t: now.Add(time.Second), timestampMs: 1001000},
+	}
+	for _, test := range tests {
+		m := test.timestamps.attach(prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1), test.t, now)
+		if m == nil {
+			if !test.dropped {
+				t.Errorf("%s: got the sample dropped", test.name)
+			}
+			continue
+		}
+		if test.dropped {
+			t.Errorf("%s: got the sample kept, want it dropped", test.name)
+			continue
+		}
+		out := &dto.Metric{}
+		if err := m.Write(out); err != nil {
+			t.Fatal(err)
+		}
+		if got := out.GetTimestampMs(); got != test.timestampMs {
+			t.Errorf("%s: got timestamp %d, want %d", test.name, got, test.timestampMs)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package collectors
+
+import (
+	"github.com/cofyc/kubelet-exporter/pkg/openmetrics"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// units are the units of metric families, declared in the OpenMetrics
+// exposition. Names of families with a unit end with it.
+var units = map[string]string{
+	volumeStatsCapacityBytesKey:        "bytes",
+	volumeStatsAvailableBytesKey:       "bytes",
+	volumeStatsUsedBytesKey:            "bytes",
+	volumeDiskReadBytesKey:             "bytes",
+	volumeDiskWrittenBytesKey:          "bytes",
+	volumeDiskReadTimeKey:              "seconds",
+	volumeDiskWriteTimeKey:             "seconds",
+	volumeDiskIOTimeKey:                "seconds",
+	volumeNFSReadBytesKey:              "bytes",
+	volumeNFSWriteBytesKey:             "bytes",
+	volumeNFSOperationRTTKey:           "seconds",
+	volumeNFSOperationExecuteKey:       "seconds",
+	volumeNFSOperationSentBytesKey:     "bytes",
+	volumeNFSOperationRecvBytesKey:     "bytes",
+	volumeProbeDurationKey:             "seconds",
+	localVolumeCapacityBytesKey:        "bytes",
+	localVolumeUsedBytesKey:            "bytes",
+	localVolumeUnboundCapacityBytesKey: "bytes",
+	orphanedVolumeBytesKey:             "bytes",
+	containerCPUUsageKey:               "seconds",
+	containerMemoryWorkingSetKey:       "bytes",
+	containerMemoryUsageKey:            "bytes",
+	containerMemoryRSSKey:              "bytes",
+	containerRootfsUsedBytesKey:        "bytes",
+	containerLogBytesKey:               "bytes",
+	containerLogNewestFileKey:          "bytes",
+	containerLogLastWriteAgeKey:        "seconds",
+}
+
+// Unit returns the unit of a metric family, or "" if it has none.
+func Unit(family string) string {
+	return units[family]
+}
+
+// newCreatedDesc returns the description of the gauge family holding the
+// creation times of the series of the counter family name, which the
+// OpenMetrics exposition turns into _created samples.
+func newCreatedDesc(name string, labels []string, constLabels prometheus.Labels) *prometheus.Desc {
+	return prometheus.NewDesc(
+		openmetrics.CreatedName(name),
+		"Time the series of "+name+" were created in seconds since the epoch",
+		labels, constLabels,
+	)
+}
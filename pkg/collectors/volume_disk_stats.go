@@ -0,0 +1,153 @@
+package collectors
+
+import (
+	"context"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+	"github.com/cofyc/kubelet-exporter/pkg/node"
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	volumeDiskReadsCompletedKey  = "kubelet_volume_disk_reads_completed_total"
+	volumeDiskWritesCompletedKey = "kubelet_volume_disk_writes_completed_total"
+	volumeDiskReadBytesKey       = "kubelet_volume_disk_read_bytes_total"
+	volumeDiskWrittenBytesKey    = "kubelet_volume_disk_written_bytes_total"
+	volumeDiskReadTimeKey        = "kubelet_volume_disk_read_time_seconds_total"
+	volumeDiskWriteTimeKey       = "kubelet_volume_disk_write_time_seconds_total"
+	volumeDiskIONowKey           = "kubelet_volume_disk_io_now"
+	volumeDiskIOTimeKey          = "kubelet_volume_disk_io_time_seconds_total"
+)
+
+var (
+	volumeDiskLabels = []string{"namespace", "persistentvolumeclaim", "device"}
+
+	volumeDiskReadsCompleted = prometheus.NewDesc(
+		volumeDiskReadsCompletedKey,
+		"Number of reads completed successfully on the block device of the volume",
+		volumeDiskLabels, nil,
+	)
+	volumeDiskWritesCompleted = prometheus.NewDesc(
+		volumeDiskWritesCompletedKey,
+		"Number of writes completed successfully on the block device of the volume",
+		volumeDiskLabels, nil,
+	)
+	volumeDiskReadBytes = prometheus.NewDesc(
+		volumeDiskReadBytesKey,
+		"Number of bytes read from the block device of the volume",
+		volumeDiskLabels, nil,
+	)
+	volumeDiskWrittenBytes = prometheus.NewDesc(
+		volumeDiskWrittenBytesKey,
+		"Number of bytes written to the block device of the volume",
+		volumeDiskLabels, nil,
+	)
+	volumeDiskReadTime = prometheus.NewDesc(
+		volumeDiskReadTimeKey,
+		"Number of seconds spent by all reads on the block device of the volume",
+		volumeDiskLabels, nil,
+	)
+	volumeDiskWriteTime = prometheus.NewDesc(
+		volumeDiskWriteTimeKey,
+		"Number of seconds spent by all writes on the block device of the volume",
+		volumeDiskLabels, nil,
+	)
+	volumeDiskIONow = prometheus.NewDesc(
+		volumeDiskIONowKey,
+		"Number of I/Os currently in progress on the block device of the volume",
+		volumeDiskLabels, nil,
+	)
+	volumeDiskIOTime = prometheus.NewDesc(
+		volumeDiskIOTimeKey,
+		"Number of seconds spent doing I/Os on the block device of the volume",
+		volumeDiskLabels, nil,
+	)
+)
+
+// volumeDiskStatsCollector collects I/O statistics of the block devices
+// backing PVCs.
+type volumeDiskStatsCollector struct {
+	provider kubelet.SummaryProvider
+	source   *NodeSource
+	filter   *Filter
+}
+
+// NewVolumeDiskStatsCollector creates a new volume disk stats prometheus
+// collector.
+func NewVolumeDiskStatsCollector(provider kubelet.SummaryProvider, source *NodeSource, filter *Filter) prometheus.Collector {
+	return &volumeDiskStatsCollector{provider: provider, source: source, filter: filter}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (collector *volumeDiskStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- volumeDiskReadsCompleted
+	ch <- volumeDiskWritesCompleted
+	ch <- volumeDiskReadBytes
+	ch <- volumeDiskWrittenBytes
+	ch <- volumeDiskReadTime
+	ch <- volumeDiskWriteTime
+	ch <- volumeDiskIONow
+	ch <- volumeDiskIOTime
+}
+
+// Collect implements the prometheus.Collector interface.
+func (collector *volumeDiskStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	statsSummary, err := collector.provider.GetSummary(ctx)
+	if err != nil {
+		glog.Errorf("failed to get stats summary: %v", err)
+		return
+	}
+	volumes, err := collector.source.pvcVolumes(ctx, statsSummary, collector.filter)
+	if err != nil {
+		glog.Errorf("failed to list pod volumes: %v", err)
+		return
+	}
+	mounts, err := node.ReadMountInfo(collector.source.ProcfsRoot)
+	if err != nil {
+		glog.Errorf("failed to read mountinfo: %v", err)
+		return
+	}
+	diskStats, err := node.ReadDiskStats(collector.source.ProcfsRoot)
+	if err != nil {
+		glog.Errorf("failed to read diskstats: %v", err)
+		return
+	}
+	mountsByPoint := node.MountsByPoint(mounts)
+
+	// The counters have no creation time. They count from when the kernel
+	// registered the device, which it doesn't expose, and the boot time is
+	// wrong for devices attached later, as most PVs are.
+	add := func(key string, desc *prometheus.Desc, valueType prometheus.ValueType, v float64, lv ...string) {
+		if !collector.filter.Family(key) {
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(desc, valueType, v, lv...)
+	}
+
+	for _, v := range volumes {
+		mount, ok := mountsByPoint[v.Path]
+		if !ok {
+			glog.V(4).Infof("volume %s/%s is not mounted on %s", v.PVC.Namespace, v.PVC.Name, v.Path)
+			continue
+		}
+		stats, ok := diskStats[node.DeviceNumber{Major: mount.Major, Minor: mount.Minor}]
+		if !ok {
+			// not backed by a block device, e.g. NFS
+			continue
+		}
+		lv := []string{v.PVC.Namespace, v.PVC.Name, stats.Device}
+		add(volumeDiskReadsCompletedKey, volumeDiskReadsCompleted, prometheus.CounterValue, float64(stats.ReadsCompleted), lv...)
+		add(volumeDiskWritesCompletedKey, volumeDiskWritesCompleted, prometheus.CounterValue, float64(stats.WritesCompleted), lv...)
+		add(volumeDiskReadBytesKey, volumeDiskReadBytes, prometheus.CounterValue, float64(stats.SectorsRead*node.DiskSectorSize), lv...)
+		add(volumeDiskWrittenBytesKey, volumeDiskWrittenBytes, prometheus.CounterValue, float64(stats.SectorsWritten*node.DiskSectorSize), lv...)
+		add(volumeDiskReadTimeKey, volumeDiskReadTime, prometheus.CounterValue, float64(stats.ReadTimeMs)/1000, lv...)
+		add(volumeDiskWriteTimeKey, volumeDiskWriteTime, prometheus.CounterValue, float64(stats.WriteTimeMs)/1000, lv...)
+		add(volumeDiskIONowKey, volumeDiskIONow, prometheus.GaugeValue, float64(stats.IOsInProgress), lv...)
+		add(volumeDiskIOTimeKey, volumeDiskIOTime, prometheus.CounterValue, float64(stats.IOTimeMs)/1000, lv...)
+	}
+}
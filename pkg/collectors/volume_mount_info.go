@@ -0,0 +1,112 @@
+package collectors
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+	"github.com/cofyc/kubelet-exporter/pkg/node"
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+const (
+	volumeMountInfoKey    = "kubelet_volume_mount_info"
+	podVolumeMountInfoKey = "kubelet_pod_volume_mount_info"
+)
+
+var (
+	volumeMountInfo = prometheus.NewDesc(
+		volumeMountInfoKey,
+		"Filesystem type, source device and key mount options of the volume, always 1",
+		[]string{"namespace", "persistentvolumeclaim", "fstype", "device", "options"}, nil,
+	)
+	podVolumeMountInfo = prometheus.NewDesc(
+		podVolumeMountInfoKey,
+		"Filesystem type, source device and key mount options of a volume of a pod, always 1",
+		[]string{"namespace", "pod", "volume", "plugin", "fstype", "device", "options"}, nil,
+	)
+)
+
+// volumeMountInfoCollector collects the mounts of the volume directories of
+// the pods running on the node from /proc/self/mountinfo.
+type volumeMountInfoCollector struct {
+	provider kubelet.SummaryProvider
+	source   *NodeSource
+	filter   *Filter
+}
+
+// NewVolumeMountInfoCollector creates a new volume mount info prometheus
+// collector.
+func NewVolumeMountInfoCollector(provider kubelet.SummaryProvider, source *NodeSource, filter *Filter) prometheus.Collector {
+	return &volumeMountInfoCollector{provider: provider, source: source, filter: filter}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (collector *volumeMountInfoCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- volumeMountInfo
+	ch <- podVolumeMountInfo
+}
+
+// Collect implements the prometheus.Collector interface.
+func (collector *volumeMountInfoCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	statsSummary, err := collector.provider.GetSummary(ctx)
+	if err != nil {
+		glog.Errorf("failed to get stats summary: %v", err)
+		return
+	}
+	volumes, err := collector.source.podVolumes(ctx, statsSummary)
+	if err != nil {
+		glog.Errorf("failed to list pod volumes: %v", err)
+		return
+	}
+	mounts, err := node.ReadMountInfo(collector.source.ProcfsRoot)
+	if err != nil {
+		glog.Errorf("failed to read mountinfo: %v", err)
+		return
+	}
+	mountsByPoint := node.MountsByPoint(mounts)
+
+	add := func(key string, desc *prometheus.Desc, lv ...string) {
+		if !collector.filter.Family(key) {
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, 1, lv...)
+	}
+
+	// A PVC mounted by several pods has a series per distinct mount.
+	type pvcMount struct {
+		pvc                     v1alpha1.PVCReference
+		fsType, device, options string
+	}
+	seen := map[pvcMount]bool{}
+	for _, v := range volumes {
+		if v.Pod == nil || !collector.filter.Namespace(v.Pod.Namespace) {
+			// not running anymore
+			continue
+		}
+		mount, ok := mountsByPoint[v.Path]
+		if !ok {
+			// e.g. empty dirs on the root filesystem
+			continue
+		}
+		options := strings.Join(mount.KeyOptions(), ",")
+		name := v.Name
+		if v.Stats != nil {
+			name = v.Stats.Name
+		}
+		add(podVolumeMountInfoKey, podVolumeMountInfo, v.Pod.Namespace, v.Pod.Name, name, v.Plugin, mount.FSType, mount.Source, options)
+		if v.PVC == nil {
+			continue
+		}
+		if key := (pvcMount{*v.PVC, mount.FSType, mount.Source, options}); !seen[key] {
+			seen[key] = true
+			add(volumeMountInfoKey, volumeMountInfo, v.PVC.Namespace, v.PVC.Name, mount.FSType, mount.Source, options)
+		}
+	}
+}
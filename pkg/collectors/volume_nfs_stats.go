@@ -0,0 +1,274 @@
+package collectors
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/procfs"
+)
+
+const (
+	volumeNFSReadBytesKey          = "kubelet_volume_nfs_read_bytes_total"
+	volumeNFSWriteBytesKey         = "kubelet_volume_nfs_write_bytes_total"
+	volumeNFSOperationsKey         = "kubelet_volume_nfs_operations_total"
+	volumeNFSRetransmissionsKey    = "kubelet_volume_nfs_operation_retransmissions_total"
+	volumeNFSMajorTimeoutsKey      = "kubelet_volume_nfs_operation_major_timeouts_total"
+	volumeNFSOperationRTTKey       = "kubelet_volume_nfs_operation_rtt_seconds_total"
+	volumeNFSOperationExecuteKey   = "kubelet_volume_nfs_operation_execute_seconds_total"
+	volumeNFSOperationSentBytesKey = "kubelet_volume_nfs_operation_sent_bytes_total"
+	volumeNFSOperationRecvBytesKey = "kubelet_volume_nfs_operation_received_bytes_total"
+)
+
+var (
+	volumeNFSLabels          = []string{"namespace", "persistentvolumeclaim", "export"}
+	volumeNFSOperationLabels = []string{"namespace", "persistentvolumeclaim", "export", "operation"}
+
+	volumeNFSReadBytes = prometheus.NewDesc(
+		volumeNFSReadBytesKey,
+		"Number of bytes read by applications from the NFS volume",
+		volumeNFSLabels, nil,
+	)
+	volumeNFSWriteBytes = prometheus.NewDesc(
+		volumeNFSWriteBytesKey,
+		"Number of bytes written by applications to the NFS volume",
+		volumeNFSLabels, nil,
+	)
+	volumeNFSOperations = prometheus.NewDesc(
+		volumeNFSOperationsKey,
+		"Number of NFS operations performed on the volume",
+		volumeNFSOperationLabels, nil,
+	)
+	volumeNFSRetransmissions = prometheus.NewDesc(
+		volumeNFSRetransmissionsKey,
+		"Number of retransmissions of NFS operations performed on the volume",
+		volumeNFSOperationLabels, nil,
+	)
+	volumeNFSMajorTimeouts = prometheus.NewDesc(
+		volumeNFSMajorTimeoutsKey,
+		"Number of major timeouts of NFS operations performed on the volume",
+		volumeNFSOperationLabels, nil,
+	)
+	volumeNFSOperationRTT = prometheus.NewDesc(
+		volumeNFSOperationRTTKey,
+		"Number of seconds between sending NFS operations on the volume and receiving the replies",
+		volumeNFSOperationLabels, nil,
+	)
+	volumeNFSOperationExecute = prometheus.NewDesc(
+		volumeNFSOperationExecuteKey,
+		"Number of seconds between queueing NFS operations on the volume and completing them",
+		volumeNFSOperationLabels, nil,
+	)
+	volumeNFSOperationSentBytes = prometheus.NewDesc(
+		volumeNFSOperationSentBytesKey,
+		"Number of bytes sent for NFS operations on the volume, including headers",
+		volumeNFSOperationLabels, nil,
+	)
+	volumeNFSOperationRecvBytes = prometheus.NewDesc(
+		volumeNFSOperationRecvBytesKey,
+		"Number of bytes received for NFS operations on the volume, including headers",
+		volumeNFSOperationLabels, nil,
+	)
+
+	// volumeNFSCreated are the descriptions of the creation times of the
+	// series of the NFS families, by family.
+	volumeNFSCreated = map[string]*prometheus.Desc{
+		volumeNFSReadBytesKey:          newCreatedDesc(volumeNFSReadBytesKey, volumeNFSLabels, nil),
+		volumeNFSWriteBytesKey:         newCreatedDesc(volumeNFSWriteBytesKey, volumeNFSLabels, nil),
+		volumeNFSOperationsKey:         newCreatedDesc(volumeNFSOperationsKey, volumeNFSOperationLabels, nil),
+		volumeNFSRetransmissionsKey:    newCreatedDesc(volumeNFSRetransmissionsKey, volumeNFSOperationLabels, nil),
+		volumeNFSMajorTimeoutsKey:      newCreatedDesc(volumeNFSMajorTimeoutsKey, volumeNFSOperationLabels, nil),
+		volumeNFSOperationRTTKey:       newCreatedDesc(volumeNFSOperationRTTKey, volumeNFSOperationLabels, nil),
+		volumeNFSOperationExecuteKey:   newCreatedDesc(volumeNFSOperationExecuteKey, volumeNFSOperationLabels, nil),
+		volumeNFSOperationSentBytesKey: newCreatedDesc(volumeNFSOperationSentBytesKey, volumeNFSOperationLabels, nil),
+		volumeNFSOperationRecvBytesKey: newCreatedDesc(volumeNFSOperationRecvBytesKey, volumeNFSOperationLabels, nil),
+	}
+)
+
+// mountTimesTTL is how long the time of a mount is kept after it was last
+// seen. Mounts are not forgotten as soon as a collection misses them, as
+// collections restricted to namespaces miss the mounts of the others.
+const mountTimesTTL = 10 * time.Minute
+
+// MountTimes caches the times NFS volumes were mounted across scrapes. They
+// are derived from the age of the mounts, which is only known to the second,
+// so deriving them anew on each scrape would make them jitter by a second. A
+// nil *MountTimes caches nothing.
+type MountTimes struct {
+	mu sync.Mutex
+	// mounts are the mount times by device and mount point.
+	mounts map[mountKey]mountTime
+}
+
+type mountKey struct {
+	device, mountPoint string
+}
+
+type mountTime struct {
+	// created is the mount time in seconds since the epoch.
+	created float64
+	// seen is when the mount was last collected.
+	seen time.Time
+}
+
+// NewMountTimes creates an empty cache of mount times.
+func NewMountTimes() *MountTimes {
+	return &MountTimes{mounts: map[mountKey]mountTime{}}
+}
+
+// mounted returns the time the device was mounted at mountPoint, given the
+// age of the mount at now. The cached time is kept while it is within the
+// precision of the age, otherwise the device was mounted again.
+func (t *MountTimes) mounted(device, mountPoint string, age time.Duration, now time.Time) float64 {
+	created := float64(now.Add(-age).Unix())
+	if t == nil {
+		return created
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	key := mountKey{device, mountPoint}
+	if cached, ok := t.mounts[key]; ok && math.Abs(created-cached.created) <= 1 {
+		created = cached.created
+	}
+	t.mounts[key] = mountTime{created: created, seen: now}
+	return created
+}
+
+// prune forgets the mounts not seen for mountTimesTTL at now.
+func (t *MountTimes) prune(now time.Time) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for key, m := range t.mounts {
+		if now.Sub(m.seen) > mountTimesTTL {
+			delete(t.mounts, key)
+		}
+	}
+}
+
+// volumeNFSStatsCollector collects NFS client statistics of NFS backed PVCs
+// from /proc/self/mountstats.
+type volumeNFSStatsCollector struct {
+	provider kubelet.SummaryProvider
+	source   *NodeSource
+	mounts   *MountTimes
+	filter   *Filter
+}
+
+// NewVolumeNFSStatsCollector creates a new volume NFS stats prometheus
+// collector. The times volumes were mounted are cached in mounts.
+func NewVolumeNFSStatsCollector(provider kubelet.SummaryProvider, source *NodeSource, mounts *MountTimes, filter *Filter) prometheus.Collector {
+	return &volumeNFSStatsCollector{provider: provider, source: source, mounts: mounts, filter: filter}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (collector *volumeNFSStatsCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- volumeNFSReadBytes
+	ch <- volumeNFSWriteBytes
+	ch <- volumeNFSOperations
+	ch <- volumeNFSRetransmissions
+	ch <- volumeNFSMajorTimeouts
+	ch <- volumeNFSOperationRTT
+	ch <- volumeNFSOperationExecute
+	ch <- volumeNFSOperationSentBytes
+	ch <- volumeNFSOperationRecvBytes
+	for _, desc := range volumeNFSCreated {
+		ch <- desc
+	}
+}
+
+// Collect implements the prometheus.Collector interface.
+func (collector *volumeNFSStatsCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	statsSummary, err := collector.provider.GetSummary(ctx)
+	if err != nil {
+		glog.Errorf("failed to get stats summary: %v", err)
+		return
+	}
+	volumes, err := collector.source.pvcVolumes(ctx, statsSummary, collector.filter)
+	if err != nil {
+		glog.Errorf("failed to list pod volumes: %v", err)
+		return
+	}
+	mounts, err := readMountStats(collector.source.ProcfsRoot)
+	if err != nil {
+		glog.Errorf("failed to read mountstats: %v", err)
+		return
+	}
+	mountsByPoint := make(map[string]*procfs.Mount, len(mounts))
+	for _, m := range mounts {
+		mountsByPoint[m.Mount] = m
+	}
+
+	// The statistics of an NFS mount count from the time it was mounted,
+	// which is only known to the second.
+	var created float64
+	add := func(key string, desc *prometheus.Desc, v float64, lv ...string) {
+		if !collector.filter.Family(key) {
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, v, lv...)
+		if created > 0 {
+			ch <- prometheus.MustNewConstMetric(volumeNFSCreated[key], prometheus.GaugeValue, created, lv...)
+		}
+	}
+	now := time.Now()
+	defer collector.mounts.prune(now)
+
+	for _, v := range volumes {
+		mount, ok := mountsByPoint[v.Path]
+		if !ok {
+			continue
+		}
+		stats, ok := mount.Stats.(*procfs.MountStatsNFS)
+		if !ok {
+			continue
+		}
+		created = 0
+		if stats.Age > 0 {
+			created = collector.mounts.mounted(mount.Device, mount.Mount, stats.Age, now)
+		}
+		lv := []string{v.PVC.Namespace, v.PVC.Name, mount.Device}
+		add(volumeNFSReadBytesKey, volumeNFSReadBytes, float64(stats.Bytes.ReadTotal), lv...)
+		add(volumeNFSWriteBytesKey, volumeNFSWriteBytes, float64(stats.Bytes.WriteTotal), lv...)
+		for _, op := range stats.Operations {
+			if op.Requests == 0 {
+				continue
+			}
+			opLv := append(lv[:len(lv):len(lv)], op.Operation)
+			var retransmissions uint64
+			if op.Transmissions > op.Requests {
+				retransmissions = op.Transmissions - op.Requests
+			}
+			add(volumeNFSOperationsKey, volumeNFSOperations, float64(op.Requests), opLv...)
+			add(volumeNFSRetransmissionsKey, volumeNFSRetransmissions, float64(retransmissions), opLv...)
+			add(volumeNFSMajorTimeoutsKey, volumeNFSMajorTimeouts, float64(op.MajorTimeouts), opLv...)
+			add(volumeNFSOperationRTTKey, volumeNFSOperationRTT, op.CumulativeTotalResponseTime.Seconds(), opLv...)
+			add(volumeNFSOperationExecuteKey, volumeNFSOperationExecute, op.CumulativeTotalRequestTime.Seconds(), opLv...)
+			add(volumeNFSOperationSentBytesKey, volumeNFSOperationSentBytes, float64(op.BytesSent), opLv...)
+			add(volumeNFSOperationRecvBytesKey, volumeNFSOperationRecvBytes, float64(op.BytesReceived), opLv...)
+		}
+	}
+}
+
+// readMountStats reads /proc/self/mountstats from the procfs mounted at
+// procfsRoot.
+func readMountStats(procfsRoot string) ([]*procfs.Mount, error) {
+	fs, err := procfs.NewFS(procfsRoot)
+	if err != nil {
+		return nil, err
+	}
+	self, err := fs.Self()
+	if err != nil {
+		return nil, err
+	}
+	return self.MountStats()
+}
@@ -0,0 +1,225 @@
+package collectors
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	collectorstesting "github.com/cofyc/kubelet-exporter/pkg/collectors/testing"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+func TestMountTimes(t *testing.T) {
+	mounts := NewMountTimes()
+	now := time.Unix(1000, 0)
+	mounted := func(device string, age time.Duration, now time.Time) float64 {
+		return mounts.mounted(device, "/var/lib/kubelet/pods/uid-1/volumes/kubernetes.io~nfs/data", age, now)
+	}
+
+	if got := mounted("nfs:/export", 100*time.Second, now.Add(500*time.Millisecond)); got != 900 {
+		t.Fatalf("got mount time %v, want 900", got)
+	}
+	// The age is truncated to the second, the mount time is kept.
+	if got := mounted("nfs:/export", 100*time.Second, now.Add(1500*time.Millisecond)); got != 900 {
+		t.Errorf("got mount time %v a second later, want the cached 900", got)
+	}
+	if got := mounted("nfs:/export", 101*time.Second, now.Add(1100*time.Millisecond)); got != 900 {
+		t.Errorf("got mount time %v, want the cached 900", got)
+	}
+	// Mounted again.
+	if got := mounted("nfs:/export", 5*time.Second, now.Add(time.Minute)); got != 1055 {
+		t.Errorf("got mount time %v after mounting again, want 1055", got)
+	}
+	// Another device at the same mount point.
+	if got := mounted("nfs:/other", 10*time.Second, now.Add(time.Minute)); got != 1050 {
+		t.Errorf("got mount time %v of another device, want 1050", got)
+	}
+
+	mounts.prune(now.Add(time.Minute + mountTimesTTL))
+	if len(mounts.mounts) != 2 {
+		t.Errorf("got %d mount times after pruning within the TTL, want 2", len(mounts.mounts))
+	}
+	mounts.prune(now.Add(time.Minute + mountTimesTTL + time.Second))
+	if len(mounts.mounts) != 0 {
+		t.Errorf("got %d mount times after pruning, want none", len(mounts.mounts))
+	}
+
+	var none *MountTimes
+	if got := none.mounted("nfs:/export", "/mnt", 100*time.Second, now); got != 900 {
+		t.Errorf("got mount time %v without cache, want 900", got)
+	}
+	none.prune(now)
+}
+
+// newNFSNode creates the kubelet root dir and procfs of a node with the NFS
+// volumes of testdata/mountstats in dir, and the summary of their pods.
+func newNFSNode(t *testing.T, dir string) (*NodeSource, *v1alpha1.Summary) {
+	source := &NodeSource{ProcfsRoot: filepath.Join(dir, "proc"), KubeletRootDir: filepath.Join(dir, "kubelet")}
+	summary := &v1alpha1.Summary{}
+	for _, v := range []struct{ uid, namespace, name string }{
+		{"uid-1", "team-a", "data"},
+		{"uid-2", "team-b", "logs"},
+	} {
+		if err := os.MkdirAll(filepath.Join(source.KubeletRootDir, "pods", v.uid, "volumes", "kubernetes.io~nfs", v.name), 0700); err != nil {
+			t.Fatal(err)
+		}
+		summary.Pods = append(summary.Pods, v1alpha1.PodStats{
+			PodRef:      v1alpha1.PodReference{Name: "pod-" + v.uid, Namespace: v.namespace, UID: v.uid},
+			VolumeStats: []v1alpha1.VolumeStats{{Name: v.name, PVCRef: &v1alpha1.PVCReference{Name: v.name, Namespace: v.namespace}}},
+		})
+	}
+	mountstats, err := ioutil.ReadFile(filepath.Join("testdata", "mountstats"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mountstats = []byte(strings.Replace(string(mountstats), "KUBELET_ROOT_DIR", source.KubeletRootDir, -1))
+	if err := os.MkdirAll(filepath.Join(source.ProcfsRoot, "1"), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(source.ProcfsRoot, "1", "mountstats"), mountstats, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink("1", filepath.Join(source.ProcfsRoot, "self")); err != nil {
+		t.Fatal(err)
+	}
+	return source, summary
+}
+
+// gatherCreated returns the values of the creation time families gathered
+// from collector by series.
+func gatherCreated(t *testing.T, collector prometheus.Collector) map[string]float64 {
+	registry := prometheus.NewPedanticRegistry()
+	registry.MustRegister(collector)
+	families, err := registry.Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := map[string]float64{}
+	for _, family := range families {
+		if !strings.HasSuffix(family.GetName(), "_created") {
+			continue
+		}
+		for _, m := range family.Metric {
+			var labels []string
+			for _, l := range m.Label {
+				labels = append(labels, l.GetValue())
+			}
+			created[family.GetName()+"{"+strings.Join(labels, ",")+"}"] = m.GetGauge().GetValue()
+		}
+	}
+	return created
+}
+
+func TestVolumeNFSStatsCollector(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source, summary := newNFSNode(t, dir)
+	collector := NewVolumeNFSStatsCollector(&fakeProvider{summary: summary}, source, NewMountTimes(), nil)
+
+	// Operations without requests are skipped.
+	expected := `
+		# HELP kubelet_volume_nfs_operation_execute_seconds_total Number of seconds between queueing NFS operations on the volume and completing them
+		# TYPE kubelet_volume_nfs_operation_execute_seconds_total counter
+		kubelet_volume_nfs_operation_execute_seconds_total{export="nfs.example.com:/exports/data",namespace="team-a",operation="READ",persistentvolumeclaim="data"} 0.3
+		kubelet_volume_nfs_operation_execute_seconds_total{export="nfs.example.com:/exports/data",namespace="team-a",operation="WRITE",persistentvolumeclaim="data"} 0.12
+		kubelet_volume_nfs_operation_execute_seconds_total{export="nfs.example.com:/exports/logs",namespace="team-b",operation="WRITE",persistentvolumeclaim="logs"} 0.003
+		# HELP kubelet_volume_nfs_operation_major_timeouts_total Number of major timeouts of NFS operations performed on the volume
+		# TYPE kubelet_volume_nfs_operation_major_timeouts_total counter
+		kubelet_volume_nfs_operation_major_timeouts_total{export="nfs.example.com:/exports/data",namespace="team-a",operation="READ",persistentvolumeclaim="data"} 1
+		kubelet_volume_nfs_operation_major_timeouts_total{export="nfs.example.com:/exports/data",namespace="team-a",operation="WRITE",persistentvolumeclaim="data"} 0
+		kubelet_volume_nfs_operation_major_timeouts_total{export="nfs.example.com:/exports/logs",namespace="team-b",operation="WRITE",persistentvolumeclaim="logs"} 0
+		# HELP kubelet_volume_nfs_operation_received_bytes_total Number of bytes received for NFS operations on the volume, including headers
+		# TYPE kubelet_volume_nfs_operation_received_bytes_total counter
+		kubelet_volume_nfs_operation_received_bytes_total{export="nfs.example.com:/exports/data",namespace="team-a",operation="READ",persistentvolumeclaim="data"} 40960
+		kubelet_volume_nfs_operation_received_bytes_total{export="nfs.example.com:/exports/data",namespace="team-a",operation="WRITE",persistentvolumeclaim="data"} 640
+		kubelet_volume_nfs_operation_received_bytes_total{export="nfs.example.com:/exports/logs",namespace="team-b",operation="WRITE",persistentvolumeclaim="logs"} 120
+		# HELP kubelet_volume_nfs_operation_retransmissions_total Number of retransmissions of NFS operations performed on the volume
+		# TYPE kubelet_volume_nfs_operation_retransmissions_total counter
+		kubelet_volume_nfs_operation_retransmissions_total{export="nfs.example.com:/exports/data",namespace="team-a",operation="READ",persistentvolumeclaim="data"} 2
+		kubelet_volume_nfs_operation_retransmissions_total{export="nfs.example.com:/exports/data",namespace="team-a",operation="WRITE",persistentvolumeclaim="data"} 0
+		kubelet_volume_nfs_operation_retransmissions_total{export="nfs.example.com:/exports/logs",namespace="team-b",operation="WRITE",persistentvolumeclaim="logs"} 0
+		# HELP kubelet_volume_nfs_operation_rtt_seconds_total Number of seconds between sending NFS operations on the volume and receiving the replies
+		# TYPE kubelet_volume_nfs_operation_rtt_seconds_total counter
+		kubelet_volume_nfs_operation_rtt_seconds_total{export="nfs.example.com:/exports/data",namespace="team-a",operation="READ",persistentvolumeclaim="data"} 0.25
+		kubelet_volume_nfs_operation_rtt_seconds_total{export="nfs.example.com:/exports/data",namespace="team-a",operation="WRITE",persistentvolumeclaim="data"} 0.1
+		kubelet_volume_nfs_operation_rtt_seconds_total{export="nfs.example.com:/exports/logs",namespace="team-b",operation="WRITE",persistentvolumeclaim="logs"} 0.002
+		# HELP kubelet_volume_nfs_operation_sent_bytes_total Number of bytes sent for NFS operations on the volume, including headers
+		# TYPE kubelet_volume_nfs_operation_sent_bytes_total counter
+		kubelet_volume_nfs_operation_sent_bytes_total{export="nfs.example.com:/exports/data",namespace="team-a",operation="READ",persistentvolumeclaim="data"} 1440
+		kubelet_volume_nfs_operation_sent_bytes_total{export="nfs.example.com:/exports/data",namespace="team-a",operation="WRITE",persistentvolumeclaim="data"} 8800
+		kubelet_volume_nfs_operation_sent_bytes_total{export="nfs.example.com:/exports/logs",namespace="team-b",operation="WRITE",persistentvolumeclaim="logs"} 640
+		# HELP kubelet_volume_nfs_operations_total Number of NFS operations performed on the volume
+		# TYPE kubelet_volume_nfs_operations_total counter
+		kubelet_volume_nfs_operations_total{export="nfs.example.com:/exports/data",namespace="team-a",operation="READ",persistentvolumeclaim="data"} 10
+		kubelet_volume_nfs_operations_total{export="nfs.example.com:/exports/data",namespace="team-a",operation="WRITE",persistentvolumeclaim="data"} 4
+		kubelet_volume_nfs_operations_total{export="nfs.example.com:/exports/logs",namespace="team-b",operation="WRITE",persistentvolumeclaim="logs"} 1
+		# HELP kubelet_volume_nfs_read_bytes_total Number of bytes read by applications from the NFS volume
+		# TYPE kubelet_volume_nfs_read_bytes_total counter
+		kubelet_volume_nfs_read_bytes_total{export="nfs.example.com:/exports/data",namespace="team-a",persistentvolumeclaim="data"} 4096
+		kubelet_volume_nfs_read_bytes_total{export="nfs.example.com:/exports/logs",namespace="team-b",persistentvolumeclaim="logs"} 0
+		# HELP kubelet_volume_nfs_write_bytes_total Number of bytes written by applications to the NFS volume
+		# TYPE kubelet_volume_nfs_write_bytes_total counter
+		kubelet_volume_nfs_write_bytes_total{export="nfs.example.com:/exports/data",namespace="team-a",persistentvolumeclaim="data"} 8192
+		kubelet_volume_nfs_write_bytes_total{export="nfs.example.com:/exports/logs",namespace="team-b",persistentvolumeclaim="logs"} 512
+	`
+	metricNames := []string{
+		"kubelet_volume_nfs_operation_execute_seconds_total",
+		"kubelet_volume_nfs_operation_major_timeouts_total",
+		"kubelet_volume_nfs_operation_received_bytes_total",
+		"kubelet_volume_nfs_operation_retransmissions_total",
+		"kubelet_volume_nfs_operation_rtt_seconds_total",
+		"kubelet_volume_nfs_operation_sent_bytes_total",
+		"kubelet_volume_nfs_operations_total",
+		"kubelet_volume_nfs_read_bytes_total",
+		"kubelet_volume_nfs_write_bytes_total",
+	}
+	if err := collectorstesting.GatherAndCompare(collector, expected, metricNames); err != nil {
+		t.Fatal(err)
+	}
+
+	// Mounted 600s ago, the mount without an age has no creation times.
+	before := time.Now()
+	created := gatherCreated(t, collector)
+	after := time.Now()
+	if n := len(created); n != 2+7*2 {
+		t.Errorf("got %d creation times %v, want 16 of the mount with an age", n, created)
+	}
+	for series, v := range created {
+		if !strings.Contains(series, "nfs.example.com:/exports/data") {
+			t.Errorf("got creation time of %s", series)
+		}
+		if v < float64(before.Unix()-601) || v > float64(after.Unix()-599) {
+			t.Errorf("got creation time %v of %s, want 600s before %v", v, series, before)
+		}
+	}
+}
+
+func TestVolumeNFSStatsCollectorKeepsMountTimesOfOtherNamespaces(t *testing.T) {
+	dir, err := ioutil.TempDir("", "nfs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	source, summary := newNFSNode(t, dir)
+	mounts := NewMountTimes()
+	provider := &fakeProvider{summary: summary}
+	key := mountKey{"nfs.example.com:/exports/data", filepath.Join(source.KubeletRootDir, "pods/uid-1/volumes/kubernetes.io~nfs/data")}
+
+	gatherCreated(t, NewVolumeNFSStatsCollector(provider, source, mounts, nil))
+	cached, ok := mounts.mounts[key]
+	if !ok {
+		t.Fatalf("got mount times %v, want the one of team-a", mounts.mounts)
+	}
+	// A scrape of team-b doesn't see the mount of team-a, which is kept.
+	gatherCreated(t, NewVolumeNFSStatsCollector(provider, source, mounts, (*Filter)(nil).WithNamespaces([]string{"team-b"})))
+	if got, ok := mounts.mounts[key]; !ok || got != cached {
+		t.Errorf("got mount time %v (%v) after a scrape of another namespace, want the cached %v", got, ok, cached)
+	}
+}
@@ -0,0 +1,267 @@
+package collectors
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
+	"github.com/cofyc/kubelet-exporter/pkg/node"
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/util/wait"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+const (
+	volumeProbeSuccessKey  = "kubelet_volume_probe_success"
+	volumeProbeDurationKey = "kubelet_volume_probe_duration_seconds"
+	volumeProbeReadOnlyKey = "kubelet_volume_probe_read_only"
+
+	// canaryFile is the file written by the canary probe in the volume.
+	canaryFile = ".kubelet-exporter-canary"
+)
+
+var (
+	volumeProbeSuccess = prometheus.NewDesc(
+		volumeProbeSuccessKey,
+		"Whether the last probe of the volume succeeded",
+		[]string{"namespace", "persistentvolumeclaim"}, nil,
+	)
+	volumeProbeDuration = prometheus.NewDesc(
+		volumeProbeDurationKey,
+		"Duration of the last probe of the volume in seconds, up to the probe timeout",
+		[]string{"namespace", "persistentvolumeclaim"}, nil,
+	)
+	volumeProbeReadOnly = prometheus.NewDesc(
+		volumeProbeReadOnlyKey,
+		"Whether the volume was found read-only by the last probe",
+		[]string{"namespace", "persistentvolumeclaim"}, nil,
+	)
+)
+
+// ProbeResult is the result of the last probe of a volume.
+type ProbeResult struct {
+	PVC      v1alpha1.PVCReference `json:"pvc"`
+	Path     string                `json:"path"`
+	Time     time.Time             `json:"time"`
+	Success  bool                  `json:"success"`
+	Duration time.Duration         `json:"duration"`
+	ReadOnly bool                  `json:"readOnly"`
+	Error    string                `json:"error,omitempty"`
+}
+
+// VolumeProber periodically probes the mounts of the PVCs of the pods on the
+// node with statfs, and optionally by writing a canary file. The summary API
+// reports hung or read-only filesystems as healthy.
+type VolumeProber struct {
+	provider kubelet.SummaryProvider
+	source   *NodeSource
+	interval time.Duration
+	timeout  time.Duration
+	canary   bool
+	// statfs is node.Statfs, replaced in tests.
+	statfs func(path string) (*node.FsStats, error)
+
+	mu      sync.Mutex
+	results map[v1alpha1.PVCReference]ProbeResult
+	// hung are the paths with a probe which didn't return yet. They are not
+	// probed again until it returns.
+	hung map[string]bool
+}
+
+// NewVolumeProber creates a prober probing every interval. Each probe may
+// take up to timeout.
+func NewVolumeProber(provider kubelet.SummaryProvider, source *NodeSource, interval, timeout time.Duration, canary bool) *VolumeProber {
+	return &VolumeProber{
+		provider: provider,
+		source:   source,
+		interval: interval,
+		timeout:  timeout,
+		canary:   canary,
+		statfs:   node.Statfs,
+		results:  map[v1alpha1.PVCReference]ProbeResult{},
+		hung:     map[string]bool{},
+	}
+}
+
+// Run probes until stopCh is closed.
+func (p *VolumeProber) Run(stopCh <-chan struct{}) {
+	wait.Until(p.ProbeAll, p.interval, stopCh)
+}
+
+// Results returns the results of the last probes.
+func (p *VolumeProber) Results() []ProbeResult {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	results := make([]ProbeResult, 0, len(p.results))
+	for _, r := range p.results {
+		results = append(results, r)
+	}
+	return results
+}
+
+// ProbeAll probes the mounts of all PVCs once. The results of the previous
+// probes are cleared if the PVCs can't be listed, rather than exported as if
+// they were fresh.
+func (p *VolumeProber) ProbeAll() {
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	statsSummary, err := p.provider.GetSummary(ctx)
+	if err != nil {
+		glog.Errorf("failed to get stats summary: %v", err)
+		p.clearResults()
+		return
+	}
+	volumes, err := p.source.pvcVolumes(ctx, statsSummary, nil)
+	if err != nil {
+		glog.Errorf("failed to list pod volumes: %v", err)
+		p.clearResults()
+		return
+	}
+
+	results := map[v1alpha1.PVCReference]ProbeResult{}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for _, v := range volumes {
+		wg.Add(1)
+		go func(pvc v1alpha1.PVCReference, path string) {
+			defer wg.Done()
+			r := p.probe(path)
+			r.PVC = pvc
+			mu.Lock()
+			results[pvc] = r
+			mu.Unlock()
+		}(*v.PVC, v.Path)
+	}
+	wg.Wait()
+
+	p.mu.Lock()
+	p.results = results
+	p.mu.Unlock()
+}
+
+func (p *VolumeProber) clearResults() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.results = map[v1alpha1.PVCReference]ProbeResult{}
+}
+
+// probe probes path, giving up after the timeout. A probe which gives up
+// keeps running in the background, the path is not probed again until it
+// returns.
+func (p *VolumeProber) probe(path string) ProbeResult {
+	result := ProbeResult{Path: path, Time: time.Now()}
+	p.mu.Lock()
+	if p.hung[path] {
+		p.mu.Unlock()
+		result.Error = "previous probe did not return yet"
+		result.Duration = p.timeout
+		return result
+	}
+	p.hung[path] = true
+	p.mu.Unlock()
+
+	done := make(chan ProbeResult, 1)
+	go func() {
+		r := p.doProbe(path)
+		p.mu.Lock()
+		delete(p.hung, path)
+		p.mu.Unlock()
+		done <- r
+	}()
+	select {
+	case r := <-done:
+		result.Success, result.ReadOnly, result.Error = r.Success, r.ReadOnly, r.Error
+		result.Duration = time.Since(result.Time)
+	case <-time.After(p.timeout):
+		result.Error = fmt.Sprintf("probe timed out after %v", p.timeout)
+		result.Duration = p.timeout
+	}
+	return result
+}
+
+func (p *VolumeProber) doProbe(path string) ProbeResult {
+	stats, err := p.statfs(path)
+	if err != nil {
+		return ProbeResult{Error: fmt.Sprintf("statfs: %v", err)}
+	}
+	if stats.ReadOnly || !p.canary {
+		return ProbeResult{Success: true, ReadOnly: stats.ReadOnly}
+	}
+	if err := writeCanary(filepath.Join(path, canaryFile)); err != nil {
+		if pe, ok := err.(*os.PathError); ok && pe.Err == syscall.EROFS {
+			return ProbeResult{Success: true, ReadOnly: true}
+		}
+		return ProbeResult{Error: fmt.Sprintf("canary: %v", err)}
+	}
+	return ProbeResult{Success: true}
+}
+
+// writeCanary writes a tiny file, syncs it to the disk and removes it.
+func writeCanary(name string) error {
+	f, err := os.OpenFile(name, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(name)
+	if _, err := f.Write([]byte(time.Now().UTC().Format(time.RFC3339))); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// volumeProbeCollector collects the results of a volume prober.
+type volumeProbeCollector struct {
+	prober *VolumeProber
+	filter *Filter
+}
+
+// NewVolumeProbeCollector creates a new volume probe prometheus collector.
+func NewVolumeProbeCollector(prober *VolumeProber, filter *Filter) prometheus.Collector {
+	return &volumeProbeCollector{prober: prober, filter: filter}
+}
+
+// Describe implements the prometheus.Collector interface.
+func (collector *volumeProbeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- volumeProbeSuccess
+	ch <- volumeProbeDuration
+	ch <- volumeProbeReadOnly
+}
+
+// Collect implements the prometheus.Collector interface.
+func (collector *volumeProbeCollector) Collect(ch chan<- prometheus.Metric) {
+	add := func(key string, desc *prometheus.Desc, v float64, lv ...string) {
+		if !collector.filter.Family(key) {
+			return
+		}
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
+	}
+	for _, r := range collector.prober.Results() {
+		if !collector.filter.Namespace(r.PVC.Namespace) {
+			continue
+		}
+		add(volumeProbeSuccessKey, volumeProbeSuccess, boolFloat64(r.Success), r.PVC.Namespace, r.PVC.Name)
+		add(volumeProbeDurationKey, volumeProbeDuration, r.Duration.Seconds(), r.PVC.Namespace, r.PVC.Name)
+		add(volumeProbeReadOnlyKey, volumeProbeReadOnly, boolFloat64(r.ReadOnly), r.PVC.Namespace, r.PVC.Name)
+	}
+}
+
+func boolFloat64(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
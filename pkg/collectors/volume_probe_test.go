@@ -0,0 +1,167 @@
+package collectors
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	collectorstesting "github.com/cofyc/kubelet-exporter/pkg/collectors/testing"
+	"github.com/cofyc/kubelet-exporter/pkg/node"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+// newTestProber creates a prober of no volumes whose statfs calls stat.
+func newTestProber(timeout time.Duration, canary bool, stat func(path string) (*node.FsStats, error)) *VolumeProber {
+	p := NewVolumeProber(nil, nil, time.Minute, timeout, canary)
+	p.statfs = stat
+	return p
+}
+
+func TestVolumeProbe(t *testing.T) {
+	tests := []struct {
+		name     string
+		stats    *node.FsStats
+		err      error
+		success  bool
+		readOnly bool
+		errorMsg string
+	}{
+		{name: "success", stats: &node.FsStats{}, success: true},
+		{name: "read-only", stats: &node.FsStats{ReadOnly: true}, success: true, readOnly: true},
+		{name: "statfs error", err: errors.New("stale file handle"), errorMsg: "statfs: stale file handle"},
+	}
+	for _, test := range tests {
+		p := newTestProber(time.Second, false, func(path string) (*node.FsStats, error) {
+			return test.stats, test.err
+		})
+		r := p.probe("/volume")
+		if r.Success != test.success || r.ReadOnly != test.readOnly || r.Error != test.errorMsg {
+			t.Errorf("%s: got success %v, read-only %v and error %q, want %v, %v and %q", test.name, r.Success, r.ReadOnly, r.Error, test.success, test.readOnly, test.errorMsg)
+		}
+		if r.Path != "/volume" || r.Duration <= 0 || r.Duration > time.Second {
+			t.Errorf("%s: got path %s and duration %v", test.name, r.Path, r.Duration)
+		}
+	}
+}
+
+func TestVolumeProbeTimeout(t *testing.T) {
+	release := make(chan struct{})
+	calls := make(chan string, 10)
+	p := newTestProber(10*time.Millisecond, false, func(path string) (*node.FsStats, error) {
+		calls <- path
+		<-release
+		return &node.FsStats{}, nil
+	})
+
+	r := p.probe("/hung")
+	if r.Success || r.Error != "probe timed out after 10ms" || r.Duration != 10*time.Millisecond {
+		t.Errorf("got success %v, error %q and duration %v, want a timeout after 10ms", r.Success, r.Error, r.Duration)
+	}
+	// The hung path isn't probed again until the probe returns.
+	r = p.probe("/hung")
+	if r.Success || r.Error != "previous probe did not return yet" || r.Duration != 10*time.Millisecond {
+		t.Errorf("got success %v, error %q and duration %v, want the previous probe to be pending", r.Success, r.Error, r.Duration)
+	}
+	if n := len(calls); n != 1 {
+		t.Errorf("statfs called %d times, want once", n)
+	}
+
+	close(release)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		p.mu.Lock()
+		hung := p.hung["/hung"]
+		p.mu.Unlock()
+		if !hung {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("path still hung after the probe returned")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if r := p.probe("/hung"); !r.Success {
+		t.Errorf("probe failed after the path recovered: %s", r.Error)
+	}
+}
+
+func TestVolumeProbeCanary(t *testing.T) {
+	dir, err := ioutil.TempDir("", "volume-probe")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	var statted bool
+	p := newTestProber(5*time.Second, true, func(path string) (*node.FsStats, error) {
+		return &node.FsStats{}, nil
+	})
+
+	r := p.probe(dir)
+	if !r.Success || r.ReadOnly || r.Error != "" {
+		t.Errorf("got success %v, read-only %v and error %q, want success", r.Success, r.ReadOnly, r.Error)
+	}
+	if _, err := os.Stat(filepath.Join(dir, canaryFile)); !os.IsNotExist(err) {
+		t.Errorf("canary file not removed: %v", err)
+	}
+
+	// The canary is written through the mount: a file in the way of the
+	// directory fails the probe.
+	blocked := filepath.Join(dir, "blocked")
+	if err := ioutil.WriteFile(blocked, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	r = p.probe(blocked)
+	if r.Success || !strings.HasPrefix(r.Error, "canary: ") {
+		t.Errorf("got success %v and error %q, want a canary error", r.Success, r.Error)
+	}
+
+	// Read-only filesystems are not written to.
+	p.statfs = func(path string) (*node.FsStats, error) {
+		statted = true
+		return &node.FsStats{ReadOnly: true}, nil
+	}
+	if r := p.probe(blocked); !r.Success || !r.ReadOnly || !statted {
+		t.Errorf("got success %v, read-only %v and error %q, want a read-only success", r.Success, r.ReadOnly, r.Error)
+	}
+}
+
+func TestVolumeProbeCollector(t *testing.T) {
+	p := newTestProber(time.Second, false, nil)
+	p.results = map[v1alpha1.PVCReference]ProbeResult{
+		{Namespace: "team-a", Name: "data"}: {PVC: v1alpha1.PVCReference{Namespace: "team-a", Name: "data"}, Success: true, Duration: 2 * time.Millisecond},
+		{Namespace: "team-b", Name: "logs"}: {PVC: v1alpha1.PVCReference{Namespace: "team-b", Name: "logs"}, Duration: time.Second, ReadOnly: true},
+	}
+	expected := `
+		# HELP kubelet_volume_probe_duration_seconds Duration of the last probe of the volume in seconds, up to the probe timeout
+		# TYPE kubelet_volume_probe_duration_seconds gauge
+		kubelet_volume_probe_duration_seconds{namespace="team-a",persistentvolumeclaim="data"} 0.002
+		kubelet_volume_probe_duration_seconds{namespace="team-b",persistentvolumeclaim="logs"} 1
+		# HELP kubelet_volume_probe_read_only Whether the volume was found read-only by the last probe
+		# TYPE kubelet_volume_probe_read_only gauge
+		kubelet_volume_probe_read_only{namespace="team-a",persistentvolumeclaim="data"} 0
+		kubelet_volume_probe_read_only{namespace="team-b",persistentvolumeclaim="logs"} 1
+		# HELP kubelet_volume_probe_success Whether the last probe of the volume succeeded
+		# TYPE kubelet_volume_probe_success gauge
+		kubelet_volume_probe_success{namespace="team-a",persistentvolumeclaim="data"} 1
+		kubelet_volume_probe_success{namespace="team-b",persistentvolumeclaim="logs"} 0
+	`
+	if err := collectorstesting.GatherAndCompare(NewVolumeProbeCollector(p, nil), expected, nil); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestVolumeProbeAllClearsResultsWithoutSummary(t *testing.T) {
+	provider := &fakeProvider{err: errors.New("connection refused")}
+	p := NewVolumeProber(provider, nil, time.Minute, time.Second, false)
+	p.results = map[v1alpha1.PVCReference]ProbeResult{
+		{Namespace: "team-a", Name: "data"}: {PVC: v1alpha1.PVCReference{Namespace: "team-a", Name: "data"}, Success: true},
+	}
+	p.ProbeAll()
+	if results := p.Results(); len(results) != 0 {
+		t.Errorf("got results %v without a summary, want none", results)
+	}
+}
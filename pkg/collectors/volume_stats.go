@@ -2,15 +2,12 @@ package collectors
 
 import (
 	"context"
-	"encoding/json"
-	"io/ioutil"
-	"net/http"
+	"strings"
 	"time"
 
+	"github.com/cofyc/kubelet-exporter/pkg/kubelet"
 	"github.com/golang/glog"
 	"github.com/prometheus/client_golang/prometheus"
-	"golang.org/x/net/context/ctxhttp"
-	"k8s.io/apimachinery/pkg/util/sets"
 	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
 )
 
@@ -21,59 +18,115 @@ const (
 	volumeStatsInodesKey         = "kubelet_volume_stats_inodes"
 	volumeStatsInodesFreeKey     = "kubelet_volume_stats_inodes_free"
 	volumeStatsInodesUsedKey     = "kubelet_volume_stats_inodes_used"
+	volumeConditionAbnormalKey   = "kubelet_volume_condition_abnormal"
+	volumeStatsAgeKey            = "kubelet_volume_stats_age_seconds"
 )
 
+// volumeStatsDescs are the descriptions of the volume stats.
+type volumeStatsDescs struct {
+	capacityBytes, availableBytes, usedBytes, inodes, inodesFree, inodesUsed, conditionAbnormal, age *prometheus.Desc
+}
+
+func newVolumeStatsDescs(labels []string) *volumeStatsDescs {
+	return &volumeStatsDescs{
+		capacityBytes: prometheus.NewDesc(
+			volumeStatsCapacityBytesKey,
+			"Capacity in bytes of the volume",
+			labels, nil,
+		),
+		availableBytes: prometheus.NewDesc(
+			volumeStatsAvailableBytesKey,
+			"Number of available bytes in the volume",
+			labels, nil,
+		),
+		usedBytes: prometheus.NewDesc(
+			volumeStatsUsedBytesKey,
+			"Number of used bytes in the volume",
+			labels, nil,
+		),
+		inodes: prometheus.NewDesc(
+			volumeStatsInodesKey,
+			"Maximum number of inodes in the volume",
+			labels, nil,
+		),
+		inodesFree: prometheus.NewDesc(
+			volumeStatsInodesFreeKey,
+			"Number of free inodes in the volume",
+			labels, nil,
+		),
+		inodesUsed: prometheus.NewDesc(
+			volumeStatsInodesUsedKey,
+			"Number of used inodes in the volume",
+			labels, nil,
+		),
+		conditionAbnormal: prometheus.NewDesc(
+			volumeConditionAbnormalKey,
+			"Whether the CSI plugin of the volume reports an abnormal condition",
+			labels, nil,
+		),
+		age: prometheus.NewDesc(
+			volumeStatsAgeKey,
+			"Number of seconds since the stats of the volume were taken",
+			labels, nil,
+		),
+	}
+}
+
 var (
-	volumeStatsCapacityBytes = prometheus.NewDesc(
-		volumeStatsCapacityBytesKey,
-		"Capacity in bytes of the volume",
-		[]string{"namespace", "persistentvolumeclaim"}, nil,
-	)
-	volumeStatsAvailableBytes = prometheus.NewDesc(
-		volumeStatsAvailableBytesKey,
-		"Number of available bytes in the volume",
-		[]string{"namespace", "persistentvolumeclaim"}, nil,
-	)
-	volumeStatsUsedBytes = prometheus.NewDesc(
-		volumeStatsUsedBytesKey,
-		"Number of used bytes in the volume",
-		[]string{"namespace", "persistentvolumeclaim"}, nil,
-	)
-	volumeStatsInodes = prometheus.NewDesc(
-		volumeStatsInodesKey,
-		"Maximum number of inodes in the volume",
-		[]string{"namespace", "persistentvolumeclaim"}, nil,
-	)
-	volumeStatsInodesFree = prometheus.NewDesc(
-		volumeStatsInodesFreeKey,
-		"Number of free inodes in the volume",
-		[]string{"namespace", "persistentvolumeclaim"}, nil,
-	)
-	volumeStatsInodesUsed = prometheus.NewDesc(
-		volumeStatsInodesUsedKey,
-		"Number of used inodes in the volume",
-		[]string{"namespace", "persistentvolumeclaim"}, nil,
-	)
+	volumeStatsDescsWithoutSource = newVolumeStatsDescs([]string{"namespace", "persistentvolumeclaim"})
+	// volumeStatsDescsWithSource label where the stats come from. They are
+	// used if the statfs fallback is enabled.
+	volumeStatsDescsWithSource = newVolumeStatsDescs([]string{"namespace", "persistentvolumeclaim", "source"})
+)
+
+// The sources of volume stats.
+const (
+	volumeStatsSourceKubelet = "kubelet"
+	volumeStatsSourceCSI     = "csi"
+	volumeStatsSourceStatfs  = "statfs"
 )
 
+// VolumeStatsRankKeys are the values the volume stats collector can rank
+// PVCs by when it exceeds its budget.
+var VolumeStatsRankKeys = []string{"used_bytes", "capacity_bytes", "available_bytes", "inodes_used"}
+
 // volumeStatsCollector collects metrics from kubelet stats summary.
 type volumeStatsCollector struct {
-	host string
+	provider kubelet.SummaryProvider
+	filter   *Filter
+	budget   *Budget
+	// csi, if not nil, overrides the stats of CSI volumes.
+	csi *CSIStatsSource
+	// statfs, if not nil, fills in the stats of PVCs the kubelet has none of.
+	statfs *StatfsFallback
+	// timestamps, if not nil, attaches the times the kubelet took the stats
+	// to the metrics.
+	timestamps *SampleTimestamps
+	descs      *volumeStatsDescs
 }
 
 // NewVolumeStatsCollector creates a new volume stats prometheus collector.
-func NewVolumeStatsCollector(host string) prometheus.Collector {
-	return &volumeStatsCollector{host: host}
+// If csi is not nil, the stats of CSI volumes are collected from their node
+// plugins. If statfs is not nil, PVCs without stats are statfs'ed and all
+// metrics are labeled with the source of the stats.
+func NewVolumeStatsCollector(provider kubelet.SummaryProvider, filter *Filter, budget *Budget, csi *CSIStatsSource, statfs *StatfsFallback, timestamps *SampleTimestamps) prometheus.Collector {
+	collector := &volumeStatsCollector{provider: provider, filter: filter, budget: budget, csi: csi, statfs: statfs, timestamps: timestamps, descs: volumeStatsDescsWithoutSource}
+	if statfs != nil {
+		collector.descs = volumeStatsDescsWithSource
+	}
+	return collector
 }
 
 // Describe implements the prometheus.Collector interface.
 func (collector *volumeStatsCollector) Describe(ch chan<- *prometheus.Desc) {
-	ch <- volumeStatsCapacityBytes
-	ch <- volumeStatsAvailableBytes
-	ch <- volumeStatsUsedBytes
-	ch <- volumeStatsInodes
-	ch <- volumeStatsInodesFree
-	ch <- volumeStatsInodesUsed
+	ch <- collector.descs.capacityBytes
+	ch <- collector.descs.availableBytes
+	ch <- collector.descs.usedBytes
+	ch <- collector.descs.inodes
+	ch <- collector.descs.inodesFree
+	ch <- collector.descs.inodesUsed
+	ch <- collector.descs.conditionAbnormal
+	ch <- collector.descs.age
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -81,51 +134,251 @@ func (collector *volumeStatsCollector) Collect(ch chan<- prometheus.Metric) {
 	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
 	defer cancel()
 
-	resp, err := ctxhttp.Get(ctx, http.DefaultClient, collector.host)
+	statsSummary, err := collector.provider.GetSummary(ctx)
 	if err != nil {
-		glog.Errorf("failed to get stats from %s: %v", collector.host, err)
+		glog.Errorf("failed to get stats summary: %v", err)
 		return
 	}
-	defer resp.Body.Close()
-	rBody, _ := ioutil.ReadAll(resp.Body)
 
-	statsSummary := v1alpha1.Summary{}
-	err = json.Unmarshal(rBody, &statsSummary)
-	if err != nil {
-		glog.Errorf("failed to parse stats summary from %s: %v", collector.host, err)
-		return
+	now := time.Now()
+	families := 0
+	for _, key := range []string{volumeStatsCapacityBytesKey, volumeStatsAvailableBytesKey, volumeStatsUsedBytesKey, volumeStatsInodesKey, volumeStatsInodesFreeKey, volumeStatsInodesUsedKey, volumeStatsAgeKey} {
+		if collector.filter.Family(key) {
+			families++
+		}
 	}
 
-	addGauge := func(desc *prometheus.Desc, pvcRef *v1alpha1.PVCReference, v float64, lv ...string) {
-		lv = append([]string{pvcRef.Namespace, pvcRef.Name}, lv...)
-		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...)
+	d := collector.descs
+	addGauge := func(key string, desc *prometheus.Desc, pvcRef *v1alpha1.PVCReference, source string, t time.Time, v float64) {
+		if !collector.filter.Family(key) {
+			return
+		}
+		lv := []string{pvcRef.Namespace, pvcRef.Name}
+		if collector.statfs != nil {
+			lv = append(lv, source)
+		}
+		if m := collector.timestamps.attach(prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, v, lv...), t, now); m != nil {
+			ch <- m
+		}
+	}
+	addUsage := func(pvcRef *v1alpha1.PVCReference, usage volumeUsage) {
+		addGauge(volumeStatsCapacityBytesKey, d.capacityBytes, pvcRef, usage.source, usage.time, usage.capacityBytes)
+		addGauge(volumeStatsAvailableBytesKey, d.availableBytes, pvcRef, usage.source, usage.time, usage.availableBytes)
+		addGauge(volumeStatsUsedBytesKey, d.usedBytes, pvcRef, usage.source, usage.time, usage.usedBytes)
+		addGauge(volumeStatsInodesKey, d.inodes, pvcRef, usage.source, usage.time, usage.inodes)
+		addGauge(volumeStatsInodesFreeKey, d.inodesFree, pvcRef, usage.source, usage.time, usage.inodesFree)
+		addGauge(volumeStatsInodesUsedKey, d.inodesUsed, pvcRef, usage.source, usage.time, usage.inodesUsed)
+	}
+
+	var (
+		pvcRefs  []*v1alpha1.PVCReference
+		usages   []volumeUsage
+		ranks    []float64
+		csiStats map[v1alpha1.PVCReference]*csiVolumeStats
+	)
+	if collector.csi != nil {
+		csiStats = collector.csi.volumeStats(ctx, statsSummary, collector.filter)
+	}
+	appendUsage := func(pvcRef *v1alpha1.PVCReference, usage volumeUsage) {
+		pvcRefs = append(pvcRefs, pvcRef)
+		usages = append(usages, usage)
+		ranks = append(ranks, usage.rank(collector.budget))
+	}
+	volumeStats, _ := pvcVolumeStats(statsSummary)
+	collected := map[v1alpha1.PVCReference]bool{}
+	for _, volumeStat := range volumeStats {
+		pvcRef := volumeStat.PVCRef
+		if !collector.filter.Namespace(pvcRef.Namespace) {
+			continue
+		}
+		usage := newVolumeUsage(&volumeStat.FsStats)
+		if stats, ok := csiStats[*pvcRef]; ok {
+			usage, _ = stats.merge(&usage)
+			usage.source = volumeStatsSourceCSI
+		}
+		collected[*pvcRef] = true
+		appendUsage(pvcRef, usage)
+	}
+	// The kubelet may have no stats of CSI volumes yet.
+	for pvcRef, stats := range csiStats {
+		if collected[pvcRef] {
+			continue
+		}
+		if usage, ok := stats.merge(nil); ok {
+			pvcRef := pvcRef
+			usage.source = volumeStatsSourceCSI
+			collected[pvcRef] = true
+			appendUsage(&pvcRef, usage)
+		}
+	}
+	if collector.statfs != nil {
+		for pvcRef, usage := range collector.statfs.volumeStats(ctx, statsSummary, collector.filter, collected) {
+			pvcRef := pvcRef
+			appendUsage(&pvcRef, usage)
+		}
 	}
 
-	if statsSummary.Pods != nil {
-		allPVCs := sets.String{}
-		for _, podStats := range statsSummary.Pods {
-			if podStats.VolumeStats == nil {
+	namespaces := make([]string, len(pvcRefs))
+	for i, pvcRef := range pvcRefs {
+		namespaces[i] = pvcRef.Namespace
+	}
+	keep, others := collector.budget.split(ranks, namespaces, families)
+	for _, i := range keep {
+		addUsage(pvcRefs[i], usages[i])
+		var age time.Duration
+		if !usages[i].time.IsZero() {
+			age = now.Sub(usages[i].time)
+		}
+		addGauge(volumeStatsAgeKey, d.age, pvcRefs[i], usages[i].source, time.Time{}, age.Seconds())
+		if stats, ok := csiStats[*pvcRefs[i]]; ok && stats.condition != nil {
+			addGauge(volumeConditionAbnormalKey, d.conditionAbnormal, pvcRefs[i], usages[i].source, time.Time{}, boolFloat64(stats.condition.Abnormal))
+		}
+	}
+	for _, namespace := range otherNamespaces(others) {
+		other := volumeUsage{}
+		for _, i := range others[namespace] {
+			other.add(usages[i])
+		}
+		addUsage(&v1alpha1.PVCReference{Namespace: namespace, Name: otherBucket}, other)
+		recordDropped("volume", len(others[namespace])*families)
+	}
+}
+
+// volumeUsage is the usage of a volume, or the sum of the usage of several
+// volumes.
+type volumeUsage struct {
+	// source is where the usage comes from, empty for sums.
+	source string
+	// time is when the usage was taken, zero if it was taken while
+	// collecting, and for sums.
+	time           time.Time
+	capacityBytes  float64
+	availableBytes float64
+	usedBytes      float64
+	inodes         float64
+	inodesFree     float64
+	inodesUsed     float64
+}
+
+func newVolumeUsage(fsStats *v1alpha1.FsStats) volumeUsage {
+	return volumeUsage{
+		source:         volumeStatsSourceKubelet,
+		time:           fsStats.Time.Time,
+		capacityBytes:  float64(*fsStats.CapacityBytes),
+		availableBytes: float64(*fsStats.AvailableBytes),
+		usedBytes:      float64(*fsStats.UsedBytes),
+		inodes:         float64(*fsStats.Inodes),
+		inodesFree:     float64(*fsStats.InodesFree),
+		inodesUsed:     float64(*fsStats.InodesUsed),
+	}
+}
+
+func (u *volumeUsage) add(o volumeUsage) {
+	u.capacityBytes += o.capacityBytes
+	u.availableBytes += o.availableBytes
+	u.usedBytes += o.usedBytes
+	u.inodes += o.inodes
+	u.inodesFree += o.inodesFree
+	u.inodesUsed += o.inodesUsed
+}
+
+// rank returns the value the volume is ranked by in budget.
+func (u *volumeUsage) rank(budget *Budget) float64 {
+	if budget == nil {
+		return 0
+	}
+	switch budget.RankBy {
+	case "capacity_bytes":
+		return u.capacityBytes
+	case "available_bytes":
+		return u.availableBytes
+	case "inodes_used":
+		return u.inodesUsed
+	default:
+		return u.usedBytes
+	}
+}
+
+// VolumeDecision explains why a volume of a pod was collected or skipped by
+// the volume stats collector.
+type VolumeDecision struct {
+	Pod       v1alpha1.PodReference  `json:"pod"`
+	Volume    string                 `json:"volume"`
+	PVCRef    *v1alpha1.PVCReference `json:"pvcRef,omitempty"`
+	Collected bool                   `json:"collected"`
+	Reason    string                 `json:"reason,omitempty"`
+}
+
+// ExplainVolumeStats returns the decision of the volume stats collector for
+// every volume in the summary.
+func ExplainVolumeStats(statsSummary *v1alpha1.Summary) []VolumeDecision {
+	_, decisions := pvcVolumeStats(statsSummary)
+	return decisions
+}
+
+// pvcVolumeStats returns the stats of the volumes to collect, one per PVC.
+// The same PVC may be mounted by several pods, only the first is collected.
+func pvcVolumeStats(statsSummary *v1alpha1.Summary) ([]v1alpha1.VolumeStats, []VolumeDecision) {
+	var (
+		volumeStats []v1alpha1.VolumeStats
+		decisions   []VolumeDecision
+	)
+	collectedBy := map[string]v1alpha1.PodReference{}
+	for _, podStats := range statsSummary.Pods {
+		for _, volumeStat := range podStats.VolumeStats {
+			decision := VolumeDecision{
+				Pod:    podStats.PodRef,
+				Volume: volumeStat.Name,
+				PVCRef: volumeStat.PVCRef,
+			}
+			pvcRef := volumeStat.PVCRef
+			if pvcRef == nil {
+				// ignore if no PVC reference
+				decision.Reason = "no PVC reference"
+				decisions = append(decisions, decision)
+				continue
+			}
+			pvcUniqStr := pvcRef.Namespace + "/" + pvcRef.Name
+			if podRef, ok := collectedBy[pvcUniqStr]; ok {
+				// ignore if already collected
+				decision.Reason = "duplicate, already collected from pod " + podRef.Namespace + "/" + podRef.Name
+				decisions = append(decisions, decision)
 				continue
 			}
-			for _, volumeStat := range podStats.VolumeStats {
-				pvcRef := volumeStat.PVCRef
-				if pvcRef == nil {
-					// ignore if no PVC reference
-					continue
-				}
-				pvcUniqStr := pvcRef.Namespace + "/" + pvcRef.Name
-				if allPVCs.Has(pvcUniqStr) {
-					// ignore if already collected
-					continue
-				}
-				addGauge(volumeStatsCapacityBytes, pvcRef, float64(*volumeStat.CapacityBytes))
-				addGauge(volumeStatsAvailableBytes, pvcRef, float64(*volumeStat.AvailableBytes))
-				addGauge(volumeStatsUsedBytes, pvcRef, float64(*volumeStat.UsedBytes))
-				addGauge(volumeStatsInodes, pvcRef, float64(*volumeStat.Inodes))
-				addGauge(volumeStatsInodesFree, pvcRef, float64(*volumeStat.InodesFree))
-				addGauge(volumeStatsInodesUsed, pvcRef, float64(*volumeStat.InodesUsed))
-				allPVCs.Insert(pvcUniqStr)
+			if missing := missingFsStats(&volumeStat.FsStats); len(missing) > 0 {
+				decision.Reason = "missing fields: " + strings.Join(missing, ", ")
+				decisions = append(decisions, decision)
+				continue
 			}
+			decision.Collected = true
+			decisions = append(decisions, decision)
+			volumeStats = append(volumeStats, volumeStat)
+			collectedBy[pvcUniqStr] = podStats.PodRef
 		}
 	}
+	return volumeStats, decisions
+}
+
+// missingFsStats returns the names of the fields missing in fsStats.
+func missingFsStats(fsStats *v1alpha1.FsStats) []string {
+	var missing []string
+	if fsStats.CapacityBytes == nil {
+		missing = append(missing, "capacityBytes")
+	}
+	if fsStats.AvailableBytes == nil {
+		missing = append(missing, "availableBytes")
+	}
+	if fsStats.UsedBytes == nil {
+		missing = append(missing, "usedBytes")
+	}
+	if fsStats.Inodes == nil {
+		missing = append(missing, "inodes")
+	}
+	if fsStats.InodesFree == nil {
+		missing = append(missing, "inodesFree")
+	}
+	if fsStats.InodesUsed == nil {
+		missing = append(missing, "inodesUsed")
+	}
+	return missing
 }
@@ -0,0 +1,53 @@
+package collectors
+
+import (
+	"testing"
+	"time"
+
+	collectorstesting "github.com/cofyc/kubelet-exporter/pkg/collectors/testing"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+func TestVolumeStatsCollectorBudget(t *testing.T) {
+	now := time.Now()
+	summary := &v1alpha1.Summary{}
+	for _, v := range []struct {
+		namespace, pvc string
+		used           uint64
+	}{
+		// A real PVC named like the bucket is kept apart from it.
+		{"team-a", "other", 600},
+		{"team-a", "data", 500},
+		{"team-a", "logs", 100},
+		{"team-b", "cache", 50},
+	} {
+		summary.Pods = append(summary.Pods, v1alpha1.PodStats{
+			PodRef: v1alpha1.PodReference{Name: "pod-" + v.pvc, Namespace: v.namespace},
+			VolumeStats: []v1alpha1.VolumeStats{{
+				Name:    "volume",
+				PVCRef:  &v1alpha1.PVCReference{Name: v.pvc, Namespace: v.namespace},
+				FsStats: newFsStats(now, 1000, 1000-v.used, v.used, 100, 90, 10),
+			}},
+		})
+	}
+	// Seven series per volume, three volumes fit: the top one and the
+	// buckets of both namespaces.
+	budget := &Budget{MaxSeries: 21, RankBy: "used_bytes"}
+	collector := NewVolumeStatsCollector(&fakeProvider{summary: summary}, nil, budget, nil, nil, nil)
+
+	expected := `
+		# HELP kubelet_volume_stats_capacity_bytes Capacity in bytes of the volume
+		# TYPE kubelet_volume_stats_capacity_bytes gauge
+		kubelet_volume_stats_capacity_bytes{namespace="team-a",persistentvolumeclaim="__other__"} 2000
+		kubelet_volume_stats_capacity_bytes{namespace="team-a",persistentvolumeclaim="other"} 1000
+		kubelet_volume_stats_capacity_bytes{namespace="team-b",persistentvolumeclaim="__other__"} 1000
+		# HELP kubelet_volume_stats_used_bytes Number of used bytes in the volume
+		# TYPE kubelet_volume_stats_used_bytes gauge
+		kubelet_volume_stats_used_bytes{namespace="team-a",persistentvolumeclaim="__other__"} 600
+		kubelet_volume_stats_used_bytes{namespace="team-a",persistentvolumeclaim="other"} 600
+		kubelet_volume_stats_used_bytes{namespace="team-b",persistentvolumeclaim="__other__"} 50
+	`
+	if err := collectorstesting.GatherAndCompare(collector, expected, []string{"kubelet_volume_stats_capacity_bytes", "kubelet_volume_stats_used_bytes"}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,47 @@
+// Package cri gets the stats of pods and containers from the container
+// runtime over the CRI, e.g. from containerd or CRI-O, bypassing the kubelet.
+package cri
+
+import (
+	"context"
+
+	"github.com/cofyc/kubelet-exporter/pkg/rpc"
+)
+
+// DefaultSocket is the CRI socket of containerd.
+const DefaultSocket = "/run/containerd/containerd.sock"
+
+// The methods of the CRI runtime service the exporter calls.
+const (
+	ListContainerStatsMethod  = "/runtime.v1.RuntimeService/ListContainerStats"
+	ListPodSandboxStatsMethod = "/runtime.v1.RuntimeService/ListPodSandboxStats"
+)
+
+// Client calls the runtime service of a container runtime.
+type Client struct {
+	*rpc.Client
+}
+
+// NewClient creates a client of the container runtime listening on socket.
+func NewClient(socket string) *Client {
+	return &Client{rpc.NewClient(socket)}
+}
+
+// ListContainerStats returns the stats of all containers.
+func (c *Client) ListContainerStats(ctx context.Context) ([]*ContainerStats, error) {
+	resp := &ListContainerStatsResponse{}
+	if err := c.Invoke(ctx, ListContainerStatsMethod, &ListContainerStatsRequest{Filter: &ContainerStatsFilter{}}, resp); err != nil {
+		return nil, err
+	}
+	return resp.Stats, nil
+}
+
+// ListPodSandboxStats returns the stats of all pod sandboxes. Runtimes
+// implementing CRI before Kubernetes 1.23 return an rpc.Unimplemented error.
+func (c *Client) ListPodSandboxStats(ctx context.Context) ([]*PodSandboxStats, error) {
+	resp := &ListPodSandboxStatsResponse{}
+	if err := c.Invoke(ctx, ListPodSandboxStatsMethod, &ListPodSandboxStatsRequest{Filter: &PodSandboxStatsFilter{}}, resp); err != nil {
+		return nil, err
+	}
+	return resp.Stats, nil
+}
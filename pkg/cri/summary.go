@@ -0,0 +1,164 @@
+package cri
+
+import (
+	"context"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/rpc"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+// The labels the kubelet sets on the containers it creates.
+const (
+	podNameLabel       = "io.kubernetes.pod.name"
+	podNamespaceLabel  = "io.kubernetes.pod.namespace"
+	podUIDLabel        = "io.kubernetes.pod.uid"
+	containerNameLabel = "io.kubernetes.container.name"
+)
+
+// SummaryProvider builds the pod and container stats of a stats summary from
+// the CRI. The summary has no node and volume stats.
+type SummaryProvider struct {
+	client *Client
+}
+
+// NewSummaryProvider creates a summary provider calling client.
+func NewSummaryProvider(client *Client) *SummaryProvider {
+	return &SummaryProvider{client: client}
+}
+
+// GetSummary implements the kubelet.SummaryProvider interface.
+func (p *SummaryProvider) GetSummary(ctx context.Context) (*v1alpha1.Summary, error) {
+	containers, err := p.client.ListContainerStats(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sandboxes, err := p.client.ListPodSandboxStats(ctx)
+	if err != nil && rpc.ErrorCode(err) != rpc.Unimplemented {
+		return nil, err
+	}
+
+	summary := &v1alpha1.Summary{}
+	pods := map[string]int{}
+	podIndex := func(ref v1alpha1.PodReference) int {
+		i, ok := pods[ref.UID]
+		if !ok {
+			i = len(summary.Pods)
+			pods[ref.UID] = i
+			summary.Pods = append(summary.Pods, v1alpha1.PodStats{PodRef: ref})
+		}
+		return i
+	}
+	for _, sandbox := range sandboxes {
+		if sandbox.Attributes == nil || sandbox.Attributes.Metadata == nil {
+			continue
+		}
+		md := sandbox.Attributes.Metadata
+		podStats := &summary.Pods[podIndex(v1alpha1.PodReference{Name: md.Name, Namespace: md.Namespace, UID: md.Uid})]
+		if sandbox.Linux != nil {
+			podStats.CPU = cpuStats(sandbox.Linux.Cpu)
+			podStats.Memory = memoryStats(sandbox.Linux.Memory)
+		}
+	}
+
+	// Exited containers may be listed next to their restarted successors.
+	attempts := map[string]uint32{}
+	for _, c := range containers {
+		if c.Attributes == nil {
+			continue
+		}
+		labels := c.Attributes.Labels
+		ref := v1alpha1.PodReference{Name: labels[podNameLabel], Namespace: labels[podNamespaceLabel], UID: labels[podUIDLabel]}
+		name := labels[containerNameLabel]
+		var attempt uint32
+		if md := c.Attributes.Metadata; md != nil {
+			if name == "" {
+				name = md.Name
+			}
+			attempt = md.Attempt
+		}
+		if ref.UID == "" || name == "" {
+			// not created by the kubelet
+			continue
+		}
+		podStats := &summary.Pods[podIndex(ref)]
+		containerStats := v1alpha1.ContainerStats{
+			Name:   name,
+			CPU:    cpuStats(c.Cpu),
+			Memory: memoryStats(c.Memory),
+			Rootfs: fsStats(c.WritableLayer),
+		}
+		key := ref.UID + "/" + name
+		replaced := false
+		for i := range podStats.Containers {
+			if podStats.Containers[i].Name == name {
+				if attempt >= attempts[key] {
+					podStats.Containers[i] = containerStats
+					attempts[key] = attempt
+				}
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			podStats.Containers = append(podStats.Containers, containerStats)
+			attempts[key] = attempt
+		}
+	}
+	return summary, nil
+}
+
+// statsTime converts a CRI timestamp in nanoseconds since the epoch. Runtimes
+// not setting it report 0, which is the zero time rather than the epoch.
+func statsTime(timestamp int64) metav1.Time {
+	if timestamp == 0 {
+		return metav1.Time{}
+	}
+	return metav1.NewTime(time.Unix(0, timestamp))
+}
+
+func uint64Value(v *UInt64Value) *uint64 {
+	if v == nil {
+		return nil
+	}
+	value := v.Value
+	return &value
+}
+
+func cpuStats(usage *CpuUsage) *v1alpha1.CPUStats {
+	if usage == nil {
+		return nil
+	}
+	return &v1alpha1.CPUStats{
+		Time:                 statsTime(usage.Timestamp),
+		UsageNanoCores:       uint64Value(usage.UsageNanoCores),
+		UsageCoreNanoSeconds: uint64Value(usage.UsageCoreNanoSeconds),
+	}
+}
+
+func memoryStats(usage *MemoryUsage) *v1alpha1.MemoryStats {
+	if usage == nil {
+		return nil
+	}
+	return &v1alpha1.MemoryStats{
+		Time:            statsTime(usage.Timestamp),
+		AvailableBytes:  uint64Value(usage.AvailableBytes),
+		UsageBytes:      uint64Value(usage.UsageBytes),
+		WorkingSetBytes: uint64Value(usage.WorkingSetBytes),
+		RSSBytes:        uint64Value(usage.RssBytes),
+		PageFaults:      uint64Value(usage.PageFaults),
+		MajorPageFaults: uint64Value(usage.MajorPageFaults),
+	}
+}
+
+func fsStats(usage *FilesystemUsage) *v1alpha1.FsStats {
+	if usage == nil {
+		return nil
+	}
+	return &v1alpha1.FsStats{
+		Time:       statsTime(usage.Timestamp),
+		UsedBytes:  uint64Value(usage.UsedBytes),
+		InodesUsed: uint64Value(usage.InodesUsed),
+	}
+}
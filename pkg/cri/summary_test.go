@@ -0,0 +1,19 @@
+package cri
+
+import (
+	"testing"
+	"time"
+)
+
+func TestStatsTime(t *testing.T) {
+	if got := statsTime(0); !got.IsZero() {
+		t.Errorf("got time %v of a zero timestamp, want the zero time", got)
+	}
+	now := time.Unix(1500000000, 123)
+	if got := statsTime(now.UnixNano()); !got.Time.Equal(now) {
+		t.Errorf("got time %v, want %v", got, now)
+	}
+	if got := cpuStats(&CpuUsage{}); !got.Time.IsZero() {
+		t.Errorf("got CPU stats time %v without timestamp, want the zero time", got.Time)
+	}
+}
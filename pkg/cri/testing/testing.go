@@ -0,0 +1,41 @@
+// Package testing provides a fake CRI runtime service.
+package testing
+
+import (
+	"context"
+	"net"
+
+	"github.com/cofyc/kubelet-exporter/pkg/cri"
+	"github.com/cofyc/kubelet-exporter/pkg/rpc"
+	"github.com/golang/protobuf/proto"
+)
+
+// FakeRuntime is a container runtime serving stats from memory.
+type FakeRuntime struct {
+	Containers []*cri.ContainerStats
+	// Sandboxes are the stats of pod sandboxes, ListPodSandboxStats is
+	// unimplemented if nil.
+	Sandboxes []*cri.PodSandboxStats
+}
+
+// Serve serves the runtime on l until it fails.
+func (r *FakeRuntime) Serve(l net.Listener) error {
+	methods := map[string]rpc.Handler{
+		cri.ListContainerStatsMethod: func(ctx context.Context, unmarshal func(proto.Message) error) (proto.Message, error) {
+			if err := unmarshal(&cri.ListContainerStatsRequest{}); err != nil {
+				return nil, err
+			}
+			return &cri.ListContainerStatsResponse{Stats: r.Containers}, nil
+		},
+	}
+	if r.Sandboxes != nil {
+		methods[cri.ListPodSandboxStatsMethod] = func(ctx context.Context, unmarshal func(proto.Message) error) (proto.Message, error) {
+			if err := unmarshal(&cri.ListPodSandboxStatsRequest{}); err != nil {
+				return nil, err
+			}
+			return &cri.ListPodSandboxStatsResponse{Stats: r.Sandboxes}, nil
+		}
+	}
+	server := &rpc.Server{Methods: methods}
+	return server.Serve(l)
+}
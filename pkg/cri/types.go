@@ -0,0 +1,200 @@
+package cri
+
+import (
+	"github.com/golang/protobuf/proto"
+)
+
+// The messages of the CRI runtime.v1 API the exporter uses, with the field
+// numbers of api.proto. Unknown fields are skipped when decoding.
+
+// UInt64Value wraps an optional uint64.
+type UInt64Value struct {
+	Value uint64 `protobuf:"varint,1,opt,name=value,proto3"`
+}
+
+func (m *UInt64Value) Reset()         { *m = UInt64Value{} }
+func (m *UInt64Value) String() string { return proto.CompactTextString(m) }
+func (*UInt64Value) ProtoMessage()    {}
+
+// ContainerStatsFilter filters the containers to list the stats of.
+type ContainerStatsFilter struct {
+	Id            string            `protobuf:"bytes,1,opt,name=id,proto3"`
+	PodSandboxId  string            `protobuf:"bytes,2,opt,name=pod_sandbox_id,json=podSandboxId,proto3"`
+	LabelSelector map[string]string `protobuf:"bytes,3,rep,name=label_selector,json=labelSelector" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *ContainerStatsFilter) Reset()         { *m = ContainerStatsFilter{} }
+func (m *ContainerStatsFilter) String() string { return proto.CompactTextString(m) }
+func (*ContainerStatsFilter) ProtoMessage()    {}
+
+// ListContainerStatsRequest is the request of RuntimeService.ListContainerStats.
+type ListContainerStatsRequest struct {
+	Filter *ContainerStatsFilter `protobuf:"bytes,1,opt,name=filter"`
+}
+
+func (m *ListContainerStatsRequest) Reset()         { *m = ListContainerStatsRequest{} }
+func (m *ListContainerStatsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListContainerStatsRequest) ProtoMessage()    {}
+
+// ListContainerStatsResponse is the response of RuntimeService.ListContainerStats.
+type ListContainerStatsResponse struct {
+	Stats []*ContainerStats `protobuf:"bytes,1,rep,name=stats"`
+}
+
+func (m *ListContainerStatsResponse) Reset()         { *m = ListContainerStatsResponse{} }
+func (m *ListContainerStatsResponse) String() string { return proto.CompactTextString(m) }
+func (*ListContainerStatsResponse) ProtoMessage()    {}
+
+// ContainerMetadata identifies a container in its pod.
+type ContainerMetadata struct {
+	Name    string `protobuf:"bytes,1,opt,name=name,proto3"`
+	Attempt uint32 `protobuf:"varint,2,opt,name=attempt,proto3"`
+}
+
+func (m *ContainerMetadata) Reset()         { *m = ContainerMetadata{} }
+func (m *ContainerMetadata) String() string { return proto.CompactTextString(m) }
+func (*ContainerMetadata) ProtoMessage()    {}
+
+// ContainerAttributes are the attributes of a container.
+type ContainerAttributes struct {
+	Id          string             `protobuf:"bytes,1,opt,name=id,proto3"`
+	Metadata    *ContainerMetadata `protobuf:"bytes,2,opt,name=metadata"`
+	Labels      map[string]string  `protobuf:"bytes,3,rep,name=labels" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Annotations map[string]string  `protobuf:"bytes,4,rep,name=annotations" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *ContainerAttributes) Reset()         { *m = ContainerAttributes{} }
+func (m *ContainerAttributes) String() string { return proto.CompactTextString(m) }
+func (*ContainerAttributes) ProtoMessage()    {}
+
+// ContainerStats are the usage of a container.
+type ContainerStats struct {
+	Attributes    *ContainerAttributes `protobuf:"bytes,1,opt,name=attributes"`
+	Cpu           *CpuUsage            `protobuf:"bytes,2,opt,name=cpu"`
+	Memory        *MemoryUsage         `protobuf:"bytes,3,opt,name=memory"`
+	WritableLayer *FilesystemUsage     `protobuf:"bytes,4,opt,name=writable_layer,json=writableLayer"`
+}
+
+func (m *ContainerStats) Reset()         { *m = ContainerStats{} }
+func (m *ContainerStats) String() string { return proto.CompactTextString(m) }
+func (*ContainerStats) ProtoMessage()    {}
+
+// CpuUsage is the CPU usage, the timestamp in nanoseconds since the epoch.
+type CpuUsage struct {
+	Timestamp            int64        `protobuf:"varint,1,opt,name=timestamp,proto3"`
+	UsageCoreNanoSeconds *UInt64Value `protobuf:"bytes,2,opt,name=usage_core_nano_seconds,json=usageCoreNanoSeconds"`
+	UsageNanoCores       *UInt64Value `protobuf:"bytes,3,opt,name=usage_nano_cores,json=usageNanoCores"`
+}
+
+func (m *CpuUsage) Reset()         { *m = CpuUsage{} }
+func (m *CpuUsage) String() string { return proto.CompactTextString(m) }
+func (*CpuUsage) ProtoMessage()    {}
+
+// MemoryUsage is the memory usage, the timestamp in nanoseconds since the epoch.
+type MemoryUsage struct {
+	Timestamp       int64        `protobuf:"varint,1,opt,name=timestamp,proto3"`
+	WorkingSetBytes *UInt64Value `protobuf:"bytes,2,opt,name=working_set_bytes,json=workingSetBytes"`
+	AvailableBytes  *UInt64Value `protobuf:"bytes,3,opt,name=available_bytes,json=availableBytes"`
+	UsageBytes      *UInt64Value `protobuf:"bytes,4,opt,name=usage_bytes,json=usageBytes"`
+	RssBytes        *UInt64Value `protobuf:"bytes,5,opt,name=rss_bytes,json=rssBytes"`
+	PageFaults      *UInt64Value `protobuf:"bytes,6,opt,name=page_faults,json=pageFaults"`
+	MajorPageFaults *UInt64Value `protobuf:"bytes,7,opt,name=major_page_faults,json=majorPageFaults"`
+}
+
+func (m *MemoryUsage) Reset()         { *m = MemoryUsage{} }
+func (m *MemoryUsage) String() string { return proto.CompactTextString(m) }
+func (*MemoryUsage) ProtoMessage()    {}
+
+// FilesystemIdentifier identifies a filesystem.
+type FilesystemIdentifier struct {
+	Mountpoint string `protobuf:"bytes,1,opt,name=mountpoint,proto3"`
+}
+
+func (m *FilesystemIdentifier) Reset()         { *m = FilesystemIdentifier{} }
+func (m *FilesystemIdentifier) String() string { return proto.CompactTextString(m) }
+func (*FilesystemIdentifier) ProtoMessage()    {}
+
+// FilesystemUsage is the usage of a filesystem, the timestamp in nanoseconds since the epoch.
+type FilesystemUsage struct {
+	Timestamp  int64                 `protobuf:"varint,1,opt,name=timestamp,proto3"`
+	FsId       *FilesystemIdentifier `protobuf:"bytes,2,opt,name=fs_id,json=fsId"`
+	UsedBytes  *UInt64Value          `protobuf:"bytes,3,opt,name=used_bytes,json=usedBytes"`
+	InodesUsed *UInt64Value          `protobuf:"bytes,4,opt,name=inodes_used,json=inodesUsed"`
+}
+
+func (m *FilesystemUsage) Reset()         { *m = FilesystemUsage{} }
+func (m *FilesystemUsage) String() string { return proto.CompactTextString(m) }
+func (*FilesystemUsage) ProtoMessage()    {}
+
+// PodSandboxStatsFilter filters the pod sandboxes to list the stats of.
+type PodSandboxStatsFilter struct {
+	Id            string            `protobuf:"bytes,1,opt,name=id,proto3"`
+	LabelSelector map[string]string `protobuf:"bytes,2,rep,name=label_selector,json=labelSelector" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *PodSandboxStatsFilter) Reset()         { *m = PodSandboxStatsFilter{} }
+func (m *PodSandboxStatsFilter) String() string { return proto.CompactTextString(m) }
+func (*PodSandboxStatsFilter) ProtoMessage()    {}
+
+// ListPodSandboxStatsRequest is the request of RuntimeService.ListPodSandboxStats.
+type ListPodSandboxStatsRequest struct {
+	Filter *PodSandboxStatsFilter `protobuf:"bytes,1,opt,name=filter"`
+}
+
+func (m *ListPodSandboxStatsRequest) Reset()         { *m = ListPodSandboxStatsRequest{} }
+func (m *ListPodSandboxStatsRequest) String() string { return proto.CompactTextString(m) }
+func (*ListPodSandboxStatsRequest) ProtoMessage()    {}
+
+// ListPodSandboxStatsResponse is the response of RuntimeService.ListPodSandboxStats.
+type ListPodSandboxStatsResponse struct {
+	Stats []*PodSandboxStats `protobuf:"bytes,1,rep,name=stats"`
+}
+
+func (m *ListPodSandboxStatsResponse) Reset()         { *m = ListPodSandboxStatsResponse{} }
+func (m *ListPodSandboxStatsResponse) String() string { return proto.CompactTextString(m) }
+func (*ListPodSandboxStatsResponse) ProtoMessage()    {}
+
+// PodSandboxMetadata identifies the pod of a sandbox.
+type PodSandboxMetadata struct {
+	Name      string `protobuf:"bytes,1,opt,name=name,proto3"`
+	Uid       string `protobuf:"bytes,2,opt,name=uid,proto3"`
+	Namespace string `protobuf:"bytes,3,opt,name=namespace,proto3"`
+	Attempt   uint32 `protobuf:"varint,4,opt,name=attempt,proto3"`
+}
+
+func (m *PodSandboxMetadata) Reset()         { *m = PodSandboxMetadata{} }
+func (m *PodSandboxMetadata) String() string { return proto.CompactTextString(m) }
+func (*PodSandboxMetadata) ProtoMessage()    {}
+
+// PodSandboxAttributes are the attributes of a pod sandbox.
+type PodSandboxAttributes struct {
+	Id          string              `protobuf:"bytes,1,opt,name=id,proto3"`
+	Metadata    *PodSandboxMetadata `protobuf:"bytes,2,opt,name=metadata"`
+	Labels      map[string]string   `protobuf:"bytes,3,rep,name=labels" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+	Annotations map[string]string   `protobuf:"bytes,4,rep,name=annotations" protobuf_key:"bytes,1,opt,name=key,proto3" protobuf_val:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *PodSandboxAttributes) Reset()         { *m = PodSandboxAttributes{} }
+func (m *PodSandboxAttributes) String() string { return proto.CompactTextString(m) }
+func (*PodSandboxAttributes) ProtoMessage()    {}
+
+// PodSandboxStats are the usage of a pod sandbox. Windows stats are skipped.
+type PodSandboxStats struct {
+	Attributes *PodSandboxAttributes `protobuf:"bytes,1,opt,name=attributes"`
+	Linux      *LinuxPodSandboxStats `protobuf:"bytes,2,opt,name=linux"`
+}
+
+func (m *PodSandboxStats) Reset()         { *m = PodSandboxStats{} }
+func (m *PodSandboxStats) String() string { return proto.CompactTextString(m) }
+func (*PodSandboxStats) ProtoMessage()    {}
+
+// LinuxPodSandboxStats are the usage of a pod sandbox on Linux. Network and process stats are skipped.
+type LinuxPodSandboxStats struct {
+	Cpu        *CpuUsage         `protobuf:"bytes,1,opt,name=cpu"`
+	Memory     *MemoryUsage      `protobuf:"bytes,2,opt,name=memory"`
+	Containers []*ContainerStats `protobuf:"bytes,5,rep,name=containers"`
+}
+
+func (m *LinuxPodSandboxStats) Reset()         { *m = LinuxPodSandboxStats{} }
+func (m *LinuxPodSandboxStats) String() string { return proto.CompactTextString(m) }
+func (*LinuxPodSandboxStats) ProtoMessage()    {}
@@ -0,0 +1,115 @@
+// Package csi calls CSI node plugins directly, bypassing the stats cached by
+// the kubelet.
+package csi
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+
+	"github.com/cofyc/kubelet-exporter/pkg/rpc"
+)
+
+// The methods of the CSI v1 services the exporter calls.
+const (
+	GetPluginInfoMethod      = "/csi.v1.Identity/GetPluginInfo"
+	NodeGetVolumeStatsMethod = "/csi.v1.Node/NodeGetVolumeStats"
+)
+
+// Client calls a CSI node plugin.
+type Client struct {
+	*rpc.Client
+}
+
+// NewClient creates a client of the CSI plugin listening on socket.
+func NewClient(socket string) *Client {
+	return &Client{rpc.NewClient(socket)}
+}
+
+// GetPluginInfo returns the name and version of the plugin.
+func (c *Client) GetPluginInfo(ctx context.Context) (*GetPluginInfoResponse, error) {
+	resp := &GetPluginInfoResponse{}
+	if err := c.Invoke(ctx, GetPluginInfoMethod, &GetPluginInfoRequest{}, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// NodeGetVolumeStats returns the usage and condition of the volume published
+// at volumePath.
+func (c *Client) NodeGetVolumeStats(ctx context.Context, volumeID, volumePath string) (*NodeGetVolumeStatsResponse, error) {
+	req := &NodeGetVolumeStatsRequest{VolumeId: volumeID, VolumePath: volumePath}
+	resp := &NodeGetVolumeStatsResponse{}
+	if err := c.Invoke(ctx, NodeGetVolumeStatsMethod, req, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// Plugins finds the CSI node plugins by the sockets matching a glob, e.g.
+// /var/lib/kubelet/plugins/*/csi.sock, and maps them to their driver names.
+type Plugins struct {
+	glob string
+
+	mu       sync.Mutex
+	byDriver map[string]*Client
+	// bySocket caches the driver names of sockets.
+	bySocket map[string]string
+}
+
+// NewPlugins creates plugins found by the sockets matching glob.
+func NewPlugins(glob string) *Plugins {
+	return &Plugins{glob: glob, byDriver: map[string]*Client{}, bySocket: map[string]string{}}
+}
+
+// Client returns the client of the plugin of driver, nil if no socket
+// belongs to it. Sockets are globbed again, and sockets not known yet asked
+// for their driver name, until the driver is found.
+func (p *Plugins) Client(ctx context.Context, driver string) (*Client, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if c, ok := p.byDriver[driver]; ok {
+		return c, nil
+	}
+	sockets, err := filepath.Glob(p.glob)
+	if err != nil {
+		return nil, err
+	}
+	for _, socket := range sockets {
+		if _, ok := p.bySocket[socket]; ok {
+			continue
+		}
+		c := NewClient(socket)
+		info, err := c.GetPluginInfo(ctx)
+		if err != nil {
+			// The plugin may not be up yet, try again next time.
+			continue
+		}
+		p.bySocket[socket] = info.Name
+		p.byDriver[info.Name] = c
+	}
+	return p.byDriver[driver], nil
+}
+
+// VolumeData is the vol_data.json the kubelet writes into the directory of
+// each CSI volume of a pod.
+type VolumeData struct {
+	DriverName   string `json:"driverName"`
+	VolumeHandle string `json:"volumeHandle"`
+	SpecVolumeID string `json:"specVolID"`
+}
+
+// ReadVolumeData reads the vol_data.json in the volume directory dir.
+func ReadVolumeData(dir string) (*VolumeData, error) {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "vol_data.json"))
+	if err != nil {
+		return nil, err
+	}
+	volumeData := &VolumeData{}
+	if err := json.Unmarshal(data, volumeData); err != nil {
+		return nil, err
+	}
+	return volumeData, nil
+}
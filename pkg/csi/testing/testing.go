@@ -0,0 +1,43 @@
+// Package testing provides a fake CSI node plugin.
+package testing
+
+import (
+	"context"
+	"net"
+
+	"github.com/cofyc/kubelet-exporter/pkg/csi"
+	"github.com/cofyc/kubelet-exporter/pkg/rpc"
+	"github.com/golang/protobuf/proto"
+)
+
+// FakePlugin is a CSI node plugin serving the stats of volumes from memory.
+type FakePlugin struct {
+	Name string
+	// Volumes are the responses of NodeGetVolumeStats by volume path. Other
+	// volumes are not found.
+	Volumes map[string]*csi.NodeGetVolumeStatsResponse
+}
+
+// Serve serves the plugin on l until it fails.
+func (p *FakePlugin) Serve(l net.Listener) error {
+	server := &rpc.Server{Methods: map[string]rpc.Handler{
+		csi.GetPluginInfoMethod: func(ctx context.Context, unmarshal func(proto.Message) error) (proto.Message, error) {
+			if err := unmarshal(&csi.GetPluginInfoRequest{}); err != nil {
+				return nil, err
+			}
+			return &csi.GetPluginInfoResponse{Name: p.Name, VendorVersion: "fake"}, nil
+		},
+		csi.NodeGetVolumeStatsMethod: func(ctx context.Context, unmarshal func(proto.Message) error) (proto.Message, error) {
+			req := &csi.NodeGetVolumeStatsRequest{}
+			if err := unmarshal(req); err != nil {
+				return nil, err
+			}
+			resp, ok := p.Volumes[req.VolumePath]
+			if !ok {
+				return nil, &rpc.Error{Code: rpc.NotFound, Message: "volume " + req.VolumeId + " not found at " + req.VolumePath}
+			}
+			return resp, nil
+		},
+	}}
+	return server.Serve(l)
+}
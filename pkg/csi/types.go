@@ -0,0 +1,80 @@
+package csi
+
+import (
+	"github.com/golang/protobuf/proto"
+)
+
+// The messages of the CSI v1 spec the exporter uses, with the field numbers
+// of csi.proto. Unknown fields are skipped when decoding.
+
+// GetPluginInfoRequest is the request of Identity.GetPluginInfo.
+type GetPluginInfoRequest struct{}
+
+func (m *GetPluginInfoRequest) Reset()         { *m = GetPluginInfoRequest{} }
+func (m *GetPluginInfoRequest) String() string { return proto.CompactTextString(m) }
+func (*GetPluginInfoRequest) ProtoMessage()    {}
+
+// GetPluginInfoResponse is the response of Identity.GetPluginInfo.
+type GetPluginInfoResponse struct {
+	Name          string `protobuf:"bytes,1,opt,name=name,proto3"`
+	VendorVersion string `protobuf:"bytes,2,opt,name=vendor_version,json=vendorVersion,proto3"`
+}
+
+func (m *GetPluginInfoResponse) Reset()         { *m = GetPluginInfoResponse{} }
+func (m *GetPluginInfoResponse) String() string { return proto.CompactTextString(m) }
+func (*GetPluginInfoResponse) ProtoMessage()    {}
+
+// NodeGetVolumeStatsRequest is the request of Node.NodeGetVolumeStats.
+type NodeGetVolumeStatsRequest struct {
+	VolumeId          string `protobuf:"bytes,1,opt,name=volume_id,json=volumeId,proto3"`
+	VolumePath        string `protobuf:"bytes,2,opt,name=volume_path,json=volumePath,proto3"`
+	StagingTargetPath string `protobuf:"bytes,3,opt,name=staging_target_path,json=stagingTargetPath,proto3"`
+}
+
+func (m *NodeGetVolumeStatsRequest) Reset()         { *m = NodeGetVolumeStatsRequest{} }
+func (m *NodeGetVolumeStatsRequest) String() string { return proto.CompactTextString(m) }
+func (*NodeGetVolumeStatsRequest) ProtoMessage()    {}
+
+// NodeGetVolumeStatsResponse is the response of Node.NodeGetVolumeStats.
+type NodeGetVolumeStatsResponse struct {
+	Usage []*VolumeUsage `protobuf:"bytes,1,rep,name=usage"`
+	// VolumeCondition is only set by plugins with the VOLUME_CONDITION node
+	// capability.
+	VolumeCondition *VolumeCondition `protobuf:"bytes,2,opt,name=volume_condition,json=volumeCondition"`
+}
+
+func (m *NodeGetVolumeStatsResponse) Reset()         { *m = NodeGetVolumeStatsResponse{} }
+func (m *NodeGetVolumeStatsResponse) String() string { return proto.CompactTextString(m) }
+func (*NodeGetVolumeStatsResponse) ProtoMessage()    {}
+
+// VolumeUsageUnit is the unit of a VolumeUsage.
+type VolumeUsageUnit int32
+
+// The units of VolumeUsage.
+const (
+	UnitUnknown VolumeUsageUnit = 0
+	UnitBytes   VolumeUsageUnit = 1
+	UnitInodes  VolumeUsageUnit = 2
+)
+
+// VolumeUsage is the usage of a volume in a unit.
+type VolumeUsage struct {
+	Available int64           `protobuf:"varint,1,opt,name=available,proto3"`
+	Total     int64           `protobuf:"varint,2,opt,name=total,proto3"`
+	Used      int64           `protobuf:"varint,3,opt,name=used,proto3"`
+	Unit      VolumeUsageUnit `protobuf:"varint,4,opt,name=unit,proto3,enum=csi.v1.VolumeUsage_Unit"`
+}
+
+func (m *VolumeUsage) Reset()         { *m = VolumeUsage{} }
+func (m *VolumeUsage) String() string { return proto.CompactTextString(m) }
+func (*VolumeUsage) ProtoMessage()    {}
+
+// VolumeCondition is the health of a volume.
+type VolumeCondition struct {
+	Abnormal bool   `protobuf:"varint,1,opt,name=abnormal,proto3"`
+	Message  string `protobuf:"bytes,2,opt,name=message,proto3"`
+}
+
+func (m *VolumeCondition) Reset()         { *m = VolumeCondition{} }
+func (m *VolumeCondition) String() string { return proto.CompactTextString(m) }
+func (*VolumeCondition) ProtoMessage()    {}
@@ -0,0 +1,242 @@
+package kube
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/net/context/ctxhttp"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+const (
+	serviceAccountTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	serviceAccountCAFile    = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+)
+
+// Namespace is the subset of a core/v1 Namespace the exporter needs.
+type Namespace struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+}
+
+// NamespaceList is the subset of a core/v1 NamespaceList the exporter needs.
+type NamespaceList struct {
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []Namespace `json:"items"`
+}
+
+// PersistentVolumeClaim is the subset of a core/v1 PersistentVolumeClaim the
+// exporter needs.
+type PersistentVolumeClaim struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              PersistentVolumeClaimSpec `json:"spec,omitempty"`
+}
+
+// PersistentVolumeClaimSpec is the subset of a core/v1
+// PersistentVolumeClaimSpec the exporter needs.
+type PersistentVolumeClaimSpec struct {
+	StorageClassName *string `json:"storageClassName,omitempty"`
+	// VolumeName is the name of the bound PV.
+	VolumeName string `json:"volumeName,omitempty"`
+}
+
+// PersistentVolumeClaimList is the subset of a core/v1
+// PersistentVolumeClaimList the exporter needs.
+type PersistentVolumeClaimList struct {
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PersistentVolumeClaim `json:"items"`
+}
+
+// PersistentVolume is the subset of a core/v1 PersistentVolume the exporter
+// needs.
+type PersistentVolume struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+	Spec              PersistentVolumeSpec   `json:"spec,omitempty"`
+	Status            PersistentVolumeStatus `json:"status,omitempty"`
+}
+
+// PersistentVolumeSpec is the subset of a core/v1 PersistentVolumeSpec the
+// exporter needs.
+type PersistentVolumeSpec struct {
+	StorageClassName string `json:"storageClassName,omitempty"`
+	// Local is set for local PVs.
+	Local *LocalVolumeSource `json:"local,omitempty"`
+	// ClaimRef is the PVC the PV is bound to, nil if unbound.
+	ClaimRef     *ObjectReference    `json:"claimRef,omitempty"`
+	NodeAffinity *VolumeNodeAffinity `json:"nodeAffinity,omitempty"`
+}
+
+// VolumeBound is the phase of PVs bound to a PVC. Released PVs keep the
+// ClaimRef of their deleted PVC.
+const VolumeBound = "Bound"
+
+// PersistentVolumeStatus is the subset of a core/v1 PersistentVolumeStatus
+// the exporter needs.
+type PersistentVolumeStatus struct {
+	Phase string `json:"phase,omitempty"`
+}
+
+// LocalVolumeSource is a core/v1 LocalVolumeSource.
+type LocalVolumeSource struct {
+	Path string `json:"path"`
+}
+
+// ObjectReference is the subset of a core/v1 ObjectReference the exporter
+// needs.
+type ObjectReference struct {
+	Namespace string `json:"namespace,omitempty"`
+	Name      string `json:"name,omitempty"`
+}
+
+// VolumeNodeAffinity is a core/v1 VolumeNodeAffinity.
+type VolumeNodeAffinity struct {
+	Required *NodeSelector `json:"required,omitempty"`
+}
+
+// NodeSelector is a core/v1 NodeSelector without field selectors.
+type NodeSelector struct {
+	NodeSelectorTerms []NodeSelectorTerm `json:"nodeSelectorTerms"`
+}
+
+// NodeSelectorTerm is a core/v1 NodeSelectorTerm without field selectors.
+type NodeSelectorTerm struct {
+	MatchExpressions []NodeSelectorRequirement `json:"matchExpressions,omitempty"`
+}
+
+// NodeSelectorRequirement is a core/v1 NodeSelectorRequirement.
+type NodeSelectorRequirement struct {
+	Key      string   `json:"key"`
+	Operator string   `json:"operator"`
+	Values   []string `json:"values,omitempty"`
+}
+
+// PersistentVolumeList is the subset of a core/v1 PersistentVolumeList the
+// exporter needs.
+type PersistentVolumeList struct {
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PersistentVolume `json:"items"`
+}
+
+// Node is the subset of a core/v1 Node the exporter needs.
+type Node struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+}
+
+// Client is a minimal read-only client of the Kubernetes API server.
+type Client struct {
+	host       string
+	token      *TokenFile
+	httpClient *http.Client
+}
+
+// NewInClusterClient creates a client from the service account mounted into
+// the pod, the same way client-go's rest.InClusterConfig does. The token is
+// read again every TokenFileTTL, bound service account tokens rotate.
+func NewInClusterClient() (*Client, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, fmt.Errorf("unable to load in-cluster configuration, KUBERNETES_SERVICE_HOST and KUBERNETES_SERVICE_PORT must be defined")
+	}
+	token := NewTokenFile(serviceAccountTokenFile, TokenFileTTL)
+	if _, err := token.Token(); err != nil {
+		return nil, err
+	}
+	caData, err := ioutil.ReadFile(serviceAccountCAFile)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(caData) {
+		return nil, fmt.Errorf("no certificates found in %s", serviceAccountCAFile)
+	}
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: &tls.Config{RootCAs: pool},
+	}
+	return &Client{
+		host:       "https://" + net.JoinHostPort(host, port),
+		token:      token,
+		httpClient: &http.Client{Transport: transport, Timeout: 30 * time.Second},
+	}, nil
+}
+
+// ListNamespaces lists namespaces matching the given label selector.
+func (c *Client) ListNamespaces(ctx context.Context, labelSelector string) (*NamespaceList, error) {
+	list := &NamespaceList{}
+	query := url.Values{}
+	if labelSelector != "" {
+		query.Set("labelSelector", labelSelector)
+	}
+	if err := c.get(ctx, "/api/v1/namespaces", query, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ListPersistentVolumeClaims lists the PVCs of all namespaces.
+func (c *Client) ListPersistentVolumeClaims(ctx context.Context) (*PersistentVolumeClaimList, error) {
+	list := &PersistentVolumeClaimList{}
+	if err := c.get(ctx, "/api/v1/persistentvolumeclaims", nil, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// ListPersistentVolumes lists all PVs.
+func (c *Client) ListPersistentVolumes(ctx context.Context) (*PersistentVolumeList, error) {
+	list := &PersistentVolumeList{}
+	if err := c.get(ctx, "/api/v1/persistentvolumes", nil, list); err != nil {
+		return nil, err
+	}
+	return list, nil
+}
+
+// GetNode gets the node named name.
+func (c *Client) GetNode(ctx context.Context, name string) (*Node, error) {
+	node := &Node{}
+	if err := c.get(ctx, "/api/v1/nodes/"+url.PathEscape(name), nil, node); err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func (c *Client) get(ctx context.Context, path string, query url.Values, into interface{}) error {
+	u := c.host + path
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	token, err := c.token.Token()
+	if err != nil {
+		return err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := ctxhttp.Do(ctx, c.httpClient, req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s: unexpected status %s: %s", path, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return json.Unmarshal(body, into)
+}
@@ -0,0 +1,51 @@
+package kube
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestClientRotatedToken(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token")
+	writeToken := func(token string) {
+		if err := ioutil.WriteFile(path, []byte(token), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v1/namespaces" || r.URL.Query().Get("labelSelector") != "team=a" {
+			t.Errorf("got request of %s", r.URL)
+		}
+		got = append(got, r.Header.Get("Authorization"))
+		w.Write([]byte(`{"items":[{"metadata":{"name":"team-a"}}]}`))
+	}))
+	defer server.Close()
+
+	client := &Client{host: server.URL, token: NewTokenFile(path, 0), httpClient: server.Client()}
+	for _, token := range []string{"first", "second"} {
+		writeToken(token)
+		list, err := client.ListNamespaces(context.Background(), "team=a")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list.Items) != 1 || list.Items[0].Name != "team-a" {
+			t.Errorf("got namespaces %+v, want team-a", list.Items)
+		}
+	}
+	if want := []string{"Bearer first", "Bearer second"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("got authorizations %v, want %v", got, want)
+	}
+}
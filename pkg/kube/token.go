@@ -0,0 +1,53 @@
+package kube
+
+import (
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+)
+
+// TokenFileTTL is how long a token read from a file is used before the file
+// is read again, as by client-go.
+const TokenFileTTL = time.Minute
+
+// TokenFile reads a bearer token from a file and caches it for a while, so
+// that rotated tokens, e.g. bound service account tokens, are picked up.
+type TokenFile struct {
+	path string
+	ttl  time.Duration
+
+	mu     sync.Mutex
+	token  string
+	expiry time.Time
+}
+
+// NewTokenFile creates a token read from path, cached for ttl.
+func NewTokenFile(path string, ttl time.Duration) *TokenFile {
+	return &TokenFile{path: path, ttl: ttl}
+}
+
+// Token returns the token, reading the file again if the cached token
+// expired. If the file can't be read, the cached token is used until it is
+// read.
+func (f *TokenFile) Token() (string, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	now := time.Now()
+	if f.token != "" && now.Before(f.expiry) {
+		return f.token, nil
+	}
+	data, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		if f.token != "" {
+			glog.Warningf("failed to read token file, using the cached token: %v", err)
+			return f.token, nil
+		}
+		return "", err
+	}
+	f.token = strings.TrimSpace(string(data))
+	f.expiry = now.Add(f.ttl)
+	return f.token, nil
+}
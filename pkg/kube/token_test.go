@@ -0,0 +1,51 @@
+package kube
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTokenFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "token")
+	writeToken := func(token string) {
+		if err := ioutil.WriteFile(path, []byte(token), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	expectToken := func(f *TokenFile, want string) {
+		token, err := f.Token()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if token != want {
+			t.Errorf("got token %q, want %q", token, want)
+		}
+	}
+
+	if _, err := NewTokenFile(path, time.Hour).Token(); err == nil {
+		t.Error("got no error of a missing token file")
+	}
+
+	writeToken("first\n")
+	cached := NewTokenFile(path, time.Hour)
+	expectToken(cached, "first")
+	writeToken("second")
+	expectToken(cached, "first")
+
+	expiring := NewTokenFile(path, 0)
+	expectToken(expiring, "second")
+	// Rotated.
+	writeToken("third")
+	expectToken(expiring, "third")
+	// The file is gone, the cached token is used.
+	os.Remove(path)
+	expectToken(expiring, "third")
+}
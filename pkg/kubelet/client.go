@@ -0,0 +1,318 @@
+package kubelet
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kube"
+	"github.com/golang/glog"
+	"github.com/prometheus/common/expfmt"
+	"golang.org/x/net/context/ctxhttp"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+const (
+	summaryPath = "/stats/summary"
+	metricsPath = "/metrics"
+
+	// buildInfoMetric is exported by the kubelet on its metrics endpoint and
+	// carries the version in the git_version label.
+	buildInfoMetric = "kubernetes_build_info"
+
+	// versionRetryInterval is the time between attempts to read the kubelet
+	// version while it is unknown.
+	versionRetryInterval = time.Hour
+)
+
+// SummaryProvider provides kubelet stats summaries.
+type SummaryProvider interface {
+	GetSummary(ctx context.Context) (*v1alpha1.Summary, error)
+}
+
+// Status describes the health of the connection to the kubelet.
+type Status struct {
+	Address         string     `json:"address"`
+	KubeletVersion  string     `json:"kubeletVersion,omitempty"`
+	LastSuccessTime *time.Time `json:"lastSuccessTime,omitempty"`
+	LastErrorTime   *time.Time `json:"lastErrorTime,omitempty"`
+	LastError       string     `json:"lastError,omitempty"`
+	// OldestFetchStartTime is the start time of the oldest fetch still in
+	// progress.
+	OldestFetchStartTime *time.Time `json:"oldestFetchStartTime,omitempty"`
+}
+
+// ClientConfig configures how the client connects to the kubelet.
+type ClientConfig struct {
+	// CAFile contains the CA certificates to verify the serving certificate
+	// of the kubelet with. The system roots are used if empty.
+	CAFile string
+	// InsecureSkipTLSVerify disables the verification of the serving
+	// certificate of the kubelet.
+	InsecureSkipTLSVerify bool
+	// TokenFile contains a bearer token to authenticate to the kubelet with,
+	// e.g. the token of the service account of the pod. Requests are
+	// anonymous if empty.
+	TokenFile string
+}
+
+// TLSConfig returns the TLS configuration of connections to the kubelet.
+func (c *ClientConfig) TLSConfig() (*tls.Config, error) {
+	tlsConfig := &tls.Config{InsecureSkipVerify: c.InsecureSkipTLSVerify}
+	if c.CAFile != "" {
+		caData, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caData) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+	return tlsConfig, nil
+}
+
+// StatusError is the error of a response of the kubelet with an unexpected
+// status.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("failed to get %s: unexpected status %s", e.URL, e.Status)
+}
+
+// Client fetches stats summaries from the kubelet and keeps track of how the
+// fetches went.
+type Client struct {
+	address    string
+	summaryURL string
+	metricsURL string
+	// token is nil if requests are anonymous.
+	token      *kube.TokenFile
+	httpClient *http.Client
+
+	mu            sync.Mutex
+	version       string
+	lastBody      []byte
+	lastBodyTime  time.Time
+	lastSuccess   time.Time
+	lastErrorTime time.Time
+	lastError     error
+	fetches       map[uint64]time.Time
+	nextFetchID   uint64
+	// versionAttempt is the time of the last attempt to read the version.
+	versionAttempt time.Time
+}
+
+var _ SummaryProvider = &Client{}
+
+// NewClient creates a client of the kubelet listening on address.
+func NewClient(address string, config ClientConfig) (*Client, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, err
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("invalid kubelet address %q, expected scheme://host:port", address)
+	}
+	u.Path = summaryPath
+	summaryURL := u.String()
+	u.Path = metricsPath
+	metricsURL := u.String()
+	tlsConfig, err := config.TLSConfig()
+	if err != nil {
+		return nil, err
+	}
+	var token *kube.TokenFile
+	if config.TokenFile != "" {
+		token = kube.NewTokenFile(config.TokenFile, kube.TokenFileTTL)
+		if _, err := token.Token(); err != nil {
+			return nil, err
+		}
+	}
+	transport := &http.Transport{
+		Proxy:           http.ProxyFromEnvironment,
+		TLSClientConfig: tlsConfig,
+	}
+	return &Client{
+		address:    address,
+		summaryURL: summaryURL,
+		metricsURL: metricsURL,
+		token:      token,
+		httpClient: &http.Client{Transport: transport},
+		fetches:    map[uint64]time.Time{},
+	}, nil
+}
+
+// GetSummary fetches the stats summary from the kubelet.
+func (c *Client) GetSummary(ctx context.Context) (*v1alpha1.Summary, error) {
+	id := c.startFetch()
+	body, summary, err := c.getSummary(ctx)
+	c.finishFetch(id, body, err)
+	if err == nil && c.shouldUpdateVersion() {
+		c.updateVersion(ctx)
+	}
+	return summary, err
+}
+
+func (c *Client) getSummary(ctx context.Context) ([]byte, *v1alpha1.Summary, error) {
+	body, err := c.get(ctx, c.summaryURL)
+	if err != nil {
+		return nil, nil, err
+	}
+	summary := &v1alpha1.Summary{}
+	if err := json.Unmarshal(body, summary); err != nil {
+		return body, nil, fmt.Errorf("failed to parse stats summary from %s: %v", c.summaryURL, err)
+	}
+	return body, summary, nil
+}
+
+// LastSummaryBody returns the body of the last summary received from the
+// kubelet, even if it failed to parse, and when it was received. It returns
+// nil if no summary was received yet.
+func (c *Client) LastSummaryBody() ([]byte, time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.lastBody, c.lastBodyTime
+}
+
+func (c *Client) get(ctx context.Context, u string) ([]byte, error) {
+	req, err := http.NewRequest("GET", u, nil)
+	if err != nil {
+		return nil, err
+	}
+	if c.token != nil {
+		token, err := c.token.Token()
+		if err != nil {
+			return nil, err
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+	}
+	resp, err := ctxhttp.Do(ctx, c.httpClient, req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get %s: %v", u, err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %v", u, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{URL: u, StatusCode: resp.StatusCode, Status: resp.Status}
+	}
+	return body, nil
+}
+
+// shouldUpdateVersion returns true if the version is unknown and was not
+// attempted to be read within versionRetryInterval, and records the attempt.
+// Reading it takes the whole metrics of the kubelet, which may not export
+// the version or deny access to them.
+func (c *Client) shouldUpdateVersion() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.version != "" || (!c.versionAttempt.IsZero() && time.Since(c.versionAttempt) < versionRetryInterval) {
+		return false
+	}
+	c.versionAttempt = time.Now()
+	return true
+}
+
+// updateVersion reads the kubelet version from its metrics endpoint. Older
+// kubelets don't export it, the version stays unknown then.
+func (c *Client) updateVersion(ctx context.Context) {
+	body, err := c.get(ctx, c.metricsURL)
+	if err != nil {
+		glog.V(2).Infof("failed to get kubelet version: %v", err)
+		return
+	}
+	var parser expfmt.TextParser
+	mfs, err := parser.TextToMetricFamilies(bytes.NewReader(body))
+	if err != nil {
+		glog.V(2).Infof("failed to parse kubelet metrics: %v", err)
+		return
+	}
+	mf, ok := mfs[buildInfoMetric]
+	if !ok || len(mf.Metric) == 0 {
+		return
+	}
+	for _, lp := range mf.Metric[0].Label {
+		if lp.GetName() == "git_version" {
+			c.mu.Lock()
+			c.version = lp.GetValue()
+			c.mu.Unlock()
+			return
+		}
+	}
+}
+
+func (c *Client) startFetch() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	id := c.nextFetchID
+	c.nextFetchID++
+	c.fetches[id] = time.Now()
+	return id
+}
+
+func (c *Client) finishFetch(id uint64, body []byte, err error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	delete(c.fetches, id)
+	if body != nil {
+		c.lastBody = body
+		c.lastBodyTime = time.Now()
+	}
+	if err != nil {
+		c.lastError = err
+		c.lastErrorTime = time.Now()
+		return
+	}
+	c.lastSuccess = time.Now()
+}
+
+// Version returns the kubelet version, or an empty string if unknown.
+func (c *Client) Version() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.version
+}
+
+// Status returns the current status of the client.
+func (c *Client) Status() Status {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status := Status{
+		Address:        c.address,
+		KubeletVersion: c.version,
+	}
+	if !c.lastSuccess.IsZero() {
+		t := c.lastSuccess
+		status.LastSuccessTime = &t
+	}
+	if c.lastError != nil {
+		t := c.lastErrorTime
+		status.LastErrorTime = &t
+		status.LastError = c.lastError.Error()
+	}
+	for _, start := range c.fetches {
+		if status.OldestFetchStartTime == nil || start.Before(*status.OldestFetchStartTime) {
+			t := start
+			status.OldestFetchStartTime = &t
+		}
+	}
+	return status
+}
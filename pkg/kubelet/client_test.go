@@ -0,0 +1,63 @@
+package kubelet
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestClientTokenFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	tokenFile := filepath.Join(dir, "token")
+	writeToken := func(token string) {
+		if err := ioutil.WriteFile(tokenFile, []byte(token), 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var got []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Path != summaryPath {
+			http.NotFound(w, req)
+			return
+		}
+		got = append(got, req.Header.Get("Authorization"))
+		w.Write([]byte(`{"node":{"nodeName":"node-1"}}`))
+	}))
+	defer server.Close()
+
+	writeToken("first\n")
+	client, err := NewClient(server.URL, ClientConfig{TokenFile: tokenFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	getSummary := func() {
+		if _, err := client.GetSummary(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	getSummary()
+	// The token is cached, see the tests of kube.TokenFile.
+	writeToken("second")
+	getSummary()
+
+	want := []string{"Bearer first", "Bearer first"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got authorizations %v, want %v", got, want)
+	}
+}
+
+func TestNewClientWithoutTokenFile(t *testing.T) {
+	if _, err := NewClient("https://localhost:10250", ClientConfig{TokenFile: "/nonexistent/token"}); err == nil {
+		t.Error("got no error of a missing token file")
+	}
+}
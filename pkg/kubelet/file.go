@@ -0,0 +1,36 @@
+package kubelet
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+// FileProvider provides a stats summary recorded in a file, e.g. from
+// /stats/summary of the kubelet or /debug/summary of the exporter.
+type FileProvider struct {
+	path string
+}
+
+var _ SummaryProvider = &FileProvider{}
+
+// NewFileProvider creates a provider of the summary recorded in path.
+func NewFileProvider(path string) *FileProvider {
+	return &FileProvider{path: path}
+}
+
+// GetSummary reads the summary from the file.
+func (p *FileProvider) GetSummary(ctx context.Context) (*v1alpha1.Summary, error) {
+	body, err := ioutil.ReadFile(p.path)
+	if err != nil {
+		return nil, err
+	}
+	summary := &v1alpha1.Summary{}
+	if err := json.Unmarshal(body, summary); err != nil {
+		return nil, fmt.Errorf("failed to parse stats summary from %s: %v", p.path, err)
+	}
+	return summary, nil
+}
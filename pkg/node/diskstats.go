@@ -0,0 +1,91 @@
+package node
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DiskSectorSize is the unit of the sector counts in /proc/diskstats,
+// independent of the actual sector size of the device.
+const DiskSectorSize = 512
+
+// DiskStats is a line of /proc/diskstats, see
+// https://www.kernel.org/doc/Documentation/iostats.txt.
+type DiskStats struct {
+	Major  int
+	Minor  int
+	Device string
+
+	ReadsCompleted   uint64
+	ReadsMerged      uint64
+	SectorsRead      uint64
+	ReadTimeMs       uint64
+	WritesCompleted  uint64
+	WritesMerged     uint64
+	SectorsWritten   uint64
+	WriteTimeMs      uint64
+	IOsInProgress    uint64
+	IOTimeMs         uint64
+	WeightedIOTimeMs uint64
+}
+
+// DeviceNumber identifies a block device.
+type DeviceNumber struct {
+	Major int
+	Minor int
+}
+
+// ReadDiskStats reads the I/O statistics of block devices from the procfs
+// mounted at procfsRoot.
+func ReadDiskStats(procfsRoot string) (map[DeviceNumber]DiskStats, error) {
+	f, err := os.Open(filepath.Join(procfsRoot, "diskstats"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseDiskStats(f)
+}
+
+// ParseDiskStats parses the /proc/diskstats format.
+func ParseDiskStats(r io.Reader) (map[DeviceNumber]DiskStats, error) {
+	stats := map[DeviceNumber]DiskStats{}
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		// Newer kernels append discard and flush statistics.
+		if len(fields) < 14 {
+			return nil, fmt.Errorf("invalid diskstats line %q", scanner.Text())
+		}
+		var (
+			s   DiskStats
+			err error
+		)
+		if s.Major, err = strconv.Atoi(fields[0]); err != nil {
+			return nil, fmt.Errorf("invalid major in diskstats line %q", scanner.Text())
+		}
+		if s.Minor, err = strconv.Atoi(fields[1]); err != nil {
+			return nil, fmt.Errorf("invalid minor in diskstats line %q", scanner.Text())
+		}
+		s.Device = fields[2]
+		counters := []*uint64{
+			&s.ReadsCompleted, &s.ReadsMerged, &s.SectorsRead, &s.ReadTimeMs,
+			&s.WritesCompleted, &s.WritesMerged, &s.SectorsWritten, &s.WriteTimeMs,
+			&s.IOsInProgress, &s.IOTimeMs, &s.WeightedIOTimeMs,
+		}
+		for i, c := range counters {
+			if *c, err = strconv.ParseUint(fields[3+i], 10, 64); err != nil {
+				return nil, fmt.Errorf("invalid value in diskstats line %q", scanner.Text())
+			}
+		}
+		stats[DeviceNumber{Major: s.Major, Minor: s.Minor}] = s
+	}
+	return stats, scanner.Err()
+}
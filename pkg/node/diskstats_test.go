@@ -0,0 +1,41 @@
+package node
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestReadDiskStats(t *testing.T) {
+	stats, err := ReadDiskStats("testdata/proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(stats) != 4 {
+		t.Fatalf("got %d devices, want 4", len(stats))
+	}
+	want := DiskStats{
+		Major: 8, Minor: 16, Device: "xvdba",
+		ReadsCompleted: 2049, ReadsMerged: 12, SectorsRead: 131496, ReadTimeMs: 1256,
+		WritesCompleted: 48213, WritesMerged: 3021, SectorsWritten: 8876544, WriteTimeMs: 61120,
+		IOsInProgress: 3, IOTimeMs: 52840, WeightedIOTimeMs: 62376,
+	}
+	if got := stats[DeviceNumber{Major: 8, Minor: 16}]; got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	// The discard and flush statistics of newer kernels are ignored.
+	if got := stats[DeviceNumber{Major: 259, Minor: 3}]; got.Device != "nvme1n1" || got.WeightedIOTimeMs != 9432 {
+		t.Errorf("got %+v, want nvme1n1 with 9432ms weighted I/O time", got)
+	}
+}
+
+func TestParseDiskStatsInvalid(t *testing.T) {
+	for _, line := range []string{
+		"8 0 sda 1 2 3",
+		"x 0 sda 1 2 3 4 5 6 7 8 9 10 11",
+		"8 0 sda 1 2 3 4 5 6 7 8 9 10 -1",
+	} {
+		if _, err := ParseDiskStats(strings.NewReader(line)); err == nil {
+			t.Errorf("parsed invalid line %q", line)
+		}
+	}
+}
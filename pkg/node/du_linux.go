@@ -0,0 +1,61 @@
+package node
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+// DiskUsage returns the number of bytes allocated to dir and the files below
+// it, without crossing into other filesystems. The mount points in skip are
+// skipped without being stat'ed, as stat'ing hung network filesystems blocks
+// forever.
+func DiskUsage(dir string, skip map[string]bool) (uint64, error) {
+	if skip[dir] {
+		return 0, nil
+	}
+	var st syscall.Stat_t
+	if err := syscall.Lstat(dir, &st); err != nil {
+		return 0, err
+	}
+	return diskUsage(dir, &st, uint64(st.Dev), skip)
+}
+
+func diskUsage(path string, st *syscall.Stat_t, dev uint64, skip map[string]bool) (uint64, error) {
+	if uint64(st.Dev) != dev {
+		return 0, nil
+	}
+	// st_blocks is in 512 byte units regardless of the filesystem.
+	usage := uint64(st.Blocks) * 512
+	if st.Mode&syscall.S_IFMT != syscall.S_IFDIR {
+		return usage, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	names, err := f.Readdirnames(-1)
+	f.Close()
+	if err != nil {
+		return 0, err
+	}
+	for _, name := range names {
+		child := filepath.Join(path, name)
+		if skip[child] {
+			continue
+		}
+		var childSt syscall.Stat_t
+		if err := syscall.Lstat(child, &childSt); err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return 0, err
+		}
+		childUsage, err := diskUsage(child, &childSt, dev, skip)
+		if err != nil {
+			return 0, err
+		}
+		usage += childUsage
+	}
+	return usage, nil
+}
@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package node
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// DiskUsage returns the number of bytes allocated to dir and the files below
+// it, without crossing into other filesystems.
+func DiskUsage(dir string, skip map[string]bool) (uint64, error) {
+	return 0, fmt.Errorf("disk usage is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,135 @@
+package node
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kube"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+// hostnameLabel is the node label local PVs are pinned to nodes by.
+const hostnameLabel = "kubernetes.io/hostname"
+
+// LocalVolume is a filesystem local volume in a discovery directory of the
+// local static provisioner, which creates a PV for each mount point in it.
+type LocalVolume struct {
+	StorageClass string
+	// Path is the mount point of the volume.
+	Path  string
+	Mount *MountInfo
+}
+
+// ListLocalVolumes lists the local volumes in the discovery directories,
+// given by storage class. Entries which are no mount points are skipped,
+// like the provisioner does, and so are block devices.
+func ListLocalVolumes(discoveryDirs map[string]string, mountsByPoint map[string]*MountInfo) ([]LocalVolume, error) {
+	var volumes []LocalVolume
+	for class, dir := range discoveryDirs {
+		entries, err := ioutil.ReadDir(dir)
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			path := filepath.Join(dir, e.Name())
+			mount, ok := mountsByPoint[path]
+			if !ok {
+				continue
+			}
+			volumes = append(volumes, LocalVolume{StorageClass: class, Path: path, Mount: mount})
+		}
+	}
+	return volumes, nil
+}
+
+// VolumeLister lists PVs and gets the nodes they are pinned to.
+type VolumeLister interface {
+	ListPersistentVolumes(ctx context.Context) (*kube.PersistentVolumeList, error)
+	GetNode(ctx context.Context, name string) (*kube.Node, error)
+}
+
+// LocalVolumeCache caches which PVC the local PVs of a node are bound to.
+type LocalVolumeCache struct {
+	lister   VolumeLister
+	nodeName string
+	ttl      time.Duration
+
+	mu     sync.Mutex
+	byPath map[string]*v1alpha1.PVCReference
+	expiry time.Time
+	// hostname is the hostname label of the node, empty until the node
+	// is got.
+	hostname string
+}
+
+// NewLocalVolumeCache creates a cache of the local PVs of the node named
+// nodeName, refreshing from lister after ttl. The PVs are matched by the
+// hostname label of the node, which differs from its name on some clouds.
+func NewLocalVolumeCache(lister VolumeLister, nodeName string, ttl time.Duration) *LocalVolumeCache {
+	return &LocalVolumeCache{lister: lister, nodeName: nodeName, ttl: ttl}
+}
+
+// ClaimsByPath returns the PVCs the local PVs of the node are bound to by
+// their path, nil for unbound PVs. Paths without PV are missing. On error,
+// stale results are returned if there are any.
+func (c *LocalVolumeCache) ClaimsByPath(ctx context.Context) (map[string]*v1alpha1.PVCReference, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.byPath != nil && time.Now().Before(c.expiry) {
+		return c.byPath, nil
+	}
+	if c.hostname == "" {
+		node, err := c.lister.GetNode(ctx, c.nodeName)
+		if err != nil {
+			return c.byPath, err
+		}
+		c.hostname = node.Labels[hostnameLabel]
+		if c.hostname == "" {
+			c.hostname = c.nodeName
+		}
+	}
+	list, err := c.lister.ListPersistentVolumes(ctx)
+	if err != nil {
+		return c.byPath, err
+	}
+	byPath := map[string]*v1alpha1.PVCReference{}
+	for _, pv := range list.Items {
+		if pv.Spec.Local == nil || !pinnedTo(&pv, c.hostname) {
+			continue
+		}
+		// Released PVs keep the ClaimRef of their deleted PVC until
+		// the provisioner cleans them up.
+		var claim *v1alpha1.PVCReference
+		if ref := pv.Spec.ClaimRef; ref != nil && pv.Status.Phase == kube.VolumeBound {
+			claim = &v1alpha1.PVCReference{Namespace: ref.Namespace, Name: ref.Name}
+		}
+		byPath[pv.Spec.Local.Path] = claim
+	}
+	c.byPath = byPath
+	c.expiry = time.Now().Add(c.ttl)
+	return byPath, nil
+}
+
+// pinnedTo returns true if the node affinity of pv selects the node by its
+// hostname label, as the local static provisioner sets it.
+func pinnedTo(pv *kube.PersistentVolume, hostname string) bool {
+	if pv.Spec.NodeAffinity == nil || pv.Spec.NodeAffinity.Required == nil {
+		return false
+	}
+	for _, term := range pv.Spec.NodeAffinity.Required.NodeSelectorTerms {
+		for _, expr := range term.MatchExpressions {
+			if expr.Key != hostnameLabel || expr.Operator != "In" {
+				continue
+			}
+			for _, v := range expr.Values {
+				if v == hostname {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+package node
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kube"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+// fakeVolumeLister serves a node and PVs, counting the requests.
+type fakeVolumeLister struct {
+	node  *kube.Node
+	pvs   []kube.PersistentVolume
+	err   error
+	gets  int
+	lists int
+}
+
+func (l *fakeVolumeLister) ListPersistentVolumes(ctx context.Context) (*kube.PersistentVolumeList, error) {
+	l.lists++
+	if l.err != nil {
+		return nil, l.err
+	}
+	return &kube.PersistentVolumeList{Items: l.pvs}, nil
+}
+
+func (l *fakeVolumeLister) GetNode(ctx context.Context, name string) (*kube.Node, error) {
+	l.gets++
+	if l.err != nil {
+		return nil, l.err
+	}
+	if l.node == nil || l.node.Name != name {
+		return nil, fmt.Errorf("node %q not found", name)
+	}
+	return l.node, nil
+}
+
+// newLocalPV creates a local PV at path pinned to hostname, bound to pvc in
+// team-a if it isn't empty.
+func newLocalPV(path, hostname, phase, pvc string) kube.PersistentVolume {
+	pv := kube.PersistentVolume{
+		Spec: kube.PersistentVolumeSpec{
+			Local: &kube.LocalVolumeSource{Path: path},
+			NodeAffinity: &kube.VolumeNodeAffinity{Required: &kube.NodeSelector{
+				NodeSelectorTerms: []kube.NodeSelectorTerm{{MatchExpressions: []kube.NodeSelectorRequirement{
+					{Key: hostnameLabel, Operator: "In", Values: []string{hostname}},
+				}}},
+			}},
+		},
+		Status: kube.PersistentVolumeStatus{Phase: phase},
+	}
+	if pvc != "" {
+		pv.Spec.ClaimRef = &kube.ObjectReference{Namespace: "team-a", Name: pvc}
+	}
+	return pv
+}
+
+func TestLocalVolumeCacheClaimsByPath(t *testing.T) {
+	lister := &fakeVolumeLister{
+		// The hostname label differs from the node name, as on EKS.
+		node: &kube.Node{ObjectMeta: metav1.ObjectMeta{
+			Name:   "ip-10-0-0-1.ec2.internal",
+			Labels: map[string]string{hostnameLabel: "ip-10-0-0-1"},
+		}},
+		pvs: []kube.PersistentVolume{
+			newLocalPV("/mnt/disks/bound", "ip-10-0-0-1", "Bound", "data-0"),
+			newLocalPV("/mnt/disks/released", "ip-10-0-0-1", "Released", "data-1"),
+			newLocalPV("/mnt/disks/available", "ip-10-0-0-1", "Available", ""),
+			newLocalPV("/mnt/disks/by-name", "ip-10-0-0-1.ec2.internal", "Bound", "data-2"),
+			newLocalPV("/mnt/disks/other-node", "ip-10-0-0-2", "Bound", "data-3"),
+		},
+	}
+	cache := NewLocalVolumeCache(lister, "ip-10-0-0-1.ec2.internal", time.Hour)
+	got, err := cache.ClaimsByPath(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]*v1alpha1.PVCReference{
+		"/mnt/disks/bound":     {Namespace: "team-a", Name: "data-0"},
+		"/mnt/disks/released":  nil,
+		"/mnt/disks/available": nil,
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got claims %v, want %v", got, want)
+	}
+
+	// The node and the PVs are cached.
+	if _, err := cache.ClaimsByPath(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if lister.gets != 1 || lister.lists != 1 {
+		t.Errorf("got %d node gets and %d PV lists, want 1 each", lister.gets, lister.lists)
+	}
+}
+
+func TestLocalVolumeCacheWithoutHostnameLabel(t *testing.T) {
+	lister := &fakeVolumeLister{
+		node: &kube.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		pvs:  []kube.PersistentVolume{newLocalPV("/mnt/disks/bound", "node-1", "Bound", "data-0")},
+	}
+	got, err := NewLocalVolumeCache(lister, "node-1", time.Hour).ClaimsByPath(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]*v1alpha1.PVCReference{"/mnt/disks/bound": {Namespace: "team-a", Name: "data-0"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got claims %v, want %v", got, want)
+	}
+}
+
+func TestLocalVolumeCacheStaleOnError(t *testing.T) {
+	lister := &fakeVolumeLister{
+		node: &kube.Node{ObjectMeta: metav1.ObjectMeta{Name: "node-1"}},
+		pvs:  []kube.PersistentVolume{newLocalPV("/mnt/disks/bound", "node-1", "Bound", "data-0")},
+	}
+	cache := NewLocalVolumeCache(lister, "node-1", 0)
+	want, err := cache.ClaimsByPath(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	lister.err = fmt.Errorf("forbidden")
+	got, err := cache.ClaimsByPath(context.Background())
+	if err == nil {
+		t.Error("got no error, want the error of the lister")
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got claims %v, want the stale %v", got, want)
+	}
+}
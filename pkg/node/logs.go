@@ -0,0 +1,112 @@
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// DefaultPodLogsDir is where the kubelet keeps the container logs of pods.
+const DefaultPodLogsDir = "/var/log/pods"
+
+// ContainerLogs are the log files of a container in
+// <pod-logs-dir>/<namespace>_<pod>_<uid>/<container>/.
+type ContainerLogs struct {
+	Namespace string
+	Pod       string
+	PodUID    string
+	Container string
+	// Files is the number of log files, including rotated and compressed
+	// ones.
+	Files int
+	// RotatedFiles is the number of log files rotated by the kubelet, named
+	// <restart-count>.log.<timestamp>, compressed or not. The logs of
+	// previous runs of the container, e.g. 0.log after a restart, are not
+	// rotated.
+	RotatedFiles int
+	// Bytes is the total size of the log files.
+	Bytes uint64
+	// NewestFileBytes is the size of the most recently written file, which
+	// is the one being written.
+	NewestFileBytes uint64
+	// LastWrite is the modification time of the most recently written file.
+	LastWrite time.Time
+}
+
+// ListContainerLogs lists the log files of all containers in the pod logs
+// directory. Pod directories of the legacy <uid> layout are skipped.
+func ListContainerLogs(podLogsDir string) ([]ContainerLogs, error) {
+	pods, err := ioutil.ReadDir(podLogsDir)
+	if err != nil {
+		return nil, err
+	}
+	var logs []ContainerLogs
+	for _, pod := range pods {
+		// Namespaces and pod names can't contain underscores.
+		parts := strings.SplitN(pod.Name(), "_", 3)
+		if !pod.IsDir() || len(parts) != 3 {
+			continue
+		}
+		podDir := filepath.Join(podLogsDir, pod.Name())
+		containers, err := ioutil.ReadDir(podDir)
+		if err != nil {
+			// The pod may be removed meanwhile.
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, container := range containers {
+			if !container.IsDir() {
+				continue
+			}
+			files, err := ioutil.ReadDir(filepath.Join(podDir, container.Name()))
+			if err != nil {
+				if os.IsNotExist(err) {
+					continue
+				}
+				return nil, err
+			}
+			l := ContainerLogs{
+				Namespace: parts[0],
+				Pod:       parts[1],
+				PodUID:    parts[2],
+				Container: container.Name(),
+			}
+			for _, f := range files {
+				name := f.Name()
+				// Docker keeps the logs elsewhere and the kubelet links
+				// them, the size is the one of the link target.
+				if f.Mode()&os.ModeSymlink != 0 {
+					target, err := os.Stat(filepath.Join(podDir, container.Name(), name))
+					if err != nil {
+						continue
+					}
+					f = target
+				}
+				if !f.Mode().IsRegular() {
+					continue
+				}
+				l.Files++
+				if isRotatedLog(name) {
+					l.RotatedFiles++
+				}
+				l.Bytes += uint64(f.Size())
+				if f.ModTime().After(l.LastWrite) {
+					l.LastWrite = f.ModTime()
+					l.NewestFileBytes = uint64(f.Size())
+				}
+			}
+			logs = append(logs, l)
+		}
+	}
+	return logs, nil
+}
+
+// isRotatedLog returns true if name is the name of a log file rotated by the
+// kubelet, e.g. 0.log.20240101-000000 or 0.log.20240101-000000.gz.
+func isRotatedLog(name string) bool {
+	return strings.Contains(name, ".log.") || strings.HasSuffix(name, ".gz")
+}
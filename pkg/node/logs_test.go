@@ -0,0 +1,73 @@
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestListContainerLogs(t *testing.T) {
+	root, err := ioutil.TempDir("", "logs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(root)
+	podLogsDir := filepath.Join(root, "pods")
+	containerDir := filepath.Join(podLogsDir, "team-a_web-0_uid-1", "app")
+	dockerDir := filepath.Join(root, "docker")
+	for _, dir := range []string{containerDir, dockerDir, filepath.Join(podLogsDir, "legacy-uid", "app")} {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(name string, size int, modTime time.Time) {
+		if err := ioutil.WriteFile(name, make([]byte, size), 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(name, modTime, modTime); err != nil {
+			t.Fatal(err)
+		}
+	}
+	link := func(target, name string) {
+		if err := os.Symlink(target, name); err != nil {
+			t.Fatal(err)
+		}
+	}
+	now := time.Now().Truncate(time.Second)
+	write(filepath.Join(containerDir, "0.log.20240101-000000.gz"), 10, now.Add(-time.Hour))
+	write(filepath.Join(containerDir, "0.log.20240101-010000"), 20, now.Add(-30*time.Minute))
+	// The log of the previous run of the container isn't rotated.
+	write(filepath.Join(containerDir, "1.log"), 1, now.Add(-2*time.Hour))
+	write(filepath.Join(dockerDir, "json.log"), 100, now)
+	// dockershim links the log files of docker.
+	link(filepath.Join(dockerDir, "json.log"), filepath.Join(containerDir, "2.log"))
+	link(dockerDir, filepath.Join(containerDir, "dir.log"))
+	link(filepath.Join(dockerDir, "missing.log"), filepath.Join(containerDir, "dangling.log"))
+
+	logs, err := ListContainerLogs(podLogsDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := ContainerLogs{
+		Namespace:       "team-a",
+		Pod:             "web-0",
+		PodUID:          "uid-1",
+		Container:       "app",
+		Files:           4,
+		RotatedFiles:    2,
+		Bytes:           131,
+		NewestFileBytes: 100,
+	}
+	if len(logs) != 1 {
+		t.Fatalf("got %d containers, want 1", len(logs))
+	}
+	if got := logs[0]; !got.LastWrite.Equal(now) {
+		t.Errorf("got last write at %v, want %v", got.LastWrite, now)
+	}
+	logs[0].LastWrite = time.Time{}
+	if logs[0] != want {
+		t.Errorf("got %+v, want %+v", logs[0], want)
+	}
+}
@@ -0,0 +1,170 @@
+package node
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// MountInfo is a line of /proc/<pid>/mountinfo, see proc(5).
+type MountInfo struct {
+	MountID  int
+	ParentID int
+	// Major and Minor are the device numbers of the mounted filesystem.
+	Major int
+	Minor int
+	// Root is the directory of the filesystem mounted at MountPoint.
+	Root       string
+	MountPoint string
+	// Options are the per mount options, e.g. ro or noatime.
+	Options []string
+	FSType  string
+	// Source is the filesystem specific source, e.g. a device or server path.
+	Source string
+	// SuperOptions are the per superblock options.
+	SuperOptions []string
+}
+
+// ReadOnly returns true if the mount is read-only.
+func (m *MountInfo) ReadOnly() bool {
+	for _, o := range m.Options {
+		if o == "ro" {
+			return true
+		}
+	}
+	return false
+}
+
+// auditedOptions are the mount options returned by KeyOptions, in order.
+var auditedOptions = []string{"noatime", "relatime", "strictatime", "nodiratime", "nosuid", "nodev", "noexec", "sync"}
+
+// KeyOptions returns the mount options worth auditing: "ro" if either the
+// mount or its superblock is read-only, "rw" otherwise, followed by the
+// access time, security and sync options set on the mount.
+func (m *MountInfo) KeyOptions() []string {
+	options := []string{"rw"}
+	if m.ReadOnly() {
+		options[0] = "ro"
+	}
+	for _, o := range m.SuperOptions {
+		if o == "ro" {
+			options[0] = "ro"
+		}
+	}
+	for _, audited := range auditedOptions {
+		for _, o := range m.Options {
+			if o == audited {
+				options = append(options, o)
+				break
+			}
+		}
+	}
+	return options
+}
+
+// ReadMountInfo reads the mount information of the current process from the
+// procfs mounted at procfsRoot.
+func ReadMountInfo(procfsRoot string) ([]MountInfo, error) {
+	f, err := os.Open(filepath.Join(procfsRoot, "self", "mountinfo"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseMountInfo(f)
+}
+
+// ParseMountInfo parses the mountinfo format.
+func ParseMountInfo(r io.Reader) ([]MountInfo, error) {
+	var mounts []MountInfo
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			continue
+		}
+		m, err := parseMountInfoLine(line)
+		if err != nil {
+			return nil, err
+		}
+		mounts = append(mounts, m)
+	}
+	return mounts, scanner.Err()
+}
+
+func parseMountInfoLine(line string) (MountInfo, error) {
+	// 36 35 98:0 /mnt1 /mnt2 rw,noatime master:1 - ext3 /dev/root rw,errors=continue
+	fields := strings.Fields(line)
+	sep := -1
+	for i := 6; i < len(fields); i++ {
+		if fields[i] == "-" {
+			sep = i
+			break
+		}
+	}
+	if len(fields) < 6 || sep < 0 || len(fields) < sep+3 {
+		return MountInfo{}, fmt.Errorf("invalid mountinfo line %q", line)
+	}
+	m := MountInfo{
+		Root:       unescapeMountPath(fields[3]),
+		MountPoint: unescapeMountPath(fields[4]),
+		Options:    strings.Split(fields[5], ","),
+		FSType:     fields[sep+1],
+		Source:     unescapeMountPath(fields[sep+2]),
+	}
+	if len(fields) > sep+3 {
+		m.SuperOptions = strings.Split(fields[sep+3], ",")
+	}
+	var err error
+	if m.MountID, err = strconv.Atoi(fields[0]); err != nil {
+		return MountInfo{}, fmt.Errorf("invalid mount ID in mountinfo line %q", line)
+	}
+	if m.ParentID, err = strconv.Atoi(fields[1]); err != nil {
+		return MountInfo{}, fmt.Errorf("invalid parent ID in mountinfo line %q", line)
+	}
+	dev := strings.SplitN(fields[2], ":", 2)
+	if len(dev) != 2 {
+		return MountInfo{}, fmt.Errorf("invalid device in mountinfo line %q", line)
+	}
+	if m.Major, err = strconv.Atoi(dev[0]); err != nil {
+		return MountInfo{}, fmt.Errorf("invalid device in mountinfo line %q", line)
+	}
+	if m.Minor, err = strconv.Atoi(dev[1]); err != nil {
+		return MountInfo{}, fmt.Errorf("invalid device in mountinfo line %q", line)
+	}
+	return m, nil
+}
+
+// unescapeMountPath decodes the octal escapes (\040 for space, etc.) the
+// kernel uses in mount paths.
+func unescapeMountPath(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+	var b bytes.Buffer
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			if v, err := strconv.ParseUint(s[i+1:i+4], 8, 8); err == nil {
+				b.WriteByte(byte(v))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// MountsByPoint indexes mounts by mount point. If several filesystems are
+// mounted on the same point, the last one, which is visible, wins.
+func MountsByPoint(mounts []MountInfo) map[string]*MountInfo {
+	byPoint := make(map[string]*MountInfo, len(mounts))
+	for i := range mounts {
+		byPoint[mounts[i].MountPoint] = &mounts[i]
+	}
+	return byPoint
+}
@@ -0,0 +1,78 @@
+package node
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadMountInfo(t *testing.T) {
+	mounts, err := ReadMountInfo("testdata/proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(mounts) != 8 {
+		t.Fatalf("got %d mounts, want 8", len(mounts))
+	}
+	want := MountInfo{
+		MountID:      121,
+		ParentID:     22,
+		Major:        8,
+		Minor:        16,
+		Root:         "/",
+		MountPoint:   "/var/lib/kubelet/pods/6a3f1c2e-0000-4000-8000-000000000001/volumes/kubernetes.io~aws-ebs/pvc-1b2c3d4e",
+		Options:      []string{"rw", "noatime", "nosuid"},
+		FSType:       "ext4",
+		Source:       "/dev/xvdba",
+		SuperOptions: []string{"rw", "data=ordered"},
+	}
+	if !reflect.DeepEqual(mounts[3], want) {
+		t.Errorf("got %+v, want %+v", mounts[3], want)
+	}
+}
+
+func TestParseMountInfoInvalid(t *testing.T) {
+	for _, line := range []string{
+		"22 1 8:1 / / rw,relatime shared:1 ext4 /dev/sda1 rw",
+		"22 1 8:1 / / rw - ext4",
+		"x 1 8:1 / / rw - ext4 /dev/sda1 rw",
+		"22 1 8 / / rw - ext4 /dev/sda1 rw",
+	} {
+		if _, err := ParseMountInfo(strings.NewReader(line)); err == nil {
+			t.Errorf("parsed invalid line %q", line)
+		}
+	}
+}
+
+func TestMountsByPoint(t *testing.T) {
+	mounts, err := ReadMountInfo("testdata/proc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	byPoint := MountsByPoint(mounts)
+	// The escaped space is decoded, and the mount on top wins.
+	m, ok := byPoint["/var/lib/kubelet/pods/6a3f1c2e-0000-4000-8000-000000000003/volumes/kubernetes.io~nfs/nfs data"]
+	if !ok {
+		t.Fatal("no mount with an escaped mount point")
+	}
+	if m.MountID != 141 || m.Source != "nfs.example.com:/exports/other" {
+		t.Errorf("got mount %d of %s, want 141 of nfs.example.com:/exports/other", m.MountID, m.Source)
+	}
+}
+
+func TestMountInfoKeyOptions(t *testing.T) {
+	tests := []struct {
+		mount MountInfo
+		want  []string
+	}{
+		{MountInfo{Options: []string{"rw", "relatime"}, SuperOptions: []string{"rw"}}, []string{"rw", "relatime"}},
+		{MountInfo{Options: []string{"ro", "nosuid", "noatime"}}, []string{"ro", "noatime", "nosuid"}},
+		// A read-only superblock makes the mount read-only.
+		{MountInfo{Options: []string{"rw"}, SuperOptions: []string{"ro"}}, []string{"ro"}},
+	}
+	for _, test := range tests {
+		if got := test.mount.KeyOptions(); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("KeyOptions of %v and %v = %v, want %v", test.mount.Options, test.mount.SuperOptions, got, test.want)
+		}
+	}
+}
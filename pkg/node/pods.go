@@ -0,0 +1,139 @@
+package node
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// DefaultKubeletRootDir is the default --root-dir of the kubelet.
+	DefaultKubeletRootDir = "/var/lib/kubelet"
+
+	// CSIPlugin is the escaped name of the CSI volume plugin. CSI volumes
+	// are mounted on a "mount" directory inside the volume directory.
+	CSIPlugin = "kubernetes.io~csi"
+)
+
+// PodVolume is a volume directory of a pod in the kubelet root directory,
+// <root>/pods/<uid>/volumes/<plugin>/<name>.
+type PodVolume struct {
+	PodUID string
+	// Plugin is the escaped name of the volume plugin, e.g.
+	// kubernetes.io~aws-ebs.
+	Plugin string
+	// Name is the name of the PV for persistent volumes, or the name of the
+	// volume in the pod spec for inline volumes.
+	Name string
+	// Dir is the volume directory.
+	Dir string
+	// Path is where the volume is mounted.
+	Path string
+}
+
+// PodsDir returns the directory of pods in the kubelet root directory.
+func PodsDir(kubeletRootDir string) string {
+	return filepath.Join(kubeletRootDir, "pods")
+}
+
+// ListPodUIDs lists the UIDs of the pods having a directory in the kubelet
+// root directory.
+func ListPodUIDs(kubeletRootDir string) ([]string, error) {
+	entries, err := ioutil.ReadDir(PodsDir(kubeletRootDir))
+	if err != nil {
+		return nil, err
+	}
+	var uids []string
+	for _, e := range entries {
+		if e.IsDir() {
+			uids = append(uids, e.Name())
+		}
+	}
+	return uids, nil
+}
+
+// ListPodVolumes lists the volume directories of all pods in the kubelet
+// root directory.
+func ListPodVolumes(kubeletRootDir string) ([]PodVolume, error) {
+	uids, err := ListPodUIDs(kubeletRootDir)
+	if err != nil {
+		return nil, err
+	}
+	var volumes []PodVolume
+	for _, uid := range uids {
+		podVolumes, err := listVolumesOfPod(kubeletRootDir, uid)
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, podVolumes...)
+	}
+	return volumes, nil
+}
+
+func listVolumesOfPod(kubeletRootDir, uid string) ([]PodVolume, error) {
+	volumesDir := filepath.Join(PodsDir(kubeletRootDir), uid, "volumes")
+	plugins, err := ioutil.ReadDir(volumesDir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var volumes []PodVolume
+	for _, plugin := range plugins {
+		if !plugin.IsDir() {
+			continue
+		}
+		pluginDir := filepath.Join(volumesDir, plugin.Name())
+		entries, err := ioutil.ReadDir(pluginDir)
+		if err != nil {
+			// The pod may be torn down meanwhile.
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			v := PodVolume{
+				PodUID: uid,
+				Plugin: plugin.Name(),
+				Name:   e.Name(),
+				Dir:    filepath.Join(pluginDir, e.Name()),
+			}
+			v.Path = v.Dir
+			if v.Plugin == CSIPlugin {
+				v.Path = filepath.Join(v.Dir, "mount")
+			}
+			volumes = append(volumes, v)
+		}
+	}
+	return volumes, nil
+}
+
+// ParsePodVolumePath returns the pod volume a path under the kubelet root
+// directory belongs to.
+func ParsePodVolumePath(kubeletRootDir, path string) (PodVolume, bool) {
+	rel, err := filepath.Rel(PodsDir(kubeletRootDir), path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return PodVolume{}, false
+	}
+	parts := strings.Split(rel, string(filepath.Separator))
+	if len(parts) < 4 || parts[1] != "volumes" {
+		return PodVolume{}, false
+	}
+	v := PodVolume{
+		PodUID: parts[0],
+		Plugin: parts[2],
+		Name:   parts[3],
+		Dir:    filepath.Join(PodsDir(kubeletRootDir), parts[0], "volumes", parts[2], parts[3]),
+	}
+	v.Path = v.Dir
+	if v.Plugin == CSIPlugin {
+		v.Path = filepath.Join(v.Dir, "mount")
+	}
+	return v, true
+}
@@ -0,0 +1,150 @@
+package node
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cofyc/kubelet-exporter/pkg/kube"
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+// ClaimLister lists PVCs.
+type ClaimLister interface {
+	ListPersistentVolumeClaims(ctx context.Context) (*kube.PersistentVolumeClaimList, error)
+}
+
+// ClaimCache caches which PVC each PV is bound to.
+type ClaimCache struct {
+	lister ClaimLister
+	ttl    time.Duration
+
+	mu       sync.Mutex
+	byVolume map[string]v1alpha1.PVCReference
+	expiry   time.Time
+}
+
+// NewClaimCache creates a cache refreshing from lister after ttl.
+func NewClaimCache(lister ClaimLister, ttl time.Duration) *ClaimCache {
+	return &ClaimCache{lister: lister, ttl: ttl}
+}
+
+// ClaimsByVolume returns the PVCs by the name of their bound PV. On error,
+// stale results are returned if there are any.
+func (c *ClaimCache) ClaimsByVolume(ctx context.Context) (map[string]v1alpha1.PVCReference, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.byVolume != nil && time.Now().Before(c.expiry) {
+		return c.byVolume, nil
+	}
+	list, err := c.lister.ListPersistentVolumeClaims(ctx)
+	if err != nil {
+		return c.byVolume, err
+	}
+	byVolume := make(map[string]v1alpha1.PVCReference, len(list.Items))
+	for _, pvc := range list.Items {
+		if pvc.Spec.VolumeName != "" {
+			byVolume[pvc.Spec.VolumeName] = v1alpha1.PVCReference{Name: pvc.Name, Namespace: pvc.Namespace}
+		}
+	}
+	c.byVolume = byVolume
+	c.expiry = time.Now().Add(c.ttl)
+	return byVolume, nil
+}
+
+// inlinePlugins are the volume plugins never backing a PV.
+var inlinePlugins = map[string]bool{
+	"kubernetes.io~empty-dir":    true,
+	"kubernetes.io~configmap":    true,
+	"kubernetes.io~secret":       true,
+	"kubernetes.io~projected":    true,
+	"kubernetes.io~downward-api": true,
+	"kubernetes.io~git-repo":     true,
+}
+
+// ResolvedVolume is a pod volume directory with what it belongs to.
+type ResolvedVolume struct {
+	PodVolume
+	// Pod is nil if the kubelet doesn't report the pod.
+	Pod *v1alpha1.PodReference
+	// PVC is nil if the volume is not a PVC, or it couldn't be resolved.
+	PVC *v1alpha1.PVCReference
+	// Stats are the stats of the volume in the summary, nil if there are none.
+	Stats *v1alpha1.VolumeStats
+}
+
+// ResolveVolumes finds the pods and PVCs volume directories belong to.
+//
+// Inline volumes and volumes of older kubelets are named after the volume in
+// the pod spec, as in the summary. PV backed volumes are named after the PV,
+// which claimsByVolume maps to PVCs. If a PV backed volume is still
+// unresolved, it is mapped to the PVC of its pod if the pod has exactly one
+// unresolved volume and PVC.
+func ResolveVolumes(summary *v1alpha1.Summary, volumes []PodVolume, claimsByVolume map[string]v1alpha1.PVCReference) []ResolvedVolume {
+	pods := map[string]*v1alpha1.PodStats{}
+	for i := range summary.Pods {
+		pods[summary.Pods[i].PodRef.UID] = &summary.Pods[i]
+	}
+
+	resolved := make([]ResolvedVolume, len(volumes))
+	byPod := map[string][]int{}
+	for i, v := range volumes {
+		resolved[i].PodVolume = v
+		byPod[v.PodUID] = append(byPod[v.PodUID], i)
+	}
+
+	for uid, indexes := range byPod {
+		podStats, ok := pods[uid]
+		if !ok {
+			continue
+		}
+		assigned := map[int]bool{}
+		for _, i := range indexes {
+			r := &resolved[i]
+			r.Pod = &podStats.PodRef
+			for j := range podStats.VolumeStats {
+				vs := &podStats.VolumeStats[j]
+				if vs.Name == r.Name {
+					r.Stats = vs
+					r.PVC = vs.PVCRef
+					assigned[j] = true
+					break
+				}
+			}
+			if r.Stats != nil {
+				continue
+			}
+			pvc, ok := claimsByVolume[r.Name]
+			if !ok || pvc.Namespace != podStats.PodRef.Namespace {
+				continue
+			}
+			r.PVC = &pvc
+			for j := range podStats.VolumeStats {
+				vs := &podStats.VolumeStats[j]
+				if vs.PVCRef != nil && *vs.PVCRef == pvc {
+					r.Stats = vs
+					assigned[j] = true
+					break
+				}
+			}
+		}
+
+		var unresolvedVolumes, unresolvedClaims []int
+		for _, i := range indexes {
+			if resolved[i].PVC == nil && resolved[i].Stats == nil && !inlinePlugins[resolved[i].Plugin] {
+				unresolvedVolumes = append(unresolvedVolumes, i)
+			}
+		}
+		for j := range podStats.VolumeStats {
+			if podStats.VolumeStats[j].PVCRef != nil && !assigned[j] {
+				unresolvedClaims = append(unresolvedClaims, j)
+			}
+		}
+		if len(unresolvedVolumes) == 1 && len(unresolvedClaims) == 1 {
+			r := &resolved[unresolvedVolumes[0]]
+			r.Stats = &podStats.VolumeStats[unresolvedClaims[0]]
+			r.PVC = r.Stats.PVCRef
+		}
+	}
+	return resolved
+}
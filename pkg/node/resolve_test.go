@@ -0,0 +1,115 @@
+package node
+
+import (
+	"testing"
+
+	"k8s.io/kubernetes/pkg/kubelet/apis/stats/v1alpha1"
+)
+
+func TestResolveVolumes(t *testing.T) {
+	pod := v1alpha1.PodReference{Name: "web-0", Namespace: "team-a", UID: "uid-1"}
+	data := v1alpha1.PVCReference{Name: "data-web-0", Namespace: "team-a"}
+	logs := v1alpha1.PVCReference{Name: "logs-web-0", Namespace: "team-a"}
+	summary := func(volumeStats ...v1alpha1.VolumeStats) *v1alpha1.Summary {
+		return &v1alpha1.Summary{Pods: []v1alpha1.PodStats{{PodRef: pod, VolumeStats: volumeStats}}}
+	}
+	volume := func(plugin, name string) PodVolume {
+		return PodVolume{PodUID: pod.UID, Plugin: plugin, Name: name}
+	}
+
+	// want is the PVC and the name of the stats each volume resolves to.
+	type want struct {
+		pvc   *v1alpha1.PVCReference
+		stats string
+	}
+	tests := []struct {
+		name           string
+		summary        *v1alpha1.Summary
+		volumes        []PodVolume
+		claimsByVolume map[string]v1alpha1.PVCReference
+		want           []want
+	}{
+		{
+			name:    "inline volume named as in the pod spec",
+			summary: summary(v1alpha1.VolumeStats{Name: "cache"}),
+			volumes: []PodVolume{volume("kubernetes.io~empty-dir", "cache")},
+			want:    []want{{nil, "cache"}},
+		},
+		{
+			name:    "PVC named as in the pod spec by an older kubelet",
+			summary: summary(v1alpha1.VolumeStats{Name: "data", PVCRef: &data}),
+			volumes: []PodVolume{volume("kubernetes.io~aws-ebs", "data")},
+			want:    []want{{&data, "data"}},
+		},
+		{
+			name:           "PV named directory",
+			summary:        summary(v1alpha1.VolumeStats{Name: "data", PVCRef: &data}, v1alpha1.VolumeStats{Name: "logs", PVCRef: &logs}),
+			volumes:        []PodVolume{volume(CSIPlugin, "pvc-2"), volume(CSIPlugin, "pvc-1")},
+			claimsByVolume: map[string]v1alpha1.PVCReference{"pvc-1": data, "pvc-2": logs},
+			want:           []want{{&logs, "logs"}, {&data, "data"}},
+		},
+		{
+			name:           "PV named directory without stats yet",
+			summary:        summary(),
+			volumes:        []PodVolume{volume(CSIPlugin, "pvc-1")},
+			claimsByVolume: map[string]v1alpha1.PVCReference{"pvc-1": data},
+			want:           []want{{&data, ""}},
+		},
+		{
+			name:           "PV bound to a PVC of another namespace",
+			summary:        summary(v1alpha1.VolumeStats{Name: "data", PVCRef: &data}, v1alpha1.VolumeStats{Name: "logs", PVCRef: &logs}),
+			volumes:        []PodVolume{volume(CSIPlugin, "pvc-1")},
+			claimsByVolume: map[string]v1alpha1.PVCReference{"pvc-1": {Name: "data-web-0", Namespace: "team-b"}},
+			want:           []want{{nil, ""}},
+		},
+		{
+			name:    "single unresolved PVC",
+			summary: summary(v1alpha1.VolumeStats{Name: "cache"}, v1alpha1.VolumeStats{Name: "data", PVCRef: &data}),
+			volumes: []PodVolume{volume("kubernetes.io~empty-dir", "cache"), volume("kubernetes.io~secret", "token"), volume(CSIPlugin, "pvc-1")},
+			want:    []want{{nil, "cache"}, {nil, ""}, {&data, "data"}},
+		},
+		{
+			name:           "single PVC left after resolving by PV",
+			summary:        summary(v1alpha1.VolumeStats{Name: "data", PVCRef: &data}, v1alpha1.VolumeStats{Name: "logs", PVCRef: &logs}),
+			volumes:        []PodVolume{volume(CSIPlugin, "pvc-1"), volume(CSIPlugin, "pvc-2")},
+			claimsByVolume: map[string]v1alpha1.PVCReference{"pvc-1": data},
+			want:           []want{{&data, "data"}, {&logs, "logs"}},
+		},
+		{
+			name:    "several unresolved PVCs",
+			summary: summary(v1alpha1.VolumeStats{Name: "data", PVCRef: &data}, v1alpha1.VolumeStats{Name: "logs", PVCRef: &logs}),
+			volumes: []PodVolume{volume(CSIPlugin, "pvc-1"), volume(CSIPlugin, "pvc-2")},
+			want:    []want{{nil, ""}, {nil, ""}},
+		},
+	}
+	for _, test := range tests {
+		resolved := ResolveVolumes(test.summary, test.volumes, test.claimsByVolume)
+		if len(resolved) != len(test.want) {
+			t.Fatalf("%s: got %d volumes, want %d", test.name, len(resolved), len(test.want))
+		}
+		for i, r := range resolved {
+			w := test.want[i]
+			if r.Pod == nil || *r.Pod != pod {
+				t.Errorf("%s: volume %s belongs to pod %v, want %v", test.name, r.Name, r.Pod, pod)
+			}
+			if (r.PVC == nil) != (w.pvc == nil) || r.PVC != nil && *r.PVC != *w.pvc {
+				t.Errorf("%s: volume %s resolved to PVC %v, want %v", test.name, r.Name, r.PVC, w.pvc)
+			}
+			var stats string
+			if r.Stats != nil {
+				stats = r.Stats.Name
+			}
+			if stats != w.stats {
+				t.Errorf("%s: volume %s has the stats of %q, want %q", test.name, r.Name, stats, w.stats)
+			}
+		}
+	}
+}
+
+func TestResolveVolumesOfUnknownPod(t *testing.T) {
+	summary := &v1alpha1.Summary{}
+	resolved := ResolveVolumes(summary, []PodVolume{{PodUID: "uid-1", Plugin: CSIPlugin, Name: "pvc-1"}}, nil)
+	if len(resolved) != 1 || resolved[0].Pod != nil || resolved[0].PVC != nil {
+		t.Errorf("got %+v, want a volume without pod and PVC", resolved)
+	}
+}
@@ -0,0 +1,13 @@
+package node
+
+// FsStats are the filesystem statistics of a mount point.
+type FsStats struct {
+	CapacityBytes  uint64
+	AvailableBytes uint64
+	UsedBytes      uint64
+	Inodes         uint64
+	InodesFree     uint64
+	InodesUsed     uint64
+	// ReadOnly is true if the filesystem is mounted read-only.
+	ReadOnly bool
+}
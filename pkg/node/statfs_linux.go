@@ -0,0 +1,28 @@
+package node
+
+import (
+	"syscall"
+)
+
+// stRdonly is the ST_RDONLY flag of statfs(2).
+const stRdonly = 0x1
+
+// Statfs returns the filesystem statistics of the filesystem mounted at path.
+// It may block forever on hung network filesystems.
+func Statfs(path string) (*FsStats, error) {
+	var st syscall.Statfs_t
+	if err := syscall.Statfs(path, &st); err != nil {
+		return nil, err
+	}
+	bsize := uint64(st.Bsize)
+	stats := &FsStats{
+		CapacityBytes:  st.Blocks * bsize,
+		AvailableBytes: st.Bavail * bsize,
+		UsedBytes:      (st.Blocks - st.Bfree) * bsize,
+		Inodes:         st.Files,
+		InodesFree:     st.Ffree,
+		InodesUsed:     st.Files - st.Ffree,
+		ReadOnly:       uint64(st.Flags)&stRdonly != 0,
+	}
+	return stats, nil
+}
@@ -0,0 +1,14 @@
+//go:build !linux
+// +build !linux
+
+package node
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// Statfs returns the filesystem statistics of the filesystem mounted at path.
+func Statfs(path string) (*FsStats, error) {
+	return nil, fmt.Errorf("statfs is not supported on %s", runtime.GOOS)
+}
@@ -0,0 +1,268 @@
+// Package openmetrics encodes gathered metric families in the OpenMetrics
+// text format, which the vendored Prometheus client doesn't support.
+//
+// Metric families carry neither units nor the creation times of counters.
+// Units are looked up by family name. The creation times of the series of a
+// counter family are gathered as a gauge family named by CreatedName, which
+// is folded into the counter family as _created samples.
+package openmetrics
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"math"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+const (
+	// ContentType is the content type of the OpenMetrics text format.
+	ContentType = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+
+	mediaType = "application/openmetrics-text"
+)
+
+// CreatedName returns the name of the gauge family holding the creation times
+// of the series of the counter family name.
+func CreatedName(name string) string {
+	return strings.TrimSuffix(name, "_total") + "_created"
+}
+
+// createdFamilies returns the creation time families of the counter families
+// in families, by name.
+func createdFamilies(families []*dto.MetricFamily) map[string]*dto.MetricFamily {
+	byName := make(map[string]*dto.MetricFamily, len(families))
+	for _, family := range families {
+		byName[family.GetName()] = family
+	}
+	created := map[string]*dto.MetricFamily{}
+	for _, family := range families {
+		if family.GetType() != dto.MetricType_COUNTER {
+			continue
+		}
+		name := CreatedName(family.GetName())
+		if c, ok := byName[name]; ok && c.GetType() == dto.MetricType_GAUGE {
+			created[name] = c
+		}
+	}
+	return created
+}
+
+// WithoutCreated returns a gatherer dropping the creation time families of
+// counters, for formats which can't express them.
+func WithoutCreated(gatherer prometheus.Gatherer) prometheus.Gatherer {
+	return prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		families, err := gatherer.Gather()
+		created := createdFamilies(families)
+		if len(created) == 0 {
+			return families, err
+		}
+		filtered := make([]*dto.MetricFamily, 0, len(families)-len(created))
+		for _, family := range families {
+			if created[family.GetName()] == nil {
+				filtered = append(filtered, family)
+			}
+		}
+		return filtered, err
+	})
+}
+
+// Accepts returns true if the Accept header of r accepts the OpenMetrics text
+// format.
+func Accepts(r *http.Request) bool {
+	for _, accept := range strings.Split(r.Header.Get("Accept"), ",") {
+		t, params, err := mime.ParseMediaType(accept)
+		if err != nil || t != mediaType {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// Handler serves the metrics of gatherer in the OpenMetrics text format, with
+// the units unit returns for family names.
+func Handler(gatherer prometheus.Gatherer, unit func(family string) string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		families, err := gatherer.Gather()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("An error has occurred during metrics gathering:\n\n%s", err), http.StatusInternalServerError)
+			return
+		}
+		var buf bytes.Buffer
+		if err := Encode(&buf, families, unit); err != nil {
+			http.Error(w, fmt.Sprintf("An error has occurred during metrics encoding:\n\n%s", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", ContentType)
+		if !acceptsGzip(r) {
+			w.Write(buf.Bytes())
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		gz := gzip.NewWriter(w)
+		gz.Write(buf.Bytes())
+		gz.Close()
+	})
+}
+
+// acceptsGzip returns true if the Accept-Encoding header of r accepts gzip.
+func acceptsGzip(r *http.Request) bool {
+	for _, encoding := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		// Encodings aren't media types, but have the same parameters.
+		name, params, err := mime.ParseMediaType(encoding)
+		if err != nil || name != "gzip" {
+			continue
+		}
+		if q, err := strconv.ParseFloat(params["q"], 64); err == nil && q == 0 {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// Encode writes families in the OpenMetrics text format, with the units unit
+// returns for family names. Units are only declared if the name of the
+// family ends with them, as OpenMetrics requires.
+func Encode(w io.Writer, families []*dto.MetricFamily, unit func(family string) string) error {
+	created := createdFamilies(families)
+	bw := bufio.NewWriter(w)
+	for _, family := range families {
+		if created[family.GetName()] != nil {
+			continue
+		}
+		name := family.GetName()
+		typ := "unknown"
+		switch family.GetType() {
+		case dto.MetricType_COUNTER:
+			typ = "counter"
+			name = strings.TrimSuffix(name, "_total")
+		case dto.MetricType_GAUGE:
+			typ = "gauge"
+		case dto.MetricType_SUMMARY:
+			typ = "summary"
+		case dto.MetricType_HISTOGRAM:
+			typ = "histogram"
+		}
+		fmt.Fprintf(bw, "# TYPE %s %s\n", name, typ)
+		if u := unit(family.GetName()); u != "" && strings.HasSuffix(name, "_"+u) {
+			fmt.Fprintf(bw, "# UNIT %s %s\n", name, u)
+		}
+		if family.Help != nil {
+			fmt.Fprintf(bw, "# HELP %s %s\n", name, escape(family.GetHelp()))
+		}
+
+		createdBySeries := map[string]float64{}
+		if c := created[CreatedName(family.GetName())]; c != nil {
+			for _, m := range c.Metric {
+				createdBySeries[labelsKey(m.Label)] = m.GetGauge().GetValue()
+			}
+		}
+		for _, m := range family.Metric {
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				writeSample(bw, name+"_total", m, "", "", m.GetCounter().GetValue())
+				if v, ok := createdBySeries[labelsKey(m.Label)]; ok {
+					writeSample(bw, name+"_created", m, "", "", v)
+				}
+			case dto.MetricType_GAUGE:
+				writeSample(bw, name, m, "", "", m.GetGauge().GetValue())
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.Quantile {
+					writeSample(bw, name, m, "quantile", formatFloat(q.GetQuantile()), q.GetValue())
+				}
+				writeSample(bw, name+"_sum", m, "", "", s.GetSampleSum())
+				writeSample(bw, name+"_count", m, "", "", float64(s.GetSampleCount()))
+			case dto.MetricType_HISTOGRAM:
+				h := m.GetHistogram()
+				infSeen := false
+				for _, b := range h.Bucket {
+					writeSample(bw, name+"_bucket", m, "le", formatFloat(b.GetUpperBound()), float64(b.GetCumulativeCount()))
+					infSeen = infSeen || math.IsInf(b.GetUpperBound(), 1)
+				}
+				if !infSeen {
+					writeSample(bw, name+"_bucket", m, "le", "+Inf", float64(h.GetSampleCount()))
+				}
+				writeSample(bw, name+"_sum", m, "", "", h.GetSampleSum())
+				writeSample(bw, name+"_count", m, "", "", float64(h.GetSampleCount()))
+			default:
+				writeSample(bw, name, m, "", "", m.GetUntyped().GetValue())
+			}
+		}
+	}
+	bw.WriteString("# EOF\n")
+	return bw.Flush()
+}
+
+// writeSample writes a sample of m, with an additional label if extraName is
+// not empty.
+func writeSample(w *bufio.Writer, name string, m *dto.Metric, extraName, extraValue string, value float64) {
+	w.WriteString(name)
+	if len(m.Label) > 0 || extraName != "" {
+		w.WriteByte('{')
+		for i, l := range m.Label {
+			if i > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, l.GetName(), escape(l.GetValue()))
+		}
+		if extraName != "" {
+			if len(m.Label) > 0 {
+				w.WriteByte(',')
+			}
+			fmt.Fprintf(w, `%s="%s"`, extraName, escape(extraValue))
+		}
+		w.WriteByte('}')
+	}
+	w.WriteByte(' ')
+	w.WriteString(formatFloat(value))
+	if m.TimestampMs != nil {
+		w.WriteByte(' ')
+		w.WriteString(strconv.FormatFloat(float64(m.GetTimestampMs())/1000, 'f', -1, 64))
+	}
+	w.WriteByte('\n')
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escape(s string) string {
+	return escaper.Replace(s)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	case math.IsNaN(f):
+		return "NaN"
+	default:
+		return strconv.FormatFloat(f, 'g', -1, 64)
+	}
+}
+
+// labelsKey returns a key identifying a label set.
+func labelsKey(labels []*dto.LabelPair) string {
+	var b bytes.Buffer
+	for _, l := range labels {
+		b.WriteString(l.GetName())
+		b.WriteByte(0)
+		b.WriteString(l.GetValue())
+		b.WriteByte(0)
+	}
+	return b.String()
+}
@@ -0,0 +1,250 @@
+package openmetrics
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// newMetric creates a metric with labels of name value pairs.
+func newMetric(labels ...string) *dto.Metric {
+	m := &dto.Metric{}
+	for i := 0; i < len(labels); i += 2 {
+		m.Label = append(m.Label, &dto.LabelPair{Name: proto.String(labels[i]), Value: proto.String(labels[i+1])})
+	}
+	return m
+}
+
+func newCounter(v float64, labels ...string) *dto.Metric {
+	m := newMetric(labels...)
+	m.Counter = &dto.Counter{Value: proto.Float64(v)}
+	return m
+}
+
+func newGauge(v float64, labels ...string) *dto.Metric {
+	m := newMetric(labels...)
+	m.Gauge = &dto.Gauge{Value: proto.Float64(v)}
+	return m
+}
+
+func newFamily(name, help string, typ dto.MetricType, metrics ...*dto.Metric) *dto.MetricFamily {
+	family := &dto.MetricFamily{Name: proto.String(name), Type: typ.Enum(), Metric: metrics}
+	if help != "" {
+		family.Help = proto.String(help)
+	}
+	return family
+}
+
+// testFamilies returns families of every type, and the creation times of a
+// counter family.
+func testFamilies() []*dto.MetricFamily {
+	timestamped := newGauge(1e21, "namespace", "team-a", "persistentvolumeclaim", "a\"b\\c\nd")
+	timestamped.TimestampMs = proto.Int64(1500000000123)
+	summary := newMetric("method", "GetSummary")
+	summary.Summary = &dto.Summary{
+		SampleCount: proto.Uint64(3),
+		SampleSum:   proto.Float64(0.75),
+		Quantile:    []*dto.Quantile{{Quantile: proto.Float64(0.5), Value: proto.Float64(0.25)}},
+	}
+	histogram := newMetric()
+	histogram.Histogram = &dto.Histogram{
+		SampleCount: proto.Uint64(7),
+		SampleSum:   proto.Float64(1024),
+		Bucket: []*dto.Bucket{
+			{UpperBound: proto.Float64(1), CumulativeCount: proto.Uint64(2)},
+			{UpperBound: proto.Float64(1e3), CumulativeCount: proto.Uint64(5)},
+		},
+	}
+	untyped := newMetric()
+	untyped.Untyped = &dto.Untyped{Value: proto.Float64(math.Inf(-1))}
+	return []*dto.MetricFamily{
+		newFamily("kubelet_volume_nfs_read_bytes_total", "Number of bytes read", dto.MetricType_COUNTER,
+			newCounter(4096, "namespace", "team-a", "persistentvolumeclaim", "data"),
+			// Without creation time.
+			newCounter(10, "namespace", "team-b", "persistentvolumeclaim", "logs"),
+		),
+		newFamily("kubelet_volume_nfs_read_bytes_created", "Time the series were created", dto.MetricType_GAUGE,
+			newGauge(1.5e9, "namespace", "team-a", "persistentvolumeclaim", "data"),
+		),
+		// Not the creation times of a counter.
+		newFamily("kubelet_volume_mount_created", "", dto.MetricType_GAUGE, newGauge(1.5e9)),
+		newFamily("kubelet_volume_stats_used_bytes", "Number of \"used\" bytes\nin the volume, see C:\\", dto.MetricType_GAUGE,
+			timestamped,
+			newGauge(math.NaN(), "namespace", "team-b"),
+			newGauge(0.1, "namespace", "team-c"),
+		),
+		newFamily("kubelet_rpc_duration_seconds", "Duration of RPCs", dto.MetricType_SUMMARY, summary),
+		newFamily("kubelet_request_size_bytes", "Size of requests", dto.MetricType_HISTOGRAM, histogram),
+		newFamily("kubelet_untyped", "", dto.MetricType_UNTYPED, untyped),
+	}
+}
+
+func testUnit(family string) string {
+	return map[string]string{
+		"kubelet_volume_nfs_read_bytes_total": "bytes",
+		"kubelet_volume_stats_used_bytes":     "bytes",
+		"kubelet_rpc_duration_seconds":        "seconds",
+		// Not the suffix of the family, so not declared.
+		"kubelet_request_size_bytes": "seconds",
+	}[family]
+}
+
+const testGolden = `# TYPE kubelet_volume_nfs_read_bytes counter
+# UNIT kubelet_volume_nfs_read_bytes bytes
+# HELP kubelet_volume_nfs_read_bytes Number of bytes read
+kubelet_volume_nfs_read_bytes_total{namespace="team-a",persistentvolumeclaim="data"} 4096
+kubelet_volume_nfs_read_bytes_created{namespace="team-a",persistentvolumeclaim="data"} 1.5e+09
+kubelet_volume_nfs_read_bytes_total{namespace="team-b",persistentvolumeclaim="logs"} 10
+# TYPE kubelet_volume_mount_created gauge
+kubelet_volume_mount_created 1.5e+09
+# TYPE kubelet_volume_stats_used_bytes gauge
+# UNIT kubelet_volume_stats_used_bytes bytes
+# HELP kubelet_volume_stats_used_bytes Number of \"used\" bytes\nin the volume, see C:\\
+kubelet_volume_stats_used_bytes{namespace="team-a",persistentvolumeclaim="a\"b\\c\nd"} 1e+21 1500000000.123
+kubelet_volume_stats_used_bytes{namespace="team-b"} NaN
+kubelet_volume_stats_used_bytes{namespace="team-c"} 0.1
+# TYPE kubelet_rpc_duration_seconds summary
+# UNIT kubelet_rpc_duration_seconds seconds
+# HELP kubelet_rpc_duration_seconds Duration of RPCs
+kubelet_rpc_duration_seconds{method="GetSummary",quantile="0.5"} 0.25
+kubelet_rpc_duration_seconds_sum{method="GetSummary"} 0.75
+kubelet_rpc_duration_seconds_count{method="GetSummary"} 3
+# TYPE kubelet_request_size_bytes histogram
+# HELP kubelet_request_size_bytes Size of requests
+kubelet_request_size_bytes_bucket{le="1"} 2
+kubelet_request_size_bytes_bucket{le="1000"} 5
+kubelet_request_size_bytes_bucket{le="+Inf"} 7
+kubelet_request_size_bytes_sum 1024
+kubelet_request_size_bytes_count 7
+# TYPE kubelet_untyped unknown
+kubelet_untyped -Inf
+# EOF
+`
+
+func TestEncode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, testFamilies(), testUnit); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != testGolden {
+		t.Errorf("got\n%s\nwant\n%s", got, testGolden)
+	}
+
+	buf.Reset()
+	if err := Encode(&buf, nil, testUnit); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != "# EOF\n" {
+		t.Errorf("got %q without families, want only # EOF", got)
+	}
+}
+
+func TestWithoutCreated(t *testing.T) {
+	gatherErr := errors.New("partial")
+	gatherer := WithoutCreated(prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return testFamilies(), gatherErr
+	}))
+	families, err := gatherer.Gather()
+	if err != gatherErr {
+		t.Errorf("got error %v, want the error of the gatherer", err)
+	}
+	var got []string
+	for _, family := range families {
+		got = append(got, family.GetName())
+	}
+	want := []string{
+		"kubelet_volume_nfs_read_bytes_total",
+		"kubelet_volume_mount_created",
+		"kubelet_volume_stats_used_bytes",
+		"kubelet_rpc_duration_seconds",
+		"kubelet_request_size_bytes",
+		"kubelet_untyped",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got families %v, want %v", got, want)
+	}
+}
+
+func TestAccepts(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{accept: "", want: false},
+		{accept: "text/plain; version=0.0.4", want: false},
+		{accept: "application/openmetrics-text", want: true},
+		{accept: "application/openmetrics-text; version=1.0.0; charset=utf-8", want: true},
+		{accept: "application/openmetrics-text;version=1.0.0;q=0.75,text/plain;version=0.0.4;q=0.5,*/*;q=0.1", want: true},
+		{accept: "text/plain;q=0.5, application/openmetrics-text;q=0.9", want: true},
+		{accept: "application/openmetrics-text;q=0", want: false},
+		{accept: "application/openmetrics-text;q=0.0, text/plain", want: false},
+		{accept: "*/*", want: false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		r.Header.Set("Accept", test.accept)
+		if got := Accepts(r); got != test.want {
+			t.Errorf("got %v for Accept %q, want %v", got, test.accept, test.want)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	handler := Handler(prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return testFamilies(), nil
+	}), testUnit)
+	tests := []struct {
+		acceptEncoding string
+		gzip           bool
+	}{
+		{acceptEncoding: "", gzip: false},
+		{acceptEncoding: "identity", gzip: false},
+		{acceptEncoding: "gzip", gzip: true},
+		{acceptEncoding: "deflate, gzip;q=1.0, *;q=0.5", gzip: true},
+		{acceptEncoding: "gzip;q=0, identity", gzip: false},
+	}
+	for _, test := range tests {
+		r := httptest.NewRequest("GET", "/metrics", nil)
+		r.Header.Set("Accept-Encoding", test.acceptEncoding)
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		if ct := w.Header().Get("Content-Type"); ct != ContentType {
+			t.Errorf("got content type %q for Accept-Encoding %q, want %q", ct, test.acceptEncoding, ContentType)
+		}
+		body := w.Body.Bytes()
+		if got := w.Header().Get("Content-Encoding") == "gzip"; got != test.gzip {
+			t.Errorf("got gzip %v for Accept-Encoding %q, want %v", got, test.acceptEncoding, test.gzip)
+			continue
+		}
+		if test.gzip {
+			gz, err := gzip.NewReader(bytes.NewReader(body))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if body, err = ioutil.ReadAll(gz); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if string(body) != testGolden {
+			t.Errorf("got body\n%s\nfor Accept-Encoding %q, want\n%s", body, test.acceptEncoding, testGolden)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	Handler(prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) {
+		return nil, errors.New("collector failed")
+	}), testUnit).ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("got status %d on gather errors, want 500", w.Code)
+	}
+}
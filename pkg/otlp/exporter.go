@@ -0,0 +1,229 @@
+// Package otlp pushes the metrics of the collectors to an OpenTelemetry
+// collector over OTLP/HTTP with protobuf encoding.
+package otlp
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"golang.org/x/net/context/ctxhttp"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// MetricsPath is the path of the metrics export of OTLP/HTTP endpoints.
+const MetricsPath = "/v1/metrics"
+
+// scopeName is the instrumentation scope of the exported metrics.
+const scopeName = "kubelet-exporter"
+
+// resourceLabels map the labels identifying Kubernetes objects to the
+// resource attributes of the semantic conventions. Series are grouped into
+// resources by them, other labels are attributes of data points.
+var resourceLabels = map[string]string{
+	"namespace":             "k8s.namespace.name",
+	"pod":                   "k8s.pod.name",
+	"pod_uid":               "k8s.pod.uid",
+	"container":             "k8s.container.name",
+	"persistentvolumeclaim": "k8s.pvc.name",
+}
+
+// Exporter pushes the metrics of a gatherer to an OTLP/HTTP endpoint.
+type Exporter struct {
+	url      string
+	gatherer prometheus.Gatherer
+	// resource are the attributes of all resources, e.g. the node name.
+	resource  map[string]string
+	client    *http.Client
+	startTime time.Time
+}
+
+// NewExporter creates an exporter pushing to endpoint, e.g.
+// http://otel-collector:4318. If nodeName is not empty, it is the
+// k8s.node.name of all resources.
+func NewExporter(endpoint string, gatherer prometheus.Gatherer, nodeName string) *Exporter {
+	resource := map[string]string{}
+	if nodeName != "" {
+		resource["k8s.node.name"] = nodeName
+	}
+	return &Exporter{
+		url:       strings.TrimSuffix(endpoint, "/") + MetricsPath,
+		gatherer:  gatherer,
+		resource:  resource,
+		client:    &http.Client{Timeout: 30 * time.Second},
+		startTime: time.Now(),
+	}
+}
+
+// Run pushes every interval until stopCh is closed.
+func (e *Exporter) Run(interval time.Duration, stopCh <-chan struct{}) {
+	wait.Until(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), interval)
+		defer cancel()
+		if err := e.Push(ctx); err != nil {
+			glog.Errorf("failed to push metrics to %s: %v", e.url, err)
+		}
+	}, interval, stopCh)
+}
+
+// Push gathers the metrics and pushes them once.
+func (e *Exporter) Push(ctx context.Context) error {
+	families, err := e.gatherer.Gather()
+	if err != nil {
+		// Gather returns what it could gather on errors.
+		glog.Warningf("failed to gather some metrics: %v", err)
+	}
+	req := Convert(families, e.resource, e.startTime, time.Now())
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequest("POST", e.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	resp, err := ctxhttp.Do(ctx, e.client, httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	}
+	return nil
+}
+
+// Convert converts metric families to an OTLP export request. Counters are
+// monotonic cumulative sums starting at start, gauges and untyped metrics
+// gauges. Summaries and histograms are skipped. Samples without timestamp
+// are at now.
+func Convert(families []*dto.MetricFamily, resource map[string]string, start, now time.Time) *ExportMetricsServiceRequest {
+	type resourceEntry struct {
+		metrics       *ResourceMetrics
+		metricsByName map[string]*Metric
+	}
+	var (
+		req       = &ExportMetricsServiceRequest{}
+		resources = map[string]*resourceEntry{}
+	)
+	for _, family := range families {
+		for _, m := range family.Metric {
+			var value float64
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				value = m.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				value = m.GetGauge().GetValue()
+			case dto.MetricType_UNTYPED:
+				value = m.GetUntyped().GetValue()
+			default:
+				continue
+			}
+
+			attrs := map[string]string{}
+			for k, v := range resource {
+				attrs[k] = v
+			}
+			var pointAttrs []*KeyValue
+			for _, l := range m.Label {
+				if key, ok := resourceLabels[l.GetName()]; ok {
+					if l.GetValue() != "" {
+						attrs[key] = l.GetValue()
+					}
+					continue
+				}
+				pointAttrs = append(pointAttrs, keyValue(l.GetName(), l.GetValue()))
+			}
+			key, resourceAttrs := resourceKey(attrs)
+			entry, ok := resources[key]
+			if !ok {
+				entry = &resourceEntry{
+					metrics: &ResourceMetrics{
+						Resource:     &Resource{Attributes: resourceAttrs},
+						ScopeMetrics: []*ScopeMetrics{{Scope: &InstrumentationScope{Name: scopeName}}},
+					},
+					metricsByName: map[string]*Metric{},
+				}
+				resources[key] = entry
+				req.ResourceMetrics = append(req.ResourceMetrics, entry.metrics)
+			}
+
+			metric, ok := entry.metricsByName[family.GetName()]
+			if !ok {
+				metric = &Metric{Name: family.GetName(), Description: family.GetHelp(), Unit: unit(family.GetName())}
+				if family.GetType() == dto.MetricType_COUNTER {
+					metric.Sum = &Sum{AggregationTemporality: AggregationTemporalityCumulative, IsMonotonic: true}
+				} else {
+					metric.Gauge = &Gauge{}
+				}
+				entry.metricsByName[family.GetName()] = metric
+				scope := entry.metrics.ScopeMetrics[0]
+				scope.Metrics = append(scope.Metrics, metric)
+			}
+
+			t := now
+			if m.TimestampMs != nil {
+				t = time.Unix(0, m.GetTimestampMs()*int64(time.Millisecond))
+			}
+			point := &NumberDataPoint{Attributes: pointAttrs, TimeUnixNano: uint64(t.UnixNano()), AsDouble: &value}
+			if metric.Sum != nil {
+				point.StartTimeUnixNano = uint64(start.UnixNano())
+				metric.Sum.DataPoints = append(metric.Sum.DataPoints, point)
+			} else {
+				metric.Gauge.DataPoints = append(metric.Gauge.DataPoints, point)
+			}
+		}
+	}
+	return req
+}
+
+// resourceKey returns a key identifying the attributes, and the attributes
+// sorted by key.
+func resourceKey(attrs map[string]string) (string, []*KeyValue) {
+	keys := make([]string, 0, len(attrs))
+	for k := range attrs {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var (
+		b  bytes.Buffer
+		kv = make([]*KeyValue, 0, len(keys))
+	)
+	for _, k := range keys {
+		b.WriteString(k)
+		b.WriteByte(0)
+		b.WriteString(attrs[k])
+		b.WriteByte(0)
+		kv = append(kv, keyValue(k, attrs[k]))
+	}
+	return b.String(), kv
+}
+
+func keyValue(key, value string) *KeyValue {
+	return &KeyValue{Key: key, Value: &AnyValue{StringValue: &value}}
+}
+
+// unit returns the UCUM unit of a metric by the suffix of its name.
+func unit(name string) string {
+	name = strings.TrimSuffix(name, "_total")
+	switch {
+	case strings.HasSuffix(name, "_bytes"):
+		return "By"
+	case strings.HasSuffix(name, "_seconds"):
+		return "s"
+	default:
+		return ""
+	}
+}
@@ -0,0 +1,183 @@
+package otlp
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// receiver is an OTLP/HTTP endpoint keeping the export requests it received.
+type receiver struct {
+	t        *testing.T
+	status   int
+	requests []*ExportMetricsServiceRequest
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.Method != "POST" || req.URL.Path != MetricsPath {
+		r.t.Errorf("got %s %s, want POST %s", req.Method, req.URL.Path, MetricsPath)
+	}
+	if ct := req.Header.Get("Content-Type"); ct != "application/x-protobuf" {
+		r.t.Errorf("got content type %q, want application/x-protobuf", ct)
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		r.t.Error(err)
+	}
+	export := &ExportMetricsServiceRequest{}
+	if err := proto.Unmarshal(body, export); err != nil {
+		r.t.Errorf("failed to unmarshal the export request: %v", err)
+	}
+	r.requests = append(r.requests, export)
+	if r.status != 0 {
+		http.Error(w, "rejected", r.status)
+	}
+}
+
+// attributes returns key value pairs as a map.
+func attributes(kvs []*KeyValue) map[string]string {
+	attrs := map[string]string{}
+	for _, kv := range kvs {
+		if kv.Value != nil && kv.Value.StringValue != nil {
+			attrs[kv.Key] = *kv.Value.StringValue
+		}
+	}
+	return attrs
+}
+
+func newTestGatherer() prometheus.Gatherer {
+	used := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kubelet_volume_stats_used_bytes",
+		Help: "Number of used bytes in the volume",
+	}, []string{"namespace", "persistentvolumeclaim", "source"})
+	used.WithLabelValues("team-a", "data-0", "kubelet").Set(100)
+	used.WithLabelValues("team-a", "data-1", "kubelet").Set(200)
+	reads := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kubelet_volume_disk_reads_completed_total",
+		Help: "Number of reads completed on the device of the volume",
+	}, []string{"namespace", "persistentvolumeclaim"})
+	reads.WithLabelValues("team-a", "data-0").Add(7)
+	cpu := prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "kubelet_container_cpu_usage_seconds_total",
+		Help: "Cumulative CPU time of the container",
+	}, []string{"namespace", "pod", "container"})
+	cpu.WithLabelValues("team-a", "web-0", "nginx").Add(1.5)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(used, reads, cpu)
+	return registry
+}
+
+func TestExporterPush(t *testing.T) {
+	r := &receiver{t: t}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	exporter := NewExporter(server.URL+"/", newTestGatherer(), "node-1")
+	if err := exporter.Push(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(r.requests))
+	}
+
+	// metric is a metric of a resource as received.
+	type metric struct {
+		unit      string
+		monotonic bool
+		gauge     bool
+		values    map[float64]map[string]string
+	}
+	got := map[string]map[string]metric{}
+	for _, rm := range r.requests[0].ResourceMetrics {
+		resource := attributes(rm.Resource.Attributes)
+		if len(rm.ScopeMetrics) != 1 || rm.ScopeMetrics[0].Scope.Name != scopeName {
+			t.Fatalf("got scope metrics %v, want one of scope %s", rm.ScopeMetrics, scopeName)
+		}
+		if resource["k8s.node.name"] != "node-1" {
+			t.Errorf("got resource %v without k8s.node.name node-1", resource)
+		}
+		delete(resource, "k8s.node.name")
+		key, _ := resourceKey(resource)
+		key = strings.Replace(key, "\x00", " ", -1)
+		if _, ok := got[key]; ok {
+			t.Errorf("got resource %s twice", key)
+		}
+		got[key] = map[string]metric{}
+		for _, m := range rm.ScopeMetrics[0].Metrics {
+			var points []*NumberDataPoint
+			converted := metric{unit: m.Unit, values: map[float64]map[string]string{}}
+			switch {
+			case m.Sum != nil && m.Gauge == nil:
+				if m.Sum.AggregationTemporality != AggregationTemporalityCumulative {
+					t.Errorf("got aggregation temporality %v of %s, want cumulative", m.Sum.AggregationTemporality, m.Name)
+				}
+				converted.monotonic = m.Sum.IsMonotonic
+				points = m.Sum.DataPoints
+				for _, p := range points {
+					if p.StartTimeUnixNano == 0 || p.StartTimeUnixNano > p.TimeUnixNano {
+						t.Errorf("got start time %d after time %d of %s", p.StartTimeUnixNano, p.TimeUnixNano, m.Name)
+					}
+				}
+			case m.Gauge != nil && m.Sum == nil:
+				converted.gauge = true
+				points = m.Gauge.DataPoints
+			default:
+				t.Fatalf("got metric %s with gauge %v and sum %v, want exactly one", m.Name, m.Gauge, m.Sum)
+			}
+			for _, p := range points {
+				converted.values[*p.AsDouble] = attributes(p.Attributes)
+			}
+			got[key][m.Name] = converted
+		}
+	}
+
+	want := map[string]map[string]metric{
+		"k8s.namespace.name team-a k8s.pvc.name data-0 ": {
+			"kubelet_volume_stats_used_bytes": {unit: "By", gauge: true, values: map[float64]map[string]string{
+				100: {"source": "kubelet"},
+			}},
+			"kubelet_volume_disk_reads_completed_total": {unit: "", monotonic: true, values: map[float64]map[string]string{
+				7: {},
+			}},
+		},
+		"k8s.namespace.name team-a k8s.pvc.name data-1 ": {
+			"kubelet_volume_stats_used_bytes": {unit: "By", gauge: true, values: map[float64]map[string]string{
+				200: {"source": "kubelet"},
+			}},
+		},
+		"k8s.container.name nginx k8s.namespace.name team-a k8s.pod.name web-0 ": {
+			"kubelet_container_cpu_usage_seconds_total": {unit: "s", monotonic: true, values: map[float64]map[string]string{
+				1.5: {},
+			}},
+		},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got resources\n%v\nwant\n%v", got, want)
+	}
+}
+
+func TestExporterPushRejected(t *testing.T) {
+	r := &receiver{t: t, status: http.StatusBadRequest}
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	err := NewExporter(server.URL, newTestGatherer(), "").Push(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "400") || !strings.Contains(err.Error(), "rejected") {
+		t.Errorf("got error %v, want the status and message of the receiver", err)
+	}
+	if len(r.requests) != 1 {
+		t.Fatalf("got %d requests, want 1", len(r.requests))
+	}
+	for _, rm := range r.requests[0].ResourceMetrics {
+		if resource := attributes(rm.Resource.Attributes); resource["k8s.node.name"] != "" {
+			t.Errorf("got k8s.node.name %q without node name", resource["k8s.node.name"])
+		}
+	}
+}
@@ -0,0 +1,129 @@
+package otlp
+
+import (
+	"github.com/golang/protobuf/proto"
+)
+
+// The messages of the OTLP metrics v1 protocol the exporter uses, with the
+// field numbers of the opentelemetry-proto definitions.
+
+// AggregationTemporality is how the values of a sum aggregate over time.
+type AggregationTemporality int32
+
+// AggregationTemporalityCumulative sums are reported since a fixed start time.
+const AggregationTemporalityCumulative AggregationTemporality = 2
+
+// ExportMetricsServiceRequest is the body of an OTLP/HTTP metrics export.
+type ExportMetricsServiceRequest struct {
+	ResourceMetrics []*ResourceMetrics `protobuf:"bytes,1,rep,name=resource_metrics,json=resourceMetrics"`
+}
+
+func (m *ExportMetricsServiceRequest) Reset()         { *m = ExportMetricsServiceRequest{} }
+func (m *ExportMetricsServiceRequest) String() string { return proto.CompactTextString(m) }
+func (*ExportMetricsServiceRequest) ProtoMessage()    {}
+
+// ResourceMetrics are the metrics of a resource.
+type ResourceMetrics struct {
+	Resource     *Resource       `protobuf:"bytes,1,opt,name=resource"`
+	ScopeMetrics []*ScopeMetrics `protobuf:"bytes,2,rep,name=scope_metrics,json=scopeMetrics"`
+}
+
+func (m *ResourceMetrics) Reset()         { *m = ResourceMetrics{} }
+func (m *ResourceMetrics) String() string { return proto.CompactTextString(m) }
+func (*ResourceMetrics) ProtoMessage()    {}
+
+// Resource is the entity metrics are about, e.g. a PVC.
+type Resource struct {
+	Attributes []*KeyValue `protobuf:"bytes,1,rep,name=attributes"`
+}
+
+func (m *Resource) Reset()         { *m = Resource{} }
+func (m *Resource) String() string { return proto.CompactTextString(m) }
+func (*Resource) ProtoMessage()    {}
+
+// KeyValue is an attribute.
+type KeyValue struct {
+	Key   string    `protobuf:"bytes,1,opt,name=key,proto3"`
+	Value *AnyValue `protobuf:"bytes,2,opt,name=value"`
+}
+
+func (m *KeyValue) Reset()         { *m = KeyValue{} }
+func (m *KeyValue) String() string { return proto.CompactTextString(m) }
+func (*KeyValue) ProtoMessage()    {}
+
+// AnyValue is an attribute value. Only strings are used, the oneof is
+// encoded as an optional field, which is the same on the wire.
+type AnyValue struct {
+	StringValue *string `protobuf:"bytes,1,opt,name=string_value,json=stringValue"`
+}
+
+func (m *AnyValue) Reset()         { *m = AnyValue{} }
+func (m *AnyValue) String() string { return proto.CompactTextString(m) }
+func (*AnyValue) ProtoMessage()    {}
+
+// ScopeMetrics are the metrics of an instrumentation scope.
+type ScopeMetrics struct {
+	Scope   *InstrumentationScope `protobuf:"bytes,1,opt,name=scope"`
+	Metrics []*Metric             `protobuf:"bytes,2,rep,name=metrics"`
+}
+
+func (m *ScopeMetrics) Reset()         { *m = ScopeMetrics{} }
+func (m *ScopeMetrics) String() string { return proto.CompactTextString(m) }
+func (*ScopeMetrics) ProtoMessage()    {}
+
+// InstrumentationScope is what produced metrics.
+type InstrumentationScope struct {
+	Name    string `protobuf:"bytes,1,opt,name=name,proto3"`
+	Version string `protobuf:"bytes,2,opt,name=version,proto3"`
+}
+
+func (m *InstrumentationScope) Reset()         { *m = InstrumentationScope{} }
+func (m *InstrumentationScope) String() string { return proto.CompactTextString(m) }
+func (*InstrumentationScope) ProtoMessage()    {}
+
+// Metric is a metric with its data points. Exactly one of Gauge and Sum is
+// set, the data oneof is encoded as optional fields.
+type Metric struct {
+	Name        string `protobuf:"bytes,1,opt,name=name,proto3"`
+	Description string `protobuf:"bytes,2,opt,name=description,proto3"`
+	Unit        string `protobuf:"bytes,3,opt,name=unit,proto3"`
+	Gauge       *Gauge `protobuf:"bytes,5,opt,name=gauge"`
+	Sum         *Sum   `protobuf:"bytes,7,opt,name=sum"`
+}
+
+func (m *Metric) Reset()         { *m = Metric{} }
+func (m *Metric) String() string { return proto.CompactTextString(m) }
+func (*Metric) ProtoMessage()    {}
+
+// Gauge are the data points of a gauge.
+type Gauge struct {
+	DataPoints []*NumberDataPoint `protobuf:"bytes,1,rep,name=data_points,json=dataPoints"`
+}
+
+func (m *Gauge) Reset()         { *m = Gauge{} }
+func (m *Gauge) String() string { return proto.CompactTextString(m) }
+func (*Gauge) ProtoMessage()    {}
+
+// Sum are the data points of a sum, e.g. a counter.
+type Sum struct {
+	DataPoints             []*NumberDataPoint     `protobuf:"bytes,1,rep,name=data_points,json=dataPoints"`
+	AggregationTemporality AggregationTemporality `protobuf:"varint,2,opt,name=aggregation_temporality,json=aggregationTemporality,proto3,enum=opentelemetry.proto.metrics.v1.AggregationTemporality"`
+	IsMonotonic            bool                   `protobuf:"varint,3,opt,name=is_monotonic,json=isMonotonic,proto3"`
+}
+
+func (m *Sum) Reset()         { *m = Sum{} }
+func (m *Sum) String() string { return proto.CompactTextString(m) }
+func (*Sum) ProtoMessage()    {}
+
+// NumberDataPoint is a data point of a gauge or sum. The value oneof is
+// encoded as an optional field, so that zeros are sent.
+type NumberDataPoint struct {
+	Attributes        []*KeyValue `protobuf:"bytes,7,rep,name=attributes"`
+	StartTimeUnixNano uint64      `protobuf:"fixed64,2,opt,name=start_time_unix_nano,json=startTimeUnixNano,proto3"`
+	TimeUnixNano      uint64      `protobuf:"fixed64,3,opt,name=time_unix_nano,json=timeUnixNano,proto3"`
+	AsDouble          *float64    `protobuf:"fixed64,4,opt,name=as_double,json=asDouble"`
+}
+
+func (m *NumberDataPoint) Reset()         { *m = NumberDataPoint{} }
+func (m *NumberDataPoint) String() string { return proto.CompactTextString(m) }
+func (*NumberDataPoint) ProtoMessage()    {}
@@ -0,0 +1,88 @@
+package remotewrite
+
+import (
+	"encoding/binary"
+)
+
+// The snappy block format, see
+// https://github.com/google/snappy/blob/master/format_description.txt. Only
+// literals and copies with 2 byte offsets are emitted.
+
+const (
+	snappyTagLiteral = 0x00
+	snappyTagCopy2   = 0x02
+
+	// snappyMinMatch is the shortest match worth a copy.
+	snappyMinMatch = 4
+	// snappyMaxOffset is the maximum offset of copies with 2 byte offsets.
+	snappyMaxOffset = 1<<16 - 1
+	// snappyMaxCopy is the maximum length of a copy.
+	snappyMaxCopy = 64
+
+	snappyHashBits = 14
+)
+
+// encodeSnappy compresses src in the snappy block format, which remote
+// write requests are encoded in. Matches are found greedily with a hash table
+// of 4 byte sequences.
+func encodeSnappy(src []byte) []byte {
+	dst := make([]byte, binary.MaxVarintLen64, binary.MaxVarintLen64+len(src)+len(src)/6+16)
+	dst = dst[:binary.PutUvarint(dst, uint64(len(src)))]
+
+	var table [1 << snappyHashBits]int32
+	for i := range table {
+		table[i] = -1
+	}
+	literalStart := 0
+	for i := 0; i+snappyMinMatch <= len(src); {
+		seq := binary.LittleEndian.Uint32(src[i:])
+		h := (seq * 0x1e35a7bd) >> (32 - snappyHashBits)
+		candidate := int(table[h])
+		table[h] = int32(i)
+		if candidate < 0 || i-candidate > snappyMaxOffset || binary.LittleEndian.Uint32(src[candidate:]) != seq {
+			i++
+			continue
+		}
+		length := snappyMinMatch
+		for i+length < len(src) && src[candidate+length] == src[i+length] {
+			length++
+		}
+		dst = appendSnappyLiteral(dst, src[literalStart:i])
+		dst = appendSnappyCopy(dst, i-candidate, length)
+		i += length
+		literalStart = i
+	}
+	return appendSnappyLiteral(dst, src[literalStart:])
+}
+
+func appendSnappyLiteral(dst, lit []byte) []byte {
+	if len(lit) == 0 {
+		return dst
+	}
+	n := len(lit) - 1
+	switch {
+	case n < 60:
+		dst = append(dst, byte(n)<<2|snappyTagLiteral)
+	case n < 1<<8:
+		dst = append(dst, 60<<2|snappyTagLiteral, byte(n))
+	case n < 1<<16:
+		dst = append(dst, 61<<2|snappyTagLiteral, byte(n), byte(n>>8))
+	case n < 1<<24:
+		dst = append(dst, 62<<2|snappyTagLiteral, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		dst = append(dst, 63<<2|snappyTagLiteral, byte(n), byte(n>>8), byte(n>>16), byte(n>>24))
+	}
+	return append(dst, lit...)
+}
+
+func appendSnappyCopy(dst []byte, offset, length int) []byte {
+	for length > 0 {
+		n := length
+		if n > snappyMaxCopy {
+			n = snappyMaxCopy
+		}
+		dst = append(dst, byte(n-1)<<2|snappyTagCopy2, byte(offset), byte(offset>>8))
+		length -= n
+	}
+	return dst
+}
@@ -0,0 +1,47 @@
+package remotewrite
+
+import (
+	"github.com/golang/protobuf/proto"
+)
+
+// The messages of the Prometheus remote write protocol, with the field
+// numbers of prompb.
+
+// WriteRequest is the body of a remote write.
+type WriteRequest struct {
+	Timeseries []*TimeSeries `protobuf:"bytes,1,rep,name=timeseries"`
+}
+
+func (m *WriteRequest) Reset()         { *m = WriteRequest{} }
+func (m *WriteRequest) String() string { return proto.CompactTextString(m) }
+func (*WriteRequest) ProtoMessage()    {}
+
+// TimeSeries are the samples of a series. Labels are sorted by name.
+type TimeSeries struct {
+	Labels  []*Label  `protobuf:"bytes,1,rep,name=labels"`
+	Samples []*Sample `protobuf:"bytes,2,rep,name=samples"`
+}
+
+func (m *TimeSeries) Reset()         { *m = TimeSeries{} }
+func (m *TimeSeries) String() string { return proto.CompactTextString(m) }
+func (*TimeSeries) ProtoMessage()    {}
+
+// Label is a label of a series.
+type Label struct {
+	Name  string `protobuf:"bytes,1,opt,name=name,proto3"`
+	Value string `protobuf:"bytes,2,opt,name=value,proto3"`
+}
+
+func (m *Label) Reset()         { *m = Label{} }
+func (m *Label) String() string { return proto.CompactTextString(m) }
+func (*Label) ProtoMessage()    {}
+
+// Sample is a sample, the timestamp in milliseconds since the epoch.
+type Sample struct {
+	Value     float64 `protobuf:"fixed64,1,opt,name=value,proto3"`
+	Timestamp int64   `protobuf:"varint,2,opt,name=timestamp,proto3"`
+}
+
+func (m *Sample) Reset()         { *m = Sample{} }
+func (m *Sample) String() string { return proto.CompactTextString(m) }
+func (*Sample) ProtoMessage()    {}
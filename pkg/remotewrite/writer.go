@@ -0,0 +1,259 @@
+// Package remotewrite pushes the metrics of the collectors to a Prometheus
+// remote write endpoint.
+package remotewrite
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"golang.org/x/net/context/ctxhttp"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+const (
+	// minBackoff and maxBackoff bound the time between retries of a request.
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+)
+
+// Writer periodically gathers the metrics of a gatherer into a bounded queue
+// of write requests, and sends them in order to a remote write endpoint.
+// Requests failing with recoverable errors are retried with exponential
+// backoff. When the queue is full, the oldest request is dropped, even one
+// being retried.
+type Writer struct {
+	url            string
+	gatherer       prometheus.Gatherer
+	externalLabels map[string]string
+	client         *http.Client
+	queueSize      int
+	// minBackoff and maxBackoff bound the time between retries.
+	minBackoff, maxBackoff time.Duration
+
+	mu    sync.Mutex
+	queue []*WriteRequest
+	// queued is signaled when a request is queued.
+	queued chan struct{}
+}
+
+// NewWriter creates a writer sending to url, e.g.
+// http://prometheus:9090/api/v1/write. externalLabels are added to all
+// series not having them already. At most queueSize requests are queued.
+func NewWriter(url string, gatherer prometheus.Gatherer, externalLabels map[string]string, queueSize int) *Writer {
+	if queueSize < 1 {
+		queueSize = 1
+	}
+	return &Writer{
+		url:            url,
+		gatherer:       gatherer,
+		externalLabels: externalLabels,
+		client:         &http.Client{Timeout: 30 * time.Second},
+		queueSize:      queueSize,
+		minBackoff:     minBackoff,
+		maxBackoff:     maxBackoff,
+		queued:         make(chan struct{}, 1),
+	}
+}
+
+// Run gathers every interval and sends until stopCh is closed.
+func (w *Writer) Run(interval time.Duration, stopCh <-chan struct{}) {
+	go w.sendLoop(stopCh)
+	wait.Until(w.gather, interval, stopCh)
+}
+
+// gather gathers the metrics and queues them.
+func (w *Writer) gather() {
+	families, err := w.gatherer.Gather()
+	if err != nil {
+		// Gather returns what it could gather on errors.
+		glog.Warningf("failed to gather some metrics: %v", err)
+	}
+	req := Convert(families, w.externalLabels, time.Now())
+	if len(req.Timeseries) == 0 {
+		return
+	}
+
+	w.mu.Lock()
+	if len(w.queue) >= w.queueSize {
+		glog.Warningf("remote write queue is full, dropping the oldest request of %d series", len(w.queue[0].Timeseries))
+		w.queue[0] = nil
+		w.queue = w.queue[1:]
+	}
+	w.queue = append(w.queue, req)
+	w.mu.Unlock()
+
+	select {
+	case w.queued <- struct{}{}:
+	default:
+	}
+}
+
+// next removes the oldest request from the queue, it returns nil if the queue
+// is empty.
+func (w *Writer) next() *WriteRequest {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.queue) == 0 {
+		return nil
+	}
+	req := w.queue[0]
+	w.queue[0] = nil
+	w.queue = w.queue[1:]
+	return req
+}
+
+func (w *Writer) queueFull() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.queue) >= w.queueSize
+}
+
+func (w *Writer) sendLoop(stopCh <-chan struct{}) {
+	for {
+		select {
+		case <-stopCh:
+			return
+		case <-w.queued:
+		}
+		for req := w.next(); req != nil; req = w.next() {
+			if !w.sendWithRetries(req, stopCh) {
+				return
+			}
+		}
+	}
+}
+
+// sendWithRetries sends req until it succeeds, fails with an unrecoverable
+// error or the queue fills up behind it. It returns false if stopCh was
+// closed.
+func (w *Writer) sendWithRetries(req *WriteRequest, stopCh <-chan struct{}) bool {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		glog.Errorf("failed to marshal remote write request: %v", err)
+		return true
+	}
+	body = encodeSnappy(body)
+
+	backoff := w.minBackoff
+	for {
+		err := w.send(body)
+		if err == nil {
+			return true
+		}
+		if _, ok := err.(recoverableError); !ok {
+			glog.Errorf("failed to write %d series to %s, dropping them: %v", len(req.Timeseries), w.url, err)
+			return true
+		}
+		glog.Warningf("failed to write %d series to %s, retrying in %v: %v", len(req.Timeseries), w.url, backoff, err)
+		select {
+		case <-stopCh:
+			return false
+		case <-time.After(backoff):
+		}
+		if w.queueFull() {
+			glog.Warningf("remote write queue is full, dropping %d series being retried", len(req.Timeseries))
+			return true
+		}
+		backoff *= 2
+		if backoff > w.maxBackoff {
+			backoff = w.maxBackoff
+		}
+	}
+}
+
+// recoverableError is an error after which a request may succeed when
+// retried.
+type recoverableError struct {
+	error
+}
+
+// send sends a snappy compressed write request once.
+func (w *Writer) send(body []byte) error {
+	httpReq, err := http.NewRequest("POST", w.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Encoding", "snappy")
+	httpReq.Header.Set("Content-Type", "application/x-protobuf")
+	httpReq.Header.Set("User-Agent", "kubelet-exporter")
+	httpReq.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	resp, err := ctxhttp.Do(context.Background(), w.client, httpReq)
+	if err != nil {
+		return recoverableError{err}
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 == 2 {
+		io.Copy(ioutil.Discard, resp.Body)
+		return nil
+	}
+	msg, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 1024))
+	err = fmt.Errorf("unexpected status %s: %s", resp.Status, strings.TrimSpace(string(msg)))
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return recoverableError{err}
+	}
+	return err
+}
+
+// Convert converts metric families to a write request. Counters, gauges and
+// untyped metrics are converted to a series each, summaries and histograms
+// are skipped. externalLabels are added to series not having them already.
+// Samples without timestamp are at now.
+func Convert(families []*dto.MetricFamily, externalLabels map[string]string, now time.Time) *WriteRequest {
+	req := &WriteRequest{}
+	nowMs := now.UnixNano() / int64(time.Millisecond)
+	for _, family := range families {
+		for _, m := range family.Metric {
+			var value float64
+			switch family.GetType() {
+			case dto.MetricType_COUNTER:
+				value = m.GetCounter().GetValue()
+			case dto.MetricType_GAUGE:
+				value = m.GetGauge().GetValue()
+			case dto.MetricType_UNTYPED:
+				value = m.GetUntyped().GetValue()
+			default:
+				continue
+			}
+
+			labels := make([]*Label, 0, len(m.Label)+len(externalLabels)+1)
+			labels = append(labels, &Label{Name: "__name__", Value: family.GetName()})
+			seen := map[string]bool{}
+			for _, l := range m.Label {
+				// Empty labels are the same as missing ones.
+				if l.GetValue() == "" {
+					continue
+				}
+				labels = append(labels, &Label{Name: l.GetName(), Value: l.GetValue()})
+				seen[l.GetName()] = true
+			}
+			for name, value := range externalLabels {
+				if !seen[name] {
+					labels = append(labels, &Label{Name: name, Value: value})
+				}
+			}
+			sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+
+			timestamp := nowMs
+			if m.TimestampMs != nil {
+				timestamp = m.GetTimestampMs()
+			}
+			req.Timeseries = append(req.Timeseries, &TimeSeries{
+				Labels:  labels,
+				Samples: []*Sample{{Value: value, Timestamp: timestamp}},
+			})
+		}
+	}
+	return req
+}
@@ -0,0 +1,379 @@
+package remotewrite
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// decodeSnappy decodes a snappy block, see
+// https://github.com/google/snappy/blob/master/format_description.txt.
+func decodeSnappy(src []byte) ([]byte, error) {
+	n, read := binary.Uvarint(src)
+	if read <= 0 {
+		return nil, fmt.Errorf("invalid length")
+	}
+	src = src[read:]
+	dst := make([]byte, 0, n)
+	for len(src) > 0 {
+		tag := src[0]
+		switch tag & 0x03 {
+		case snappyTagLiteral:
+			length := int(tag >> 2)
+			src = src[1:]
+			if length >= 60 {
+				bytes := length - 59
+				if len(src) < bytes {
+					return nil, fmt.Errorf("truncated literal length")
+				}
+				length = 0
+				for i := bytes - 1; i >= 0; i-- {
+					length = length<<8 | int(src[i])
+				}
+				src = src[bytes:]
+			}
+			length++
+			if len(src) < length {
+				return nil, fmt.Errorf("truncated literal")
+			}
+			dst = append(dst, src[:length]...)
+			src = src[length:]
+		case 0x01:
+			if len(src) < 2 {
+				return nil, fmt.Errorf("truncated copy")
+			}
+			length, offset := int(tag>>2&0x07)+4, int(tag>>5)<<8|int(src[1])
+			if dst, read = appendCopy(dst, offset, length); read < 0 {
+				return nil, fmt.Errorf("invalid copy offset %d", offset)
+			}
+			src = src[2:]
+		case snappyTagCopy2:
+			if len(src) < 3 {
+				return nil, fmt.Errorf("truncated copy")
+			}
+			length, offset := int(tag>>2)+1, int(binary.LittleEndian.Uint16(src[1:]))
+			if dst, read = appendCopy(dst, offset, length); read < 0 {
+				return nil, fmt.Errorf("invalid copy offset %d", offset)
+			}
+			src = src[3:]
+		default:
+			if len(src) < 5 {
+				return nil, fmt.Errorf("truncated copy")
+			}
+			length, offset := int(tag>>2)+1, int(binary.LittleEndian.Uint32(src[1:]))
+			if dst, read = appendCopy(dst, offset, length); read < 0 {
+				return nil, fmt.Errorf("invalid copy offset %d", offset)
+			}
+			src = src[5:]
+		}
+	}
+	if uint64(len(dst)) != n {
+		return nil, fmt.Errorf("decoded %d bytes, want %d", len(dst), n)
+	}
+	return dst, nil
+}
+
+// appendCopy appends length bytes from offset bytes back in dst, byte by byte
+// as copies may overlap. It returns -1 if offset is invalid.
+func appendCopy(dst []byte, offset, length int) ([]byte, int) {
+	if offset <= 0 || offset > len(dst) {
+		return dst, -1
+	}
+	for i := 0; i < length; i++ {
+		dst = append(dst, dst[len(dst)-offset])
+	}
+	return dst, length
+}
+
+// snappyVectors are encoded by the reference implementation,
+// github.com/golang/snappy v0.0.1, so that decodeSnappy and the round trip
+// through it are checked against it rather than only against encodeSnappy.
+var snappyVectors = []struct {
+	name             string
+	decoded, encoded string
+}{
+	{name: "empty", decoded: "", encoded: "\x00"},
+	{name: "short literal", decoded: "abc", encoded: "\x03\babc"},
+	{
+		name:    "literal with a one byte length",
+		decoded: "0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ!?",
+		encoded: "@\xf0?0123456789abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ!?",
+	},
+	{
+		name:    "literal with a two byte length",
+		decoded: "xvlbzgbaicmrajwwhthctcuaxhxkqfdafplsjfbcxoeffrswxpldnjobcsnvlgtemapezqleqyhyzrywjjpjzpfrfegmotafethsbzrjxawnwekrbemfdzdcekxbakjqzlcttmttcoanatyyinkarekjyixjrscctnswynsgrussvmaozfzbsbojifqgzsnwtksmvoiglopbuopedkupdomervjarzlntxyeucwksxbgyraombtvksjfjzalbtzsymgeudtrzqmdqiycohghovgseycjpjhynufnjjhhjuvr",
+		encoded: "\xac\x02\xf4+\x01xvlbzgbaicmrajwwhthctcuaxhxkqfdafplsjfbcxoeffrswxpldnjobcsnvlgtemapezqleqyhyzrywjjpjzpfrfegmotafethsbzrjxawnwekrbemfdzdcekxbakjqzlcttmttcoanatyyinkarekjyixjrscctnswynsgrussvmaozfzbsbojifqgzsnwtksmvoiglopbuopedkupdomervjarzlntxyeucwksxbgyraombtvksjfjzalbtzsymgeudtrzqmdqiycohghovgseycjpjhynufnjjhhjuvr",
+	},
+	{
+		name:    "overlapping copy with a two byte offset",
+		decoded: strings.Repeat("a", 100),
+		encoded: "d\x00a\xfe\x01\x00\x8a\x01\x00",
+	},
+	{
+		name:    "copies with one and two byte offsets",
+		decoded: `kubelet_volume_stats_used_bytes{namespace="team-a",persistentvolumeclaim="data-0"} 100 kubelet_volume_stats_used_bytes{namespace="team-a",persistentvolumeclaim="data-1"} 200`,
+		encoded: "\xad\x01\xf0<kubelet_volume_stats_used_bytes{namespace=\"team-a\",persistent\t5Pclaim=\"data-0\"} 100 k\xfeW\x006W\x00\x181\"} 200",
+	},
+}
+
+func TestDecodeSnappyReferenceVectors(t *testing.T) {
+	for _, v := range snappyVectors {
+		decoded, err := decodeSnappy([]byte(v.encoded))
+		if err != nil {
+			t.Errorf("%s: failed to decode: %v", v.name, err)
+			continue
+		}
+		if string(decoded) != v.decoded {
+			t.Errorf("%s: got %q decoded, want %q", v.name, decoded, v.decoded)
+		}
+	}
+}
+
+func TestSnappyRoundTrip(t *testing.T) {
+	var repeated []byte
+	for i := 0; len(repeated) < 100000; i++ {
+		repeated = append(repeated, fmt.Sprintf("kubelet_volume_stats_used_bytes{persistentvolumeclaim=\"data-%d\"} ", i%97)...)
+	}
+	srcs := [][]byte{repeated}
+	for _, v := range snappyVectors {
+		srcs = append(srcs, []byte(v.decoded))
+	}
+	for _, src := range srcs {
+		encoded := encodeSnappy(src)
+		decoded, err := decodeSnappy(encoded)
+		if err != nil {
+			t.Errorf("failed to decode %d bytes: %v", len(src), err)
+			continue
+		}
+		if string(decoded) != string(src) {
+			t.Errorf("got %d bytes decoded, want the %d bytes encoded", len(decoded), len(src))
+		}
+		if len(src) > 1000 && len(encoded) > len(src)/2 {
+			t.Errorf("got %d bytes encoded from %d repetitive bytes", len(encoded), len(src))
+		}
+	}
+}
+
+// receiver is a remote write endpoint sending the requests it receives to
+// requests, answering with the status returned by status for the index of
+// the request.
+type receiver struct {
+	t        *testing.T
+	status   func(i int) int
+	requests chan *WriteRequest
+
+	mu sync.Mutex
+	n  int
+}
+
+func newReceiver(t *testing.T, status func(i int) int) *receiver {
+	return &receiver{t: t, status: status, requests: make(chan *WriteRequest, 100)}
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	for header, want := range map[string]string{
+		"Content-Encoding":                  "snappy",
+		"Content-Type":                      "application/x-protobuf",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	} {
+		if got := req.Header.Get(header); got != want {
+			r.t.Errorf("got %s %q, want %q", header, got, want)
+		}
+	}
+	body, err := ioutil.ReadAll(req.Body)
+	if err != nil {
+		r.t.Error(err)
+	}
+	body, err = decodeSnappy(body)
+	if err != nil {
+		r.t.Errorf("failed to decode the request body: %v", err)
+	}
+	write := &WriteRequest{}
+	if err := proto.Unmarshal(body, write); err != nil {
+		r.t.Errorf("failed to unmarshal the write request: %v", err)
+	}
+	r.mu.Lock()
+	i := r.n
+	r.n++
+	r.mu.Unlock()
+	r.requests <- write
+	if status := r.status(i); status != http.StatusOK {
+		http.Error(w, "rejected", status)
+	}
+}
+
+// receive returns the next request, failing if none is received in time.
+func (r *receiver) receive() *WriteRequest {
+	select {
+	case req := <-r.requests:
+		return req
+	case <-time.After(5 * time.Second):
+		r.t.Fatal("timed out waiting for a request")
+		return nil
+	}
+}
+
+// expectNone fails if a request is received within a while.
+func (r *receiver) expectNone() {
+	select {
+	case req := <-r.requests:
+		r.t.Errorf("got unexpected request %v", req)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+// seriesLabels returns the labels of the series of a request as maps,
+// failing if they aren't sorted by name.
+func seriesLabels(t *testing.T, req *WriteRequest) []map[string]string {
+	var series []map[string]string
+	for _, ts := range req.Timeseries {
+		labels := map[string]string{}
+		for i, l := range ts.Labels {
+			if i > 0 && ts.Labels[i-1].Name >= l.Name {
+				t.Errorf("got labels %v not sorted by name", ts.Labels)
+			}
+			labels[l.Name] = l.Value
+		}
+		series = append(series, labels)
+	}
+	return series
+}
+
+// newTestWriter creates a writer of gauge to url, retrying quickly.
+func newTestWriter(url string, gauge prometheus.Gauge, queueSize int) *Writer {
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(gauge)
+	w := NewWriter(url, registry, nil, queueSize)
+	w.minBackoff, w.maxBackoff = time.Millisecond, 2*time.Millisecond
+	return w
+}
+
+func newTestGauge() prometheus.Gauge {
+	return prometheus.NewGauge(prometheus.GaugeOpts{Name: "kubelet_volume_stats_used_bytes", Help: "Number of used bytes in the volume"})
+}
+
+// sampleValue returns the value of the only sample of a request.
+func sampleValue(t *testing.T, req *WriteRequest) float64 {
+	if len(req.Timeseries) != 1 || len(req.Timeseries[0].Samples) != 1 {
+		t.Fatalf("got request %v, want one sample", req)
+	}
+	return req.Timeseries[0].Samples[0].Value
+}
+
+func TestWriterLabels(t *testing.T) {
+	r := newReceiver(t, func(int) int { return http.StatusOK })
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	used := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kubelet_volume_stats_used_bytes",
+		Help: "Number of used bytes in the volume",
+	}, []string{"namespace", "persistentvolumeclaim", "cluster"})
+	used.WithLabelValues("team-a", "data-0", "").Set(100)
+	used.WithLabelValues("team-a", "data-1", "own").Set(200)
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(used)
+	w := NewWriter(server.URL, registry, map[string]string{"cluster": "external", "region": "eu"}, 1)
+
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go w.sendLoop(stopCh)
+	before := time.Now().UnixNano() / int64(time.Millisecond)
+	w.gather()
+	req := r.receive()
+
+	want := []map[string]string{
+		// The empty label is missing, so the external label is added.
+		{"__name__": "kubelet_volume_stats_used_bytes", "cluster": "external", "namespace": "team-a", "persistentvolumeclaim": "data-0", "region": "eu"},
+		// The label of the series takes precedence.
+		{"__name__": "kubelet_volume_stats_used_bytes", "cluster": "own", "namespace": "team-a", "persistentvolumeclaim": "data-1", "region": "eu"},
+	}
+	if got := seriesLabels(t, req); !reflect.DeepEqual(got, want) {
+		t.Errorf("got series %v, want %v", got, want)
+	}
+	for i, value := range []float64{100, 200} {
+		samples := req.Timeseries[i].Samples
+		if len(samples) != 1 || samples[0].Value != value || samples[0].Timestamp < before {
+			t.Errorf("got samples %v of series %d, want %v at the gather time", samples, i, value)
+		}
+	}
+}
+
+func TestWriterRetriesRecoverableErrors(t *testing.T) {
+	statuses := []int{http.StatusServiceUnavailable, http.StatusTooManyRequests, http.StatusOK}
+	r := newReceiver(t, func(i int) int { return statuses[i] })
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	gauge := newTestGauge()
+	w := newTestWriter(server.URL, gauge, 1)
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go w.sendLoop(stopCh)
+	gauge.Set(1)
+	w.gather()
+	for i := range statuses {
+		if value := sampleValue(t, r.receive()); value != 1 {
+			t.Errorf("got value %v in attempt %d, want the same request", value, i)
+		}
+	}
+	r.expectNone()
+}
+
+func TestWriterDropsUnrecoverableErrors(t *testing.T) {
+	r := newReceiver(t, func(int) int { return http.StatusBadRequest })
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	gauge := newTestGauge()
+	w := newTestWriter(server.URL, gauge, 2)
+	gauge.Set(1)
+	w.gather()
+	gauge.Set(2)
+	w.gather()
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go w.sendLoop(stopCh)
+	w.queued <- struct{}{}
+
+	// Each request is sent once, and the next one after it.
+	for _, want := range []float64{1, 2} {
+		if value := sampleValue(t, r.receive()); value != want {
+			t.Errorf("got value %v, want %v", value, want)
+		}
+	}
+	r.expectNone()
+}
+
+func TestWriterDropsOldestWhenFull(t *testing.T) {
+	r := newReceiver(t, func(int) int { return http.StatusOK })
+	server := httptest.NewServer(r)
+	defer server.Close()
+
+	gauge := newTestGauge()
+	w := newTestWriter(server.URL, gauge, 2)
+	for i := 1; i <= 3; i++ {
+		gauge.Set(float64(i))
+		w.gather()
+	}
+	stopCh := make(chan struct{})
+	defer close(stopCh)
+	go w.sendLoop(stopCh)
+
+	for _, want := range []float64{2, 3} {
+		if value := sampleValue(t, r.receive()); value != want {
+			t.Errorf("got value %v, want %v", value, want)
+		}
+	}
+	r.expectNone()
+}
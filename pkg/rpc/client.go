@@ -0,0 +1,168 @@
+// Package rpc implements the subset of gRPC needed to call unary methods of
+// local plugins, e.g. CSI node plugins and container runtimes, over unix
+// sockets. Messages are encoded with the golang/protobuf reflection based
+// encoding, so they only need struct tags.
+package rpc
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/http2"
+)
+
+// maxMessageSize is the maximum size of a response message.
+const maxMessageSize = 16 << 20
+
+// Code is a gRPC status code.
+type Code int
+
+// The status codes the callers handle.
+const (
+	OK            Code = 0
+	NotFound      Code = 5
+	Unimplemented Code = 12
+	Unavailable   Code = 14
+)
+
+// Error is a non-OK status returned by a method.
+type Error struct {
+	Code    Code
+	Message string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("rpc error: code = %d desc = %s", e.Code, e.Message)
+}
+
+// ErrorCode returns the status code of err, OK for nil, Unavailable for
+// errors which are no status, e.g. connection errors.
+func ErrorCode(err error) Code {
+	if err == nil {
+		return OK
+	}
+	if e, ok := err.(*Error); ok {
+		return e.Code
+	}
+	return Unavailable
+}
+
+// Client calls methods of the server listening on a unix socket.
+type Client struct {
+	socket string
+	client *http.Client
+}
+
+// NewClient creates a client of the server listening on socket. Connections
+// are established lazily.
+func NewClient(socket string) *Client {
+	transport := &http2.Transport{
+		// gRPC over unix sockets is cleartext HTTP/2.
+		AllowHTTP: true,
+		DialTLS: func(network, addr string, cfg *tls.Config) (net.Conn, error) {
+			return net.Dial("unix", socket)
+		},
+	}
+	return &Client{socket: socket, client: &http.Client{Transport: transport}}
+}
+
+// Socket returns the socket the client connects to.
+func (c *Client) Socket() string {
+	return c.socket
+}
+
+// Invoke calls method, e.g. /csi.v1.Identity/GetPluginInfo, with req and
+// decodes the reply into resp.
+func (c *Client) Invoke(ctx context.Context, method string, req, resp proto.Message) error {
+	body, err := proto.Marshal(req)
+	if err != nil {
+		return err
+	}
+	httpReq, err := http.NewRequest("POST", "http://localhost"+method, bytes.NewReader(encodeFrame(body)))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("Content-Type", "application/grpc+proto")
+	httpReq.Header.Set("TE", "trailers")
+	httpResp, err := c.client.Do(httpReq.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer httpResp.Body.Close()
+	if httpResp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: unexpected HTTP status %s", method, httpResp.Status)
+	}
+	data, err := ioutil.ReadAll(io.LimitReader(httpResp.Body, maxMessageSize+5))
+	if err != nil {
+		return err
+	}
+	// The status is in the headers of trailers-only responses.
+	if err := statusError(httpResp.Header); err != nil {
+		return err
+	}
+	if err := statusError(httpResp.Trailer); err != nil {
+		return err
+	}
+	msg, err := decodeFrame(data)
+	if err != nil {
+		return fmt.Errorf("%s: %v", method, err)
+	}
+	return proto.Unmarshal(msg, resp)
+}
+
+// statusError returns the error of the status in header, nil if there is no
+// status or it is OK.
+func statusError(header http.Header) error {
+	status := header.Get("Grpc-Status")
+	if status == "" {
+		return nil
+	}
+	code, err := strconv.Atoi(status)
+	if err != nil {
+		return fmt.Errorf("invalid grpc-status %q", status)
+	}
+	if Code(code) == OK {
+		return nil
+	}
+	message, err := url.PathUnescape(header.Get("Grpc-Message"))
+	if err != nil {
+		message = header.Get("Grpc-Message")
+	}
+	return &Error{Code: Code(code), Message: message}
+}
+
+// encodeFrame prefixes msg with the uncompressed flag and its length.
+func encodeFrame(msg []byte) []byte {
+	frame := make([]byte, 5+len(msg))
+	binary.BigEndian.PutUint32(frame[1:5], uint32(len(msg)))
+	copy(frame[5:], msg)
+	return frame
+}
+
+// decodeFrame returns the message of the single frame in data.
+func decodeFrame(data []byte) ([]byte, error) {
+	if len(data) < 5 {
+		return nil, fmt.Errorf("short message frame of %d bytes", len(data))
+	}
+	if data[0] != 0 {
+		return nil, fmt.Errorf("compressed messages are not supported")
+	}
+	n := binary.BigEndian.Uint32(data[1:5])
+	if n > maxMessageSize {
+		return nil, fmt.Errorf("message of %d bytes exceeds the maximum of %d", n, maxMessageSize)
+	}
+	if uint32(len(data)-5) < n {
+		return nil, fmt.Errorf("truncated message frame, %d of %d bytes", len(data)-5, n)
+	}
+	return data[5 : 5+n], nil
+}
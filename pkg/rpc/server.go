@@ -0,0 +1,87 @@
+package rpc
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/golang/protobuf/proto"
+	"golang.org/x/net/http2"
+)
+
+// Handler handles a call of a method. It decodes the request with unmarshal.
+// An *Error is returned to the caller as is, other errors with the Unknown
+// code.
+type Handler func(ctx context.Context, unmarshal func(proto.Message) error) (proto.Message, error)
+
+// Server serves unary methods, to fake plugins in tests.
+type Server struct {
+	// Methods are the handlers by method, e.g. /csi.v1.Identity/GetPluginInfo.
+	Methods map[string]Handler
+}
+
+// Serve serves connections accepted from l, e.g. a unix socket listener,
+// until it fails.
+func (s *Server) Serve(l net.Listener) error {
+	h2s := &http2.Server{}
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			return err
+		}
+		go h2s.ServeConn(conn, &http2.ServeConnOpts{Handler: s})
+	}
+}
+
+// ServeHTTP implements the http.Handler interface.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/grpc+proto")
+	handler, ok := s.Methods[r.URL.Path]
+	if !ok {
+		writeStatus(w.Header(), &Error{Code: Unimplemented, Message: "unknown method " + r.URL.Path})
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return
+	}
+	resp, err := handler(r.Context(), func(req proto.Message) error {
+		msg, err := decodeFrame(data)
+		if err != nil {
+			return err
+		}
+		return proto.Unmarshal(msg, req)
+	})
+	if err == nil {
+		var body []byte
+		if body, err = proto.Marshal(resp); err == nil {
+			w.Header().Set("Trailer", "Grpc-Status, Grpc-Message")
+			w.WriteHeader(http.StatusOK)
+			w.Write(encodeFrame(body))
+			writeStatus(w.Header(), nil)
+			return
+		}
+	}
+	writeStatus(w.Header(), err)
+	w.WriteHeader(http.StatusOK)
+}
+
+// writeStatus sets the status of err in header.
+func writeStatus(header http.Header, err error) {
+	if err == nil {
+		header.Set("Grpc-Status", "0")
+		header.Set("Grpc-Message", "")
+		return
+	}
+	e, ok := err.(*Error)
+	if !ok {
+		// Unknown
+		e = &Error{Code: 2, Message: err.Error()}
+	}
+	header.Set("Grpc-Status", strconv.Itoa(int(e.Code)))
+	header.Set("Grpc-Message", url.PathEscape(e.Message))
+}
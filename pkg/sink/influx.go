@@ -0,0 +1,97 @@
+package sink
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+var (
+	influxMeasurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "\n", `\n`)
+	influxTagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `, "\n", `\n`)
+)
+
+// Influx encodes metrics in the InfluxDB line protocol, as the Prometheus
+// input of Telegraf does: each series is a point of the measurement named
+// after its family, with labels as tags, and a counter, gauge or value field
+// by the type of the family. Summaries, histograms and samples which are not
+// finite are skipped.
+type Influx struct{}
+
+var _ Encoder = Influx{}
+
+// ContentType implements the Encoder interface.
+func (Influx) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+// Encode implements the Encoder interface.
+func (Influx) Encode(w io.Writer, families []*dto.MetricFamily, now time.Time) error {
+	bw := bufio.NewWriter(w)
+	for _, family := range families {
+		measurement := influxMeasurementEscaper.Replace(family.GetName())
+		for _, m := range family.Metric {
+			field, value, ok := sampleValue(family.GetType(), m)
+			if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+			bw.WriteString(measurement)
+			for _, l := range sortedLabels(m) {
+				bw.WriteByte(',')
+				bw.WriteString(influxTagEscaper.Replace(l.GetName()))
+				bw.WriteByte('=')
+				bw.WriteString(influxTagEscaper.Replace(l.GetValue()))
+			}
+			bw.WriteByte(' ')
+			bw.WriteString(field)
+			bw.WriteByte('=')
+			bw.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+			bw.WriteByte(' ')
+			bw.WriteString(strconv.FormatInt(sampleTime(m, now).UnixNano(), 10))
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+// sampleValue returns the value of a counter, gauge or untyped metric, and
+// the name of its type. ok is false for other types.
+func sampleValue(t dto.MetricType, m *dto.Metric) (typ string, value float64, ok bool) {
+	switch t {
+	case dto.MetricType_COUNTER:
+		return "counter", m.GetCounter().GetValue(), true
+	case dto.MetricType_GAUGE:
+		return "gauge", m.GetGauge().GetValue(), true
+	case dto.MetricType_UNTYPED:
+		return "value", m.GetUntyped().GetValue(), true
+	default:
+		return "", 0, false
+	}
+}
+
+// sampleTime returns the timestamp of a sample, or now if it has none.
+func sampleTime(m *dto.Metric, now time.Time) time.Time {
+	if m.TimestampMs != nil {
+		return time.Unix(0, m.GetTimestampMs()*int64(time.Millisecond))
+	}
+	return now
+}
+
+// sortedLabels returns the non-empty labels of a metric sorted by name.
+func sortedLabels(m *dto.Metric) []*dto.LabelPair {
+	labels := make([]*dto.LabelPair, 0, len(m.Label))
+	for _, l := range m.Label {
+		// Empty labels are the same as missing ones.
+		if l.GetValue() != "" {
+			labels = append(labels, l)
+		}
+	}
+	sort.Slice(labels, func(i, j int) bool { return labels[i].GetName() < labels[j].GetName() })
+	return labels
+}
@@ -0,0 +1,46 @@
+package sink
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestInfluxEncode(t *testing.T) {
+	timestamped := newMetric(dto.MetricType_GAUGE, 400, "namespace", "team-a")
+	timestamped.TimestampMs = proto.Int64(1500000000123)
+	families := []*dto.MetricFamily{
+		newFamily("kubelet_volume_stats_used_bytes", dto.MetricType_GAUGE,
+			// Sorted by name, the empty label is left out.
+			newMetric(dto.MetricType_GAUGE, 1.5, "persistentvolumeclaim", "data", "namespace", "team-a", "source", ""),
+			timestamped,
+			newMetric(dto.MetricType_GAUGE, math.NaN(), "namespace", "team-b"),
+			newMetric(dto.MetricType_GAUGE, math.Inf(1), "namespace", "team-b"),
+		),
+		newFamily("kubelet_volume_nfs_operations_total", dto.MetricType_COUNTER,
+			newMetric(dto.MetricType_COUNTER, 1e21, "operation", "READ"),
+		),
+		newFamily("untyped", dto.MetricType_UNTYPED, newMetric(dto.MetricType_UNTYPED, -3)),
+		newFamily("summary", dto.MetricType_SUMMARY, newMetric(dto.MetricType_SUMMARY, 1)),
+		newFamily("odd, name", dto.MetricType_GAUGE,
+			newMetric(dto.MetricType_GAUGE, 0, "odd key", "a,b=c d\ne"),
+		),
+	}
+	want := `kubelet_volume_stats_used_bytes,namespace=team-a,persistentvolumeclaim=data gauge=1.5 1000000000
+kubelet_volume_stats_used_bytes,namespace=team-a gauge=400 1500000000123000000
+kubelet_volume_nfs_operations_total,operation=READ counter=1e+21 1000000000
+untyped value=-3 1000000000
+odd\,\ name,odd\ key=a\,b\=c\ d\ne gauge=0 1000000000
+`
+	var buf bytes.Buffer
+	if err := (Influx{}).Encode(&buf, families, time.Unix(1, 0)); err != nil {
+		t.Fatal(err)
+	}
+	if got := buf.String(); got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,122 @@
+// Package sink outputs the metrics of the collectors in formats other than
+// the Prometheus exposition formats. Encoders encode gathered metric
+// families, which are served over HTTP or pushed over UDP.
+package sink
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/golang/glog"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"k8s.io/apimachinery/pkg/util/wait"
+)
+
+// maxDatagramSize is the maximum size of pushed datagrams, which fits into
+// the MTU of common networks.
+const maxDatagramSize = 1432
+
+// Encoder encodes metric families as lines. Samples without timestamp are at
+// now.
+type Encoder interface {
+	// ContentType is the content type of the encoded metrics.
+	ContentType() string
+	Encode(w io.Writer, families []*dto.MetricFamily, now time.Time) error
+}
+
+// Handler serves the metrics of gatherer encoded by encoder.
+func Handler(gatherer prometheus.Gatherer, encoder Encoder) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		families, err := gatherer.Gather()
+		if err != nil {
+			http.Error(w, fmt.Sprintf("An error has occurred during metrics gathering:\n\n%s", err), http.StatusInternalServerError)
+			return
+		}
+		var buf bytes.Buffer
+		if err := encoder.Encode(&buf, families, time.Now()); err != nil {
+			http.Error(w, fmt.Sprintf("An error has occurred during metrics encoding:\n\n%s", err), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", encoder.ContentType())
+		w.Write(buf.Bytes())
+	})
+}
+
+// UDPPusher pushes the metrics of a gatherer encoded by an encoder to a UDP
+// endpoint, in datagrams of whole lines.
+type UDPPusher struct {
+	address  string
+	gatherer prometheus.Gatherer
+	encoder  Encoder
+}
+
+// NewUDPPusher creates a pusher pushing to address, e.g. localhost:8125.
+func NewUDPPusher(address string, gatherer prometheus.Gatherer, encoder Encoder) *UDPPusher {
+	return &UDPPusher{address: address, gatherer: gatherer, encoder: encoder}
+}
+
+// Run pushes every interval until stopCh is closed.
+func (p *UDPPusher) Run(interval time.Duration, stopCh <-chan struct{}) {
+	wait.Until(func() {
+		if err := p.Push(); err != nil {
+			glog.Errorf("failed to push metrics to %s: %v", p.address, err)
+		}
+	}, interval, stopCh)
+}
+
+// Push gathers the metrics and pushes them once.
+func (p *UDPPusher) Push() error {
+	families, err := p.gatherer.Gather()
+	if err != nil {
+		// Gather returns what it could gather on errors.
+		glog.Warningf("failed to gather some metrics: %v", err)
+	}
+	var buf bytes.Buffer
+	if err := p.encoder.Encode(&buf, families, time.Now()); err != nil {
+		return err
+	}
+	// The address is resolved on every push, it may be a service whose IP
+	// changes.
+	conn, err := net.Dial("udp", p.address)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	for _, datagram := range splitLines(buf.Bytes(), maxDatagramSize) {
+		if _, err := conn.Write(datagram); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// splitLines splits newline terminated lines into chunks of whole lines of at
+// most size bytes without the trailing newline. Longer lines are chunks of
+// their own.
+func splitLines(b []byte, size int) [][]byte {
+	var chunks [][]byte
+	for len(b) > 0 {
+		end := 0
+		for end < len(b) {
+			i := bytes.IndexByte(b[end:], '\n')
+			if i < 0 {
+				i = len(b) - end
+			}
+			if end > 0 && end+i > size {
+				break
+			}
+			end += i + 1
+		}
+		if end > len(b) {
+			end = len(b)
+		}
+		chunks = append(chunks, bytes.TrimSuffix(b[:end], []byte("\n")))
+		b = b[end:]
+	}
+	return chunks
+}
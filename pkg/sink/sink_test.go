@@ -0,0 +1,107 @@
+package sink
+
+import (
+	"fmt"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// newFamily creates a family of metrics of type typ.
+func newFamily(name string, typ dto.MetricType, metrics ...*dto.Metric) *dto.MetricFamily {
+	return &dto.MetricFamily{Name: proto.String(name), Type: typ.Enum(), Metric: metrics}
+}
+
+// newMetric creates a metric of type typ with value v and labels of name
+// value pairs.
+func newMetric(typ dto.MetricType, v float64, labels ...string) *dto.Metric {
+	m := &dto.Metric{}
+	for i := 0; i < len(labels); i += 2 {
+		m.Label = append(m.Label, &dto.LabelPair{Name: proto.String(labels[i]), Value: proto.String(labels[i+1])})
+	}
+	switch typ {
+	case dto.MetricType_COUNTER:
+		m.Counter = &dto.Counter{Value: proto.Float64(v)}
+	case dto.MetricType_GAUGE:
+		m.Gauge = &dto.Gauge{Value: proto.Float64(v)}
+	case dto.MetricType_UNTYPED:
+		m.Untyped = &dto.Untyped{Value: proto.Float64(v)}
+	case dto.MetricType_SUMMARY:
+		m.Summary = &dto.Summary{SampleCount: proto.Uint64(1), SampleSum: proto.Float64(v)}
+	}
+	return m
+}
+
+func TestSplitLines(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines string
+		want  []string
+	}{
+		{name: "empty", lines: ""},
+		{name: "lines fitting", lines: "a\nb\n", want: []string{"a\nb"}},
+		{name: "lines filling a chunk", lines: "aaaa\nbbbbb\n", want: []string{"aaaa\nbbbbb"}},
+		{name: "lines exceeding a chunk", lines: "aaaa\nbbbb\ncc\n", want: []string{"aaaa\nbbbb", "cc"}},
+		{name: "first line longer than a chunk", lines: "aaaaaaaaaaaaaaa\nb\n", want: []string{"aaaaaaaaaaaaaaa", "b"}},
+		{name: "later line longer than a chunk", lines: "a\nbbbbbbbbbbbbbbb\nc\n", want: []string{"a", "bbbbbbbbbbbbbbb", "c"}},
+		{name: "last line without newline", lines: "a\nb", want: []string{"a\nb"}},
+		{name: "long last line without newline", lines: "a\nbbbbbbbbbbbbbbb", want: []string{"a", "bbbbbbbbbbbbbbb"}},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, chunk := range splitLines([]byte(test.lines), 10) {
+			got = append(got, string(chunk))
+		}
+		if !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: got chunks %q, want %q", test.name, got, test.want)
+		}
+	}
+}
+
+func TestUDPPusherPush(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	used := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "kubelet_volume_stats_used_bytes",
+		Help: "Number of used bytes in the volume",
+	}, []string{"namespace", "persistentvolumeclaim"})
+	// More lines than fit into a datagram.
+	var want []string
+	for i := 0; i < 50; i++ {
+		pvc := fmt.Sprintf("data-%s-%02d", strings.Repeat("x", 40), i)
+		used.WithLabelValues("team-a", pvc).Set(float64(i))
+		want = append(want, fmt.Sprintf("kubelet_volume_stats_used_bytes:%d|g|#namespace:team-a,persistentvolumeclaim:%s", i, pvc))
+	}
+	registry := prometheus.NewRegistry()
+	registry.MustRegister(used)
+	if err := NewUDPPusher(conn.LocalAddr().String(), registry, NewStatsD()).Push(); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	buf := make([]byte, 65536)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for datagrams := 0; len(got) < len(want); datagrams++ {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatalf("got %d of %d lines in %d datagrams: %v", len(got), len(want), datagrams, err)
+		}
+		if n > maxDatagramSize {
+			t.Errorf("got datagram of %d bytes, want at most %d", n, maxDatagramSize)
+		}
+		got = append(got, strings.Split(string(buf[:n]), "\n")...)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got lines\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
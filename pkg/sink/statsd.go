@@ -0,0 +1,105 @@
+package sink
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+var statsDTagEscaper = strings.NewReplacer(",", "_", "|", "_", ":", "_", "\n", "_")
+
+// StatsD encodes metrics in the StatsD protocol with the tags extension of
+// DogStatsD, e.g. "kubelet_volume_stats_used_bytes:42|g|#namespace:default".
+//
+// Gauges and untyped metrics are gauges. Counters are counts of their
+// increase since the previous encoding, the first encoding of a counter only
+// records its value, so an encoder must only be used by one pusher.
+// Summaries, histograms and samples which are not finite
+// are skipped.
+type StatsD struct {
+	mu sync.Mutex
+	// counters are the values of counters in the previous encoding, by
+	// series.
+	counters map[string]float64
+}
+
+var _ Encoder = &StatsD{}
+
+// NewStatsD creates a StatsD encoder.
+func NewStatsD() *StatsD {
+	return &StatsD{counters: map[string]float64{}}
+}
+
+// ContentType implements the Encoder interface.
+func (s *StatsD) ContentType() string {
+	return "text/plain; charset=utf-8"
+}
+
+// Encode implements the Encoder interface. Timestamps are not supported by
+// the protocol.
+func (s *StatsD) Encode(w io.Writer, families []*dto.MetricFamily, now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Counters not gathered anymore are forgotten.
+	counters := map[string]float64{}
+	bw := bufio.NewWriter(w)
+	for _, family := range families {
+		for _, m := range family.Metric {
+			typ, value, ok := sampleValue(family.GetType(), m)
+			if !ok || math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+			var tags bytes.Buffer
+			for i, l := range sortedLabels(m) {
+				if i == 0 {
+					tags.WriteString("|#")
+				} else {
+					tags.WriteByte(',')
+				}
+				tags.WriteString(statsDTagEscaper.Replace(l.GetName()))
+				tags.WriteByte(':')
+				tags.WriteString(statsDTagEscaper.Replace(l.GetValue()))
+			}
+
+			metricType := "g"
+			if typ == "counter" {
+				key := family.GetName() + tags.String()
+				counters[key] = value
+				previous, ok := s.counters[key]
+				if !ok {
+					continue
+				}
+				metricType = "c"
+				if value >= previous {
+					value -= previous
+				}
+				// Otherwise the counter was reset, it increased by its value.
+			} else if value < 0 {
+				// Signed gauges change the value in StatsD, set it to zero
+				// first.
+				writeStatsDLine(bw, family.GetName(), 0, metricType, tags.Bytes())
+			}
+			writeStatsDLine(bw, family.GetName(), value, metricType, tags.Bytes())
+		}
+	}
+	s.counters = counters
+	return bw.Flush()
+}
+
+func writeStatsDLine(w *bufio.Writer, name string, value float64, metricType string, tags []byte) {
+	w.WriteString(name)
+	w.WriteByte(':')
+	w.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	w.WriteByte('|')
+	w.WriteString(metricType)
+	w.Write(tags)
+	w.WriteByte('\n')
+}
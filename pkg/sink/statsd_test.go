@@ -0,0 +1,67 @@
+package sink
+
+import (
+	"bytes"
+	"math"
+	"testing"
+	"time"
+
+	dto "github.com/prometheus/client_model/go"
+)
+
+func TestStatsDEncode(t *testing.T) {
+	s := NewStatsD()
+	// encode encodes a counter of value, skipped if negative, and returns
+	// the lines.
+	encode := func(counter float64, families ...*dto.MetricFamily) string {
+		if counter >= 0 {
+			families = append(families, newFamily("kubelet_volume_nfs_operations_total", dto.MetricType_COUNTER,
+				newMetric(dto.MetricType_COUNTER, counter, "operation", "READ", "namespace", "team-a"),
+			))
+		}
+		var buf bytes.Buffer
+		if err := s.Encode(&buf, families, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		return buf.String()
+	}
+
+	gauges := newFamily("kubelet_volume_stats_used_bytes", dto.MetricType_GAUGE,
+		newMetric(dto.MetricType_GAUGE, 400, "persistentvolumeclaim", "data", "namespace", "team-a", "source", ""),
+		// Signed gauges change the value, it is set to zero first.
+		newMetric(dto.MetricType_GAUGE, -2.5, "namespace", "team-b"),
+		newMetric(dto.MetricType_GAUGE, math.NaN(), "namespace", "team-c"),
+	)
+	others := []*dto.MetricFamily{
+		newFamily("untyped", dto.MetricType_UNTYPED, newMetric(dto.MetricType_UNTYPED, 1, "odd,key", "a|b:c\nd")),
+		newFamily("summary", dto.MetricType_SUMMARY, newMetric(dto.MetricType_SUMMARY, 1)),
+	}
+	// The first value of a counter is only recorded.
+	want := `kubelet_volume_stats_used_bytes:400|g|#namespace:team-a,persistentvolumeclaim:data
+kubelet_volume_stats_used_bytes:0|g|#namespace:team-b
+kubelet_volume_stats_used_bytes:-2.5|g|#namespace:team-b
+untyped:1|g|#odd_key:a_b_c_d
+`
+	if got := encode(10, append([]*dto.MetricFamily{gauges}, others...)...); got != want {
+		t.Errorf("got first encoding\n%s\nwant\n%s", got, want)
+	}
+
+	for _, step := range []struct {
+		name    string
+		counter float64
+		want    string
+	}{
+		{name: "increase", counter: 15, want: "kubelet_volume_nfs_operations_total:5|c|#namespace:team-a,operation:READ\n"},
+		{name: "no increase", counter: 15, want: "kubelet_volume_nfs_operations_total:0|c|#namespace:team-a,operation:READ\n"},
+		// Reset, it increased by its value.
+		{name: "reset", counter: 3, want: "kubelet_volume_nfs_operations_total:3|c|#namespace:team-a,operation:READ\n"},
+		{name: "not gathered", counter: -1, want: ""},
+		// Forgotten, the value is only recorded again.
+		{name: "gathered again", counter: 20, want: ""},
+		{name: "increase after forgetting", counter: 21, want: "kubelet_volume_nfs_operations_total:1|c|#namespace:team-a,operation:READ\n"},
+	} {
+		if got := encode(step.counter); got != step.want {
+			t.Errorf("%s: got %q, want %q", step.name, got, step.want)
+		}
+	}
+}